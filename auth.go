@@ -0,0 +1,275 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+const (
+	authMethodAPIKey = "api-key"
+	authMethodJWT    = "jwt"
+)
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidAPIKey      = errors.New("invalid API key")
+	errInvalidToken       = errors.New("invalid bearer token")
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Subject string
+	Method  string
+}
+
+type identityContextKey struct{}
+
+func contextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// identityFromContext returns the caller identity, if the request has been authenticated.
+func identityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(Identity)
+	return identity, ok
+}
+
+// apiKey is an entry of the API keys file.
+type apiKey struct {
+	Key     string `json:"key"`
+	Subject string `json:"subject"`
+}
+
+// authenticator validates the API keys and the JWT bearer tokens of the requests.
+type authenticator struct {
+	required bool
+	// apiKeys maps the sha256 of every key to its subject
+	apiKeys   map[[sha256.Size]byte]string
+	jwtSecret []byte
+	jwks      map[string]*rsa.PublicKey
+	issuer    string
+	audience  string
+}
+
+// newAuthenticator loads the credentials configured by the environment.
+// It returns nil when no credentials are configured, leaving every route anonymous.
+func newAuthenticator(env EnvironmentVariables) (*authenticator, error) {
+	if env.AuthAPIKeysFilePath == "" && env.AuthJWTSecret == "" && env.AuthJWKSFilePath == "" {
+		if env.AuthRequired {
+			return nil, errors.New("authentication is required but no API keys, JWT secret or JWKS are configured")
+		}
+		return nil, nil
+	}
+
+	auth := &authenticator{
+		required:  env.AuthRequired,
+		apiKeys:   map[[sha256.Size]byte]string{},
+		jwtSecret: []byte(env.AuthJWTSecret),
+		issuer:    env.AuthJWTIssuer,
+		audience:  env.AuthJWTAudience,
+	}
+	if env.AuthAPIKeysFilePath != "" {
+		keys, err := readAPIKeys(env.AuthAPIKeysFilePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			auth.apiKeys[sha256.Sum256([]byte(key.Key))] = key.Subject
+		}
+	}
+	if env.AuthJWKSFilePath != "" {
+		jwks, err := readJWKS(env.AuthJWKSFilePath)
+		if err != nil {
+			return nil, err
+		}
+		auth.jwks = jwks
+	}
+	return auth, nil
+}
+
+func readAPIKeys(filePath string) ([]apiKey, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading API keys: %w", err)
+	}
+	var keys []apiKey
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return nil, fmt.Errorf("parsing API keys %s: %w", filePath, err)
+	}
+	for i, key := range keys {
+		if key.Key == "" || key.Subject == "" {
+			return nil, fmt.Errorf("parsing API keys %s: entry %d must have both key and subject", filePath, i)
+		}
+	}
+	return keys, nil
+}
+
+// jsonWebKey is the subset of RFC 7517 needed to verify RSA signatures.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func readJWKS(filePath string) (map[string]*rsa.PublicKey, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS: %w", err)
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &jwks); err != nil {
+		return nil, fmt.Errorf("parsing JWKS %s: %w", filePath, err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWKS %s: key %q has an invalid modulus", filePath, key.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("parsing JWKS %s: key %q has an invalid exponent", filePath, key.Kid)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("parsing JWKS %s: no RSA keys found", filePath)
+	}
+	return keys, nil
+}
+
+// Authenticate returns the identity carried by the request credentials.
+// errMissingCredentials is returned when the request has none.
+func (a *authenticator) Authenticate(req *http.Request) (Identity, error) {
+	if key := req.Header.Get(apiKeyHeader); key != "" {
+		subject, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return Identity{}, errInvalidAPIKey
+		}
+		return Identity{Subject: subject, Method: authMethodAPIKey}, nil
+	}
+
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return Identity{}, errMissingCredentials
+	}
+	const bearerPrefix = "bearer "
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return Identity{}, errInvalidToken
+	}
+	return a.validateToken(strings.TrimSpace(authorization[len(bearerPrefix):]))
+}
+
+func (a *authenticator) validateToken(rawToken string) (Identity, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, a.keyFunc, jwt.WithValidMethods(a.validMethods()))
+	if err != nil {
+		return Identity{}, errInvalidToken
+	}
+	if claims.Subject == "" {
+		return Identity{}, errInvalidToken
+	}
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return Identity{}, errInvalidToken
+	}
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return Identity{}, errInvalidToken
+	}
+	return Identity{Subject: claims.Subject, Method: authMethodJWT}, nil
+}
+
+func (a *authenticator) validMethods() []string {
+	var methods []string
+	if len(a.jwtSecret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(a.jwks) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	return methods
+}
+
+// keyFunc picks the verification key matching the signing method and the key id of the token.
+func (a *authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.jwtSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.jwks[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(a.jwks) == 1 {
+			for _, key := range a.jwks {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// authMiddleware stores the caller identity in the request context.
+// Invalid credentials are always rejected, while anonymous requests are rejected only when
+// authentication is required. The status and metrics routes are never authenticated.
+func authMiddleware(auth *authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if auth == nil || strings.HasPrefix(req.URL.Path, "/-/") {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			identity, err := auth.Authenticate(req)
+			if err == errMissingCredentials && !auth.required {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="feriapp"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(contextWithIdentity(req.Context(), identity)))
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, name string, content interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(content)
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "feriapp-auth")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filePath := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(filePath, raw, 0600))
+	return filePath
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestNewAuthenticator(testCase *testing.T) {
+	testCase.Run("nil when nothing is configured", func(t *testing.T) {
+		auth, err := newAuthenticator(EnvironmentVariables{})
+		require.NoError(t, err)
+		require.Nil(t, auth)
+	})
+
+	testCase.Run("required without credentials", func(t *testing.T) {
+		_, err := newAuthenticator(EnvironmentVariables{AuthRequired: true})
+		require.Error(t, err)
+	})
+
+	testCase.Run("missing API keys file", func(t *testing.T) {
+		_, err := newAuthenticator(EnvironmentVariables{AuthAPIKeysFilePath: "./missing.json"})
+		require.Error(t, err)
+	})
+
+	testCase.Run("API key without subject", func(t *testing.T) {
+		filePath := writeTestFile(t, "keys.json", []apiKey{{Key: "secret"}})
+		_, err := newAuthenticator(EnvironmentVariables{AuthAPIKeysFilePath: filePath})
+		require.EqualError(t, err, "parsing API keys "+filePath+": entry 0 must have both key and subject")
+	})
+
+	testCase.Run("JWKS without RSA keys", func(t *testing.T) {
+		filePath := writeTestFile(t, "jwks.json", map[string]interface{}{"keys": []jsonWebKey{{Kty: "EC", Kid: "ec"}}})
+		_, err := newAuthenticator(EnvironmentVariables{AuthJWKSFilePath: filePath})
+		require.Error(t, err)
+	})
+}
+
+func TestAuthMiddleware(testCase *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(testCase, err)
+	jwksPath := writeTestFile(testCase, "jwks.json", map[string]interface{}{"keys": []jsonWebKey{{
+		Kty: "RSA",
+		Kid: "partner",
+		N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}})
+	keysPath := writeTestFile(testCase, "keys.json", []apiKey{{Key: "partner-key", Subject: "partner"}})
+	secret := []byte("shared-secret")
+
+	newRouter := func(env EnvironmentVariables) *mux.Router {
+		auth, err := newAuthenticator(env)
+		require.NoError(testCase, err)
+
+		router := mux.NewRouter()
+		router.Use(authMiddleware(auth))
+		StatusRoutes(router, "feriapp-backend-go", "")
+		router.HandleFunc("/whoami", func(w http.ResponseWriter, req *http.Request) {
+			identity, ok := identityFromContext(req.Context())
+			if !ok {
+				w.Write([]byte("anonymous"))
+				return
+			}
+			w.Write([]byte(identity.Method + ":" + identity.Subject))
+		})
+		return router
+	}
+	serve := func(router *mux.Router, setup func(req *http.Request)) *httptest.ResponseRecorder {
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		setup(request)
+		router.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	validClaims := jwt.RegisteredClaims{
+		Subject:   "user-1",
+		Issuer:    "https://auth.example.com",
+		Audience:  jwt.ClaimStrings{"feriapp"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	router := newRouter(EnvironmentVariables{
+		AuthAPIKeysFilePath: keysPath,
+		AuthJWTSecret:       string(secret),
+		AuthJWKSFilePath:    jwksPath,
+		AuthJWTIssuer:       "https://auth.example.com",
+		AuthJWTAudience:     "feriapp",
+	})
+
+	testCase.Run("anonymous requests are allowed when not required", func(t *testing.T) {
+		responseRecorder := serve(router, func(req *http.Request) {})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "anonymous", responseRecorder.Body.String())
+	})
+
+	testCase.Run("API key", func(t *testing.T) {
+		responseRecorder := serve(router, func(req *http.Request) {
+			req.Header.Set(apiKeyHeader, "partner-key")
+		})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "api-key:partner", responseRecorder.Body.String())
+	})
+
+	testCase.Run("invalid API key", func(t *testing.T) {
+		responseRecorder := serve(router, func(req *http.Request) {
+			req.Header.Set(apiKeyHeader, "wrong")
+		})
+		require.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+		require.NotEmpty(t, responseRecorder.Header().Get("WWW-Authenticate"))
+	})
+
+	testCase.Run("HMAC bearer token", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodHS256, secret, "", validClaims)
+		responseRecorder := serve(router, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "jwt:user-1", responseRecorder.Body.String())
+	})
+
+	testCase.Run("RSA bearer token from the JWKS", func(t *testing.T) {
+		token := signToken(t, jwt.SigningMethodRS256, rsaKey, "partner", validClaims)
+		responseRecorder := serve(router, func(req *http.Request) {
+			req.Header.Set("Authorization", "bearer "+token)
+		})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "jwt:user-1", responseRecorder.Body.String())
+	})
+
+	testCase.Run("rejected tokens", func(t *testing.T) {
+		expired := validClaims
+		expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		wrongAudience := validClaims
+		wrongAudience.Audience = jwt.ClaimStrings{"other"}
+		wrongIssuer := validClaims
+		wrongIssuer.Issuer = "https://evil.example.com"
+		noSubject := validClaims
+		noSubject.Subject = ""
+
+		tokens := map[string]string{
+			"expired":        signToken(t, jwt.SigningMethodHS256, secret, "", expired),
+			"wrong audience": signToken(t, jwt.SigningMethodHS256, secret, "", wrongAudience),
+			"wrong issuer":   signToken(t, jwt.SigningMethodHS256, secret, "", wrongIssuer),
+			"no subject":     signToken(t, jwt.SigningMethodHS256, secret, "", noSubject),
+			"wrong secret":   signToken(t, jwt.SigningMethodHS256, []byte("other"), "", validClaims),
+			"unknown kid":    signToken(t, jwt.SigningMethodRS256, rsaKey, "other", validClaims),
+			"none":           signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims),
+			"malformed":      "not-a-token",
+		}
+		for name, token := range tokens {
+			responseRecorder := serve(router, func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			})
+			require.Equal(t, http.StatusUnauthorized, responseRecorder.Code, name)
+		}
+	})
+
+	testCase.Run("non bearer authorization", func(t *testing.T) {
+		responseRecorder := serve(router, func(req *http.Request) {
+			req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		})
+		require.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+	})
+
+	testCase.Run("anonymous requests are rejected when required", func(t *testing.T) {
+		requiredRouter := newRouter(EnvironmentVariables{AuthRequired: true, AuthAPIKeysFilePath: keysPath})
+		responseRecorder := serve(requiredRouter, func(req *http.Request) {})
+		require.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+	})
+
+	testCase.Run("status routes are not authenticated", func(t *testing.T) {
+		requiredRouter := newRouter(EnvironmentVariables{AuthRequired: true, AuthAPIKeysFilePath: keysPath})
+		responseRecorder := httptest.NewRecorder()
+		requiredRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/-/healthz", nil))
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+	})
+}
@@ -0,0 +1,515 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/leave"
+	"feriapp-backend-go/planner"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+var (
+	errBookingNotFound = errors.New("booking not found")
+	errLeaveNotTracked = errors.New("the profile does not track its leave accrual")
+	errNotEnoughLeave  = errors.New("not enough leave")
+)
+
+// bridgesFilter adapts the bridges of a year to the caller. The bridges it receives are
+// shared through the cache, so it must return new slices instead of modifying them.
+type bridgesFilter func(yearBridges bridges.YearBridges) bridges.YearBridges
+
+func unfiltered(yearBridges bridges.YearBridges) bridges.YearBridges {
+	return yearBridges
+}
+
+// leaveBalance is the use of the leave of a year. Remaining is omitted when the
+// profile does not track its annual leave.
+type leaveBalance struct {
+	Year    int `json:"year"`
+	Budget  int `json:"budget"`
+	Planned int `json:"planned"`
+	Booked  int `json:"booked"`
+	// Forced is the leave taken by the closures of the organization of the profile.
+	Forced int `json:"forced"`
+	// Compensation is the leave given back for the holidays falling on the compensated weekdays.
+	Compensation int  `json:"compensation"`
+	Remaining    *int `json:"remaining,omitempty"`
+}
+
+func setupBookingsRouter(router *mux.Router, store storage.Store) {
+	router.HandleFunc("/profiles/{id}/bookings", createBooking(store)).Methods(http.MethodPost)
+	router.HandleFunc("/profiles/{id}/bookings", listBookings(store)).Methods(http.MethodGet)
+	router.HandleFunc("/profiles/{id}/bookings/{bookingId}", updateBooking(store)).Methods(http.MethodPut)
+	router.HandleFunc("/profiles/{id}/bookings/{bookingId}", deleteBooking(store)).Methods(http.MethodDelete)
+	router.HandleFunc("/profiles/{id}/leave", getLeave(store)).Methods(http.MethodGet)
+	router.HandleFunc("/profiles/{id}/leave/balance", getLeaveBalance(store)).Methods(http.MethodGet)
+}
+
+// parseBridgeID returns the first and the last day of a bridge from its id.
+func parseBridgeID(id string) (civil.Date, civil.Date, error) {
+	invalid := fmt.Errorf("invalid bridgeId %q: must be formatted as YYYY-MM-DD-YYYY-MM-DD", id)
+	if len(id) != len("2006-01-02-2006-01-02") || id[10] != '-' {
+		return civil.Date{}, civil.Date{}, invalid
+	}
+	start, err := civil.ParseDate(id[:10])
+	if err != nil {
+		return civil.Date{}, civil.Date{}, invalid
+	}
+	end, err := civil.ParseDate(id[11:])
+	if err != nil || end.Before(start) {
+		return civil.Date{}, civil.Date{}, invalid
+	}
+	return start, end, nil
+}
+
+func validateBookingStatus(status string) error {
+	if status != storage.BookingPlanned && status != storage.BookingBooked {
+		return fmt.Errorf("invalid status %q: must be %s or %s", status, storage.BookingPlanned, storage.BookingBooked)
+	}
+	return nil
+}
+
+// usedLeave sums the leave spent by the bookings in every year, net of the compensations.
+func usedLeave(bookings []storage.Booking) map[int]int {
+	used := map[int]int{}
+	for _, booking := range bookings {
+		if booking.Status == storage.BookingCompensation {
+			used[booking.Start.Year] -= booking.LeaveDays
+			continue
+		}
+		used[booking.Start.Year] += booking.LeaveDays
+	}
+	return used
+}
+
+func leaveBalances(profile storage.Profile, bookings []storage.Booking, fromYear int) []leaveBalance {
+	years := profile.YearsScope
+	if years == 0 {
+		years = defaultYearsScope
+	}
+	balances := make([]leaveBalance, 0, years)
+	for year := fromYear; year < fromYear+years; year++ {
+		balance := leaveBalance{Year: year, Budget: profile.AnnualLeaveDays}
+		for _, booking := range bookings {
+			if booking.Start.Year != year {
+				continue
+			}
+			switch booking.Status {
+			case storage.BookingBooked:
+				balance.Booked += booking.LeaveDays
+			case storage.BookingForced:
+				balance.Forced += booking.LeaveDays
+			case storage.BookingCompensation:
+				balance.Compensation += booking.LeaveDays
+			default:
+				balance.Planned += booking.LeaveDays
+			}
+		}
+		if profile.AnnualLeaveDays > 0 {
+			remaining := balance.Budget + balance.Compensation - balance.Planned - balance.Booked - balance.Forced
+			balance.Remaining = &remaining
+		}
+		balances = append(balances, balance)
+	}
+	return balances
+}
+
+// untilFilter removes the bridges starting after until from those kept by filter.
+func untilFilter(until civil.Date, filter bridgesFilter) bridgesFilter {
+	return func(yearBridges bridges.YearBridges) bridges.YearBridges {
+		yearBridges = filter(yearBridges)
+		filtered := make([]bridges.Bridge, 0, len(yearBridges.Bridges))
+		for _, bridge := range yearBridges.Bridges {
+			if !bridge.Start.After(until) {
+				filtered = append(filtered, bridge)
+			}
+		}
+		yearBridges.Bridges = filtered
+		return yearBridges
+	}
+}
+
+// leaveAccount returns the leave account of the profile with the leave used by its bookings,
+// and granted by its compensations. Bookings before the start of the account are left out,
+// since their leave is already accounted for by the opening lots.
+func leaveAccount(profile storage.Profile, bookings []storage.Booking) leave.Account {
+	account := *profile.Leave
+	account.Grants = append([]leave.Grant{}, account.Grants...)
+	account.Usages = make([]leave.Usage, 0, len(bookings))
+	for _, booking := range bookings {
+		if booking.Start.Before(account.Start) {
+			continue
+		}
+		if booking.Status == storage.BookingCompensation {
+			account.Grants = append(account.Grants, leave.Grant{Name: storage.BookingCompensation, Date: booking.Start, Hours: account.Policy.Hours(float64(booking.LeaveDays))})
+			continue
+		}
+		account.Usages = append(account.Usages, leave.Usage{Date: booking.Start, Hours: account.Policy.Hours(float64(booking.LeaveDays))})
+	}
+	return account
+}
+
+// checkLeave fails with errNotEnoughLeave when the profile cannot afford leaveDays on date
+// besides its bookings.
+func checkLeave(profile storage.Profile, bookings []storage.Booking, date civil.Date, leaveDays int) error {
+	if profile.AnnualLeaveDays == 0 && profile.Leave == nil {
+		return nil
+	}
+	used := usedLeave(bookings)
+	if canAfford(profile, bookings, used, date, leaveDays) {
+		return nil
+	}
+	if profile.Leave != nil {
+		return fmt.Errorf("%w: the bridge costs %d days not accrued by %s", errNotEnoughLeave, leaveDays, date)
+	}
+	return fmt.Errorf("%w: the bridge costs %d days but %d remain in %d", errNotEnoughLeave, leaveDays, profile.AnnualLeaveDays-used[date.Year], date.Year)
+}
+
+// derivedBookings returns the bookings computed for the profile, which are never stored.
+func derivedBookings(bookings []storage.Booking) []storage.Booking {
+	derived := []storage.Booking{}
+	for _, booking := range bookings {
+		if booking.Status == storage.BookingForced || booking.Status == storage.BookingCompensation {
+			derived = append(derived, booking)
+		}
+	}
+	return derived
+}
+
+// canAfford tells whether the profile can take leaveDays more days of leave for a bridge starting on date.
+// The leave account is used when the profile has one, otherwise the annual leave when it is tracked.
+func canAfford(profile storage.Profile, bookings []storage.Booking, used map[int]int, date civil.Date, leaveDays int) bool {
+	switch {
+	case profile.Leave != nil:
+		return leaveDays == 0 || leaveAccount(profile, bookings).CanAfford(date, leaveDays)
+	case profile.AnnualLeaveDays > 0:
+		return leaveDays <= profile.AnnualLeaveDays-used[date.Year]
+	default:
+		return true
+	}
+}
+
+// bookingsFilter removes the bridges overlapping the bookings of the profile, those within the
+// closures of its organization and, when the profile tracks its leave, those costing more than
+// the leave available. Bridges only overlapping a closure are kept, since they extend it.
+func bookingsFilter(profile storage.Profile, bookings []storage.Booking) bridgesFilter {
+	if len(bookings) == 0 && profile.AnnualLeaveDays == 0 && profile.Leave == nil {
+		return unfiltered
+	}
+	used := usedLeave(bookings)
+
+	return func(yearBridges bridges.YearBridges) bridges.YearBridges {
+		filtered := make([]bridges.Bridge, 0, len(yearBridges.Bridges))
+		for _, bridge := range yearBridges.Bridges {
+			if !canAfford(profile, bookings, used, bridge.Start, bridge.WeekdaysCount) {
+				continue
+			}
+			overlaps := false
+			for _, booking := range bookings {
+				if booking.Status == storage.BookingCompensation {
+					continue
+				}
+				if booking.Status == storage.BookingForced {
+					overlaps = !bridge.Start.Before(booking.Start) && !bridge.End.After(booking.End)
+				} else {
+					overlaps = booking.Overlaps(bridge.Start, bridge.End)
+				}
+				if overlaps {
+					break
+				}
+			}
+			if !overlaps {
+				filtered = append(filtered, bridge)
+			}
+		}
+		yearBridges.Bridges = filtered
+		return yearBridges
+	}
+}
+
+// bookingsETag changes the etag of the bridges of a profile whenever its bookings
+// or its leave change, since both filter the response.
+func bookingsETag(etag string, profile storage.Profile, bookings []storage.Booking) string {
+	return filteredETag(etag, struct {
+		AnnualLeaveDays int
+		Leave           *leave.Account
+		Bookings        []storage.Booking
+	}{profile.AnnualLeaveDays, profile.Leave, bookings})
+}
+
+// filteredETag derives the etag of a filtered response from the etag of the unfiltered
+// one and the state the filter depends on.
+func filteredETag(etag string, filterState interface{}) string {
+	state, _ := json.Marshal(filterState)
+	hash := sha256.New()
+	hash.Write([]byte(etag))
+	hash.Write(state)
+	return fmt.Sprintf("%q", hex.EncodeToString(hash.Sum(nil)[:16]))
+}
+
+func createBooking(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			BridgeId string `json:"bridgeId"`
+			Status   string `json:"status"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if body.Status == "" {
+			body.Status = storage.BookingPlanned
+		}
+		if err := validateBookingStatus(body.Status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		start, end, err := parseBridgeID(body.BridgeId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		bookings, organization, err := profileBookings(req.Context(), store, profile, end.Year)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		// the days the organization is closed are not paid twice
+		reqBody := bridges.BridgesRequest{CustomHolidays: profile.CustomHolidays, YearsScope: end.Year - start.Year}
+		if err := applyOrganization(&reqBody, organization, start.Year); err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		customHolidays, err := customHolidayDates(req.Context(), reqBody.CustomHolidays, start.Year, end.Year-start.Year)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		leaveDays, err := bridgesPlanner.LeaveCost(req.Context(), planner.Options{
+			Country:        profile.Country,
+			City:           profile.City,
+			DaysOff:        profile.DaysOff,
+			CustomHolidays: customHolidays,
+		}, start, end)
+		if err != nil {
+			writeComputationError(w, err)
+			return
+		}
+
+		// the budget is checked against the bookings saved when the booking is, so that
+		// concurrent bookings cannot overdraw it
+		booking, err := store.CreateBookingIf(req.Context(), storage.Booking{
+			ProfileId: profile.Id,
+			BridgeId:  body.BridgeId,
+			Status:    body.Status,
+			Start:     start,
+			End:       end,
+			LeaveDays: leaveDays,
+		}, func(stored []storage.Booking) error {
+			return checkLeave(profile, append(stored, derivedBookings(bookings)...), start, leaveDays)
+		})
+		if errors.Is(err, storage.ErrOverlap) {
+			http.Error(w, fmt.Sprintf("bridge %s %s", body.BridgeId, err.Error()), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errNotEnoughLeave) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			err = errProfileNotFound
+		}
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		w.Header().Set("Location", path.Join(req.URL.Path, booking.Id))
+		writeResponse(glogger.Get(req.Context()), w, http.StatusCreated, booking)
+	}
+}
+
+func listBookings(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		bookings, err := store.ListBookings(req.Context(), profile.Id)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, bookings)
+	}
+}
+
+// findBooking returns the booking only when it belongs to a profile of the caller.
+func findBooking(req *http.Request, store storage.Store) (storage.Booking, error) {
+	profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+	if err != nil {
+		return storage.Booking{}, err
+	}
+	booking, err := store.GetBooking(req.Context(), mux.Vars(req)["bookingId"])
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && booking.ProfileId != profile.Id) {
+		return storage.Booking{}, errBookingNotFound
+	}
+	return booking, err
+}
+
+func writeBookingError(w http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, errBookingNotFound) || errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, errBookingNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	writeProfileError(w, req, err)
+}
+
+// updateBooking changes the status of a booking, as when planned leave gets booked.
+func updateBooking(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if err := validateBookingStatus(body.Status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		booking, err := findBooking(req, store)
+		if err == nil {
+			booking, err = store.UpdateBookingStatus(req.Context(), booking.Id, body.Status)
+		}
+		if err != nil {
+			writeBookingError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, booking)
+	}
+}
+
+func deleteBooking(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		booking, err := findBooking(req, store)
+		if err == nil {
+			err = store.DeleteBooking(req.Context(), booking.Id)
+		}
+		if err != nil {
+			writeBookingError(w, req, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getLeave returns the leave balance of the years planned by the profile.
+func getLeave(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		years := profile.YearsScope
+		if years == 0 {
+			years = defaultYearsScope
+		}
+		now, err := requestNow(profile.BridgesRequest)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		fromYear := now.Year()
+		bookings, _, err := profileBookings(req.Context(), store, profile, fromYear+years-1)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, leaveBalances(profile, bookings, fromYear))
+	}
+}
+
+// getLeaveBalance returns the balance of the leave account of the profile at the end of the day
+// given by the at query parameter, today in the time zone of the profile by default, after the
+// leave used by the bookings. Days more than maxYearsScope years after the start of the account
+// are rejected, since the account is simulated day by day.
+func getLeaveBalance(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var at civil.Date
+		if value := req.URL.Query().Get("at"); value != "" {
+			var err error
+			if at, err = civil.ParseDate(value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid at value %q: must be formatted as YYYY-MM-DD", value), http.StatusBadRequest)
+				return
+			}
+		}
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		if profile.Leave == nil {
+			http.Error(w, errLeaveNotTracked.Error(), http.StatusNotFound)
+			return
+		}
+		if at.IsZero() {
+			now, err := requestNow(profile.BridgesRequest)
+			if err != nil {
+				writeProfileError(w, req, err)
+				return
+			}
+			at = civil.DateOf(now)
+		}
+		if horizon := profile.Leave.Start.AddDate(maxYearsScope, 0, 0); at.After(horizon) {
+			http.Error(w, fmt.Sprintf("invalid at value %q: must be at most %d years after the start of the leave account", at, maxYearsScope), http.StatusBadRequest)
+			return
+		}
+		bookings, _, err := profileBookings(req.Context(), store, profile, at.Year)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		balance, err := leaveAccount(profile, bookings).BalanceAt(at)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, balance)
+	}
+}
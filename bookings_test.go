@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/leave"
+	"feriapp-backend-go/planner"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBridgeID(testCase *testing.T) {
+	testCase.Run("valid", func(t *testing.T) {
+		start, end, err := parseBridgeID("2030-04-25-2030-04-28")
+		require.NoError(t, err)
+		require.Equal(t, civil.NewDate(2030, 4, 25), start)
+		require.Equal(t, civil.NewDate(2030, 4, 28), end)
+	})
+
+	testCase.Run("invalid", func(t *testing.T) {
+		for _, id := range []string{"", "2030-04-25", "2030-04-25-2030-04-2x", "2030-04-28-2030-04-25", "2030-04-25+2030-04-28"} {
+			_, _, err := parseBridgeID(id)
+			require.Error(t, err, id)
+		}
+	})
+}
+
+func TestBookingsFilter(testCase *testing.T) {
+	day := func(month time.Month, day int) civil.Date { return civil.NewDate(2030, month, day) }
+	date := func(month time.Month, day int) civil.Date { return civil.NewDate(2030, month, day) }
+	yearBridges := bridges.YearBridges{
+		Years: []string{"2030"},
+		Bridges: []bridges.Bridge{
+			{Id: "easter", Start: date(4, 20), End: date(4, 22), WeekdaysCount: 0},
+			{Id: "liberation", Start: date(4, 25), End: date(4, 28), WeekdaysCount: 1},
+			{Id: "labour", Start: date(4, 27), End: date(5, 5), WeekdaysCount: 4},
+		},
+	}
+	ids := func(yearBridges bridges.YearBridges) []string {
+		ids := []string{}
+		for _, bridge := range yearBridges.Bridges {
+			ids = append(ids, bridge.Id)
+		}
+		return ids
+	}
+
+	testCase.Run("nothing to filter", func(t *testing.T) {
+		filter := bookingsFilter(storage.Profile{}, nil)
+		require.Equal(t, yearBridges, filter(yearBridges))
+	})
+
+	testCase.Run("overlapping bridges are removed", func(t *testing.T) {
+		filter := bookingsFilter(storage.Profile{}, []storage.Booking{{Start: day(4, 28), End: day(4, 28)}})
+		require.Equal(t, []string{"easter"}, ids(filter(yearBridges)))
+		require.Len(t, yearBridges.Bridges, 3, "the cached bridges must not be modified")
+	})
+
+	testCase.Run("bridges over the remaining leave are removed", func(t *testing.T) {
+		filter := bookingsFilter(storage.Profile{AnnualLeaveDays: 5}, []storage.Booking{{Start: day(1, 2), End: day(1, 3), LeaveDays: 2}})
+		require.Equal(t, []string{"easter", "liberation"}, ids(filter(yearBridges)))
+	})
+
+	testCase.Run("bridges not accrued by their start are removed", func(t *testing.T) {
+		account := &leave.Account{
+			Policy: leave.Policy{HoursPerDay: 8, Entitlements: []leave.Entitlement{{Name: "ferie", Hours: 96, Accrual: leave.Monthly, CarryOverHours: -1}}},
+			Start:  day(1, 1),
+		}
+		// three days are accrued by the end of March
+		filter := bookingsFilter(storage.Profile{Leave: account}, nil)
+		require.Equal(t, []string{"easter", "liberation"}, ids(filter(yearBridges)))
+
+		filter = bookingsFilter(storage.Profile{Leave: account}, []storage.Booking{{Start: day(3, 1), End: day(3, 1), LeaveDays: 2}})
+		require.Equal(t, []string{"easter", "liberation"}, ids(filter(yearBridges)))
+
+		filter = bookingsFilter(storage.Profile{Leave: account}, []storage.Booking{{Start: day(3, 1), End: day(3, 1), LeaveDays: 3}})
+		require.Equal(t, []string{"easter"}, ids(filter(yearBridges)))
+	})
+
+	testCase.Run("compensations add to the leave and overlap nothing", func(t *testing.T) {
+		compensation := storage.Booking{Start: day(4, 21), End: day(4, 21), LeaveDays: 1, Status: storage.BookingCompensation}
+		filter := bookingsFilter(storage.Profile{AnnualLeaveDays: 3}, []storage.Booking{compensation})
+		require.Equal(t, []string{"easter", "liberation", "labour"}, ids(filter(yearBridges)))
+
+		account := &leave.Account{
+			Policy: leave.Policy{HoursPerDay: 8, Entitlements: []leave.Entitlement{{Name: "ferie", Hours: 96, Accrual: leave.Monthly, CarryOverHours: -1}}},
+			Start:  day(1, 1),
+		}
+		filter = bookingsFilter(storage.Profile{Leave: account}, []storage.Booking{compensation})
+		require.Equal(t, []string{"easter", "liberation", "labour"}, ids(filter(yearBridges)))
+	})
+
+	testCase.Run("bridges after until are removed", func(t *testing.T) {
+		filter := untilFilter(date(4, 25), bookingsFilter(storage.Profile{}, []storage.Booking{{Start: day(4, 20), End: day(4, 20)}}))
+		require.Equal(t, []string{"liberation"}, ids(filter(yearBridges)))
+		require.Len(t, yearBridges.Bridges, 3, "the cached bridges must not be modified")
+	})
+}
+
+func TestLeaveBalances(t *testing.T) {
+	bookings := []storage.Booking{
+		{Start: civil.NewDate(2030, 4, 25), LeaveDays: 1, Status: storage.BookingBooked},
+		{Start: civil.NewDate(2030, 12, 24), LeaveDays: 3, Status: storage.BookingPlanned},
+		{Start: civil.NewDate(2031, 1, 2), LeaveDays: 2, Status: storage.BookingPlanned},
+		{Start: civil.NewDate(2030, 6, 2), LeaveDays: 1, Status: storage.BookingCompensation},
+	}
+	remaining := func(days int) *int { return &days }
+
+	require.Equal(t, []leaveBalance{
+		{Year: 2030, Budget: 20, Planned: 3, Booked: 1, Compensation: 1, Remaining: remaining(17)},
+		{Year: 2031, Budget: 20, Planned: 2, Remaining: remaining(18)},
+	}, leaveBalances(storage.Profile{AnnualLeaveDays: 20, BridgesRequest: bridges.BridgesRequest{YearsScope: 2}}, bookings, 2030))
+
+	require.Equal(t, []leaveBalance{
+		{Year: 2030, Planned: 3, Booked: 1, Compensation: 1},
+	}, leaveBalances(storage.Profile{BridgesRequest: bridges.BridgesRequest{YearsScope: 1}}, bookings, 2030))
+}
+
+func TestBookingsRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	store := storage.NewMemory()
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), store, testMessages, testEnv)
+	setupProfilesRouter(testRouter, store)
+	setupBookingsRouter(testRouter, store)
+
+	serve := func(method, target string, body interface{}) *httptest.ResponseRecorder {
+		var requestBody bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&requestBody).Encode(body)
+		}
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(method, target, &requestBody))
+		return responseRecorder
+	}
+
+	var profile storage.Profile
+	responseRecorder := serve(http.MethodPost, "/profiles", map[string]interface{}{
+		"name":            "work",
+		"city":            "Milano",
+		"daysOff":         []int{0, 6},
+		"dayOfHolidays":   3,
+		"annualLeaveDays": 3,
+	})
+	require.Equal(testCase, http.StatusCreated, responseRecorder.Code)
+	require.NoError(testCase, json.Unmarshal(responseRecorder.Body.Bytes(), &profile))
+	bookingsPath := "/profiles/" + profile.Id + "/bookings"
+
+	var booking storage.Booking
+	testCase.Run("create", func(t *testing.T) {
+		// Thursday 25th of April is an holiday, so the bridge costs only the Friday
+		responseRecorder := serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": "2030-04-25-2030-04-28"})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &booking))
+		require.Equal(t, storage.BookingPlanned, booking.Status)
+		require.Equal(t, 1, booking.LeaveDays)
+		require.Equal(t, bookingsPath+"/"+booking.Id, responseRecorder.Header().Get("Location"))
+	})
+
+	testCase.Run("create - invalid", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": "easter"}).Code)
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": "2030-06-01-2030-06-02", "status": "maybe"}).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/profiles/unknown/bookings", map[string]string{"bridgeId": "2030-06-01-2030-06-02"}).Code)
+	})
+
+	testCase.Run("create - overlapping", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": "2030-04-26-2030-04-29"})
+		require.Equal(t, http.StatusConflict, responseRecorder.Code)
+	})
+
+	testCase.Run("create - not enough leave", func(t *testing.T) {
+		// from Wednesday 1st of May to Sunday 5th costs the Thursday and the Friday of the week after
+		responseRecorder := serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": "2030-05-01-2030-05-10"})
+		require.Equal(t, http.StatusConflict, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), "not enough leave: the bridge costs 7 days but 2 remain in 2030")
+	})
+
+	testCase.Run("update status", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPut, bookingsPath+"/"+booking.Id, map[string]string{"status": storage.BookingBooked})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var updated storage.Booking
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &updated))
+		require.Equal(t, storage.BookingBooked, updated.Status)
+
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPut, bookingsPath+"/"+booking.Id, map[string]string{"status": ""}).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodPut, bookingsPath+"/unknown", map[string]string{"status": storage.BookingBooked}).Code)
+	})
+
+	testCase.Run("list", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, bookingsPath, nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var bookings []storage.Booking
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &bookings))
+		require.Len(t, bookings, 1)
+	})
+
+	testCase.Run("leave", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/profiles/"+profile.Id+"/leave", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var balances []leaveBalance
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &balances))
+		require.Len(t, balances, defaultYearsScope)
+		require.Equal(t, time.Now().UTC().Year(), balances[0].Year)
+		require.Equal(t, 3, *balances[0].Remaining)
+	})
+
+	testCase.Run("bridges exclude the booked ones", func(t *testing.T) {
+		before := serve(http.MethodGet, "/bridges?profile="+profile.Id+"&yearsScope=1", nil)
+		require.Equal(t, http.StatusOK, before.Code)
+		var years []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(before.Body.Bytes(), &years))
+		require.NotEmpty(t, years[0].Bridges)
+		first := years[0].Bridges[0]
+		for _, bridge := range years[0].Bridges {
+			require.LessOrEqual(t, bridge.WeekdaysCount, 3, bridge.Id)
+		}
+
+		created := serve(http.MethodPost, bookingsPath, map[string]string{"bridgeId": first.Id})
+		require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+
+		after := serve(http.MethodGet, "/bridges?profile="+profile.Id+"&yearsScope=1", nil)
+		require.Equal(t, http.StatusOK, after.Code)
+		require.NotEqual(t, before.Header().Get("ETag"), after.Header().Get("ETag"))
+		require.NoError(t, json.Unmarshal(after.Body.Bytes(), &years))
+		for _, bridge := range years[0].Bridges {
+			require.False(t, !bridge.Start.After(first.End) && !first.Start.After(bridge.End), bridge.Id)
+		}
+	})
+
+	testCase.Run("bridges until a day", func(t *testing.T) {
+		until := civil.NewDate(time.Now().UTC().Year(), 6, 30)
+		all := serve(http.MethodGet, "/bridges?profile="+profile.Id+"&yearsScope=1", nil)
+		responseRecorder := serve(http.MethodGet, "/bridges?profile="+profile.Id+"&yearsScope=1&until="+until.String(), nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.NotEqual(t, all.Header().Get("ETag"), responseRecorder.Header().Get("ETag"))
+		var years []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &years))
+		for _, bridge := range years[0].Bridges {
+			require.False(t, bridge.Start.After(until), bridge.Id)
+		}
+
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/bridges?until=june", nil).Code)
+	})
+
+	testCase.Run("leave account", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/profiles", map[string]interface{}{
+			"name":    "contract",
+			"city":    "Milano",
+			"daysOff": []int{0, 6},
+			"leave": leave.Account{
+				Policy: leave.Policy{HoursPerDay: 8, Entitlements: []leave.Entitlement{{Name: "ferie", Hours: 96, Accrual: leave.Monthly, CarryOverHours: -1}}},
+				Start:  civil.NewDate(2030, 1, 1),
+			},
+		})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+		var contract storage.Profile
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &contract))
+		contractPath := "/profiles/" + contract.Id
+
+		responseRecorder = serve(http.MethodPost, contractPath+"/bookings", map[string]string{"bridgeId": "2030-04-25-2030-04-28"})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+
+		// four days are accrued by the end of April, one of which is already booked
+		responseRecorder = serve(http.MethodPost, contractPath+"/bookings", map[string]string{"bridgeId": "2030-05-01-2030-05-10"})
+		require.Equal(t, http.StatusConflict, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), "not enough leave: the bridge costs 7 days not accrued by 2030-05-01")
+
+		responseRecorder = serve(http.MethodGet, contractPath+"/leave/balance?at=2030-04-30", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var balance leave.Balance
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &balance))
+		require.Equal(t, 24.0, balance.Hours)
+		require.Equal(t, 3.0, balance.Days)
+
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodGet, contractPath+"/leave/balance?at=tomorrow", nil).Code)
+		responseRecorder = serve(http.MethodGet, contractPath+"/leave/balance?at=9999-12-31", nil)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), "must be at most 50 years after the start of the leave account")
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/profiles/"+profile.Id+"/leave/balance", nil).Code)
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/profiles", map[string]interface{}{
+			"name":  "invalid",
+			"leave": leave.Account{Policy: leave.Policy{HoursPerDay: 8}},
+		}).Code)
+	})
+
+	testCase.Run("compensated holidays", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/profiles", map[string]interface{}{
+			"name":            "compensated",
+			"city":            "Milano",
+			"daysOff":         []int{0, 6},
+			"annualLeaveDays": 20,
+			"compensatedDays": []int{0},
+			"yearsScope":      1,
+		})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+		var compensated storage.Profile
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &compensated))
+		require.Equal(t, []int{0}, compensated.CompensatedDays)
+
+		responseRecorder = serve(http.MethodGet, "/profiles/"+compensated.Id+"/leave", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var balances []leaveBalance
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &balances))
+		year := time.Now().UTC().Year()
+		dates, err := bridgesPlanner.Compensations(context.Background(), planner.Options{City: "Milano", DaysOff: []int{0, 6}}, []int{0},
+			civil.NewDate(year, 1, 1), civil.NewDate(year, 12, 31))
+		require.NoError(t, err)
+		require.Equal(t, len(dates), balances[0].Compensation)
+		require.Equal(t, 20+len(dates), *balances[0].Remaining)
+
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/profiles", map[string]interface{}{
+			"name":            "invalid",
+			"compensatedDays": []int{7},
+		}).Code)
+	})
+
+	testCase.Run("delete", func(t *testing.T) {
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, bookingsPath+"/"+booking.Id, nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, bookingsPath+"/"+booking.Id, nil).Code)
+	})
+
+	testCase.Run("deleting the profile deletes its bookings", func(t *testing.T) {
+		bookings, err := store.ListBookings(context.Background(), profile.Id)
+		require.NoError(t, err)
+		require.NotEmpty(t, bookings)
+
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/profiles/"+profile.Id, nil).Code)
+		bookings, err = store.ListBookings(context.Background(), profile.Id)
+		require.NoError(t, err)
+		require.Empty(t, bookings)
+	})
+}
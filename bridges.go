@@ -17,13 +17,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/civil"
 	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
+	"feriapp-backend-go/planner"
+	"feriapp-backend-go/storage"
 	"fmt"
 	"net/http"
-	"strconv"
+	"net/url"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -36,161 +42,280 @@ var (
 	// errBadRequest = errors.New("bad Request")
 )
 
-func setupBridgesRouter(router *mux.Router) {
+func setupBridgesRouter(router *mux.Router, bridgesCache *cache.LRU, store storage.Store, messages *i18n.Bundle, env EnvironmentVariables) {
+	timeout := time.Duration(env.ComputationTimeoutMs) * time.Millisecond
+
 	// Setup your routes here.
-	router.HandleFunc("/bridges", createBridges).Methods(http.MethodPost)
+	router.HandleFunc("/bridges", createBridges(bridgesCache, messages, timeout)).Methods(http.MethodPost)
+	router.HandleFunc("/bridges", getBridges(bridgesCache, store, messages, timeout)).Methods(http.MethodGet)
+	router.HandleFunc("/bridges/{id}/explain", explainBridge(bridgesCache, store, messages, timeout)).Methods(http.MethodGet)
+	router.HandleFunc("/bridges/batch", createBatchBridges(bridgesCache, messages, timeout, env.BatchWorkers, env.BatchMaxItems)).Methods(http.MethodPost)
 }
 
-func createBridges(w http.ResponseWriter, req *http.Request) {
-	var reqBody bridges.BridgesRequest
-	var responseBody []bridges.YearBridges
-
-	err := json.NewDecoder(req.Body).Decode(&reqBody)
+func createBridges(bridgesCache *cache.LRU, messages *i18n.Bundle, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var reqBody bridges.BridgesRequest
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+		_, decodeSpan := tracer.Start(req.Context(), "decodeBridgesRequest")
+		err := json.NewDecoder(req.Body).Decode(&reqBody)
+		if err != nil {
+			recordSpanError(decodeSpan, err)
+		}
+		decodeSpan.End()
 
-	logger := glogger.Get(req.Context())
-
-	if reqBody.YearsScope == 0 {
-		reqBody.YearsScope = 3
-	}
-	for i := 0; i < reqBody.YearsScope; i++ {
-		currentYear := time.Now().UTC()
-		yearBridges, err := bridgesByYear(
-			currentYear.AddDate(i, 0, 0),
-			4,
-			reqBody.DayOfHolidays,
-			reqBody.City,
-			reqBody.DaysOff,
-			true,
-		)
-		filteredBridges := []bridges.Bridge{}
-		for _, bridge := range yearBridges.Bridges {
-			if bridge.Start.After(time.Now().UTC().AddDate(0, 0, reqBody.DayOfHolidays)) {
-				filteredBridges = append(filteredBridges, bridge)
-			}
+		if err != nil {
+			writeDecodeError(w, err)
+			return
 		}
-		yearBridges.Bridges = filteredBridges
+
+		logger := glogger.Get(req.Context())
+
+		now, err := requestNow(reqBody)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		responseBody = append(responseBody, yearBridges)
-	}
+		if err := checkYearsScope(reqBody.YearsScope); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	writeResponse(logger, w, 200, responseBody)
-}
+		localizer := requestLocalizer(messages, req, reqBody.Lang)
+		normalizeBridgesRequest(&reqBody)
+		if err := resolveCity(&reqBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cacheKey := bridgesETag(reqBody, helpers.LanguagePackVersion(reqBody.Country), now)
+		filter := localizeFilter(glogger.Get(req.Context()), localizer, reqBody, unfiltered)
+		setLanguageHeaders(w, localizer)
+		ctx, cancel := withComputationDeadline(req.Context(), timeout)
+		defer cancel()
+		if acceptsNDJSON(req) {
+			streamBridges(ctx, w, bridgesCache, cacheKey, reqBody, now, filter)
+			return
+		}
+		computed, err := cachedComputeBridges(ctx, bridgesCache, cacheKey, reqBody, now)
+		if err != nil {
+			writeComputationError(w, err)
+			return
+		}
+		responseBody := make([]bridges.YearBridges, 0, len(computed))
+		for _, yearBridges := range computed {
+			responseBody = append(responseBody, filter(yearBridges))
+		}
+		observeBridgesReturned(responseBody)
 
-func bridgesByYear(date time.Time, maxHolidaysDistance int, maxAvailability int, city string, daysOff []int, skipPastBridges bool) (bridges.YearBridges, error) {
-	var daysOffMap = make(map[int]bool)
-	for i := 0; i < len(daysOff); i += 1 {
-		daysOffMap[daysOff[i]] = true
+		writeResponse(logger, w, 200, responseBody)
 	}
-	startDate := time.Date(date.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
-	var currentDate = startDate
+}
 
-	var isHolidays = helpers.HolidaysUtils(currentDate.Year(), daysOffMap, "IT", city)
+func getBridges(bridgesCache *cache.LRU, store storage.Store, messages *i18n.Bundle, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		reqBody, err := parseBridgesQuery(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	var scoreMap = map[int][]bridges.Bridge{}
-	var topBridges, goodBridges int
-	var calculatedBridges []bridges.Bridge
+		logger := glogger.Get(req.Context())
 
-	for !currentDate.UTC().After(time.Date(date.Year(), 12, 31, 0, 0, 0, 0, time.UTC)) {
+		filter := unfiltered
+		// committed are the bookings of the profile and the closures of its organization
+		var committed []storage.Booking
+		var profile storage.Profile
+		profileID := query.Get("profile")
+		if profileID != "" {
+			profile, committed, err = profileRequest(req.Context(), store, profileID, &reqBody, query)
+			if err != nil {
+				writeProfileError(w, req, err)
+				return
+			}
+			filter = bookingsFilter(profile, committed)
+		}
+		var until civil.Date
+		if value := query.Get("until"); value != "" {
+			if until, err = civil.ParseDate(value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid until value %q: must be formatted as YYYY-MM-DD", value), http.StatusBadRequest)
+				return
+			}
+			filter = untilFilter(until, filter)
+		}
 
-		isCurrentDateHolidays := isHolidays(currentDate)
-		availableDays := (map[bool]int{true: maxAvailability, false: maxAvailability - 1})[isCurrentDateHolidays]
-		// if no more days off are left and today is not holiday the bridge is closed
-		if maxAvailability == 0 && !isCurrentDateHolidays {
-			currentDate = currentDate.AddDate(0, 0, 1)
-			continue
+		localizer := requestLocalizer(messages, req, reqBody.Lang)
+		normalizeBridgesRequest(&reqBody)
+		if err := resolveCity(&reqBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		// if skipPastBridges is true only bridges that happens after today - maxAvailability day will be returned
-		if currentDate.Before(time.Now().AddDate(0, 0, -(maxAvailability+1))) && skipPastBridges {
-			currentDate = currentDate.AddDate(0, 0, 1)
-			continue
+		filter = localizeFilter(glogger.Get(req.Context()), localizer, reqBody, filter)
+		now, err := requestNow(reqBody)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cacheKey := bridgesETag(reqBody, helpers.LanguagePackVersion(reqBody.Country), now)
+		streaming := acceptsNDJSON(req)
+		etag := cacheKey
+		if profileID != "" {
+			etag = bookingsETag(cacheKey, profile, committed)
+		}
+		if !until.IsZero() {
+			etag = filteredETag(etag, until)
 		}
-		currentBridge := bridges.Bridge{
-			Start:         currentDate,
-			End:           currentDate,
-			HolidaysCount: (map[bool]int{true: 1, false: 0})[isCurrentDateHolidays],
-			WeekdaysCount: (map[bool]int{true: 0, false: 1})[isCurrentDateHolidays],
-			DaysCount:     1,
+		etag = filteredETag(etag, localizer.Language())
+		if streaming {
+			etag = ndjsonETag(etag)
+		}
+		setLanguageHeaders(w, localizer)
+		// bridges computed from a profile are personal and must not be stored by shared caches
+		setCacheHeaders(w, etag, now, profileID != "")
+		if etagMatches(req.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		ctx, cancel := withComputationDeadline(req.Context(), timeout)
+		defer cancel()
+		if streaming {
+			streamBridges(ctx, w, bridgesCache, cacheKey, reqBody, now, filter)
+			return
 		}
 
-		nextDate := currentDate
-		nextDate = nextDate.AddDate(0, 0, 1)
-		// a bridge should always start with an holiday
-		if currentBridge.DaysCount == 1 && !isCurrentDateHolidays {
-			currentDate = currentDate.AddDate(0, 0, 1)
-			continue
+		computed, err := cachedComputeBridges(ctx, bridgesCache, cacheKey, reqBody, now)
+		if err != nil {
+			writeComputationError(w, err)
+			return
+		}
+		responseBody := make([]bridges.YearBridges, 0, len(computed))
+		for _, yearBridges := range computed {
+			responseBody = append(responseBody, filter(yearBridges))
 		}
+		observeBridgesReturned(responseBody)
 
-		for availableDays > 0 || isHolidays(nextDate) {
-			isNextDateHolidays := isHolidays(nextDate)
+		writeResponse(logger, w, 200, responseBody)
+	}
+}
 
-			if isNextDateHolidays {
-				currentBridge.HolidaysCount++
-			} else {
-				currentBridge.WeekdaysCount++
-				availableDays -= 1
-			}
+// profileRequest applies the settings of the profile and of its organization to the request,
+// returning the profile with its bookings and the leave forced by the closures of the organization.
+func profileRequest(ctx context.Context, store storage.Store, profileID string, reqBody *bridges.BridgesRequest, query url.Values) (storage.Profile, []storage.Booking, error) {
+	profile, err := findProfile(ctx, store, profileID)
+	if err != nil {
+		return storage.Profile{}, nil, err
+	}
+	applyProfile(reqBody, profile, query)
+	years := reqBody.YearsScope
+	if years == 0 {
+		years = defaultYearsScope
+	}
+	now, err := requestNow(*reqBody)
+	if err != nil {
+		return storage.Profile{}, nil, err
+	}
+	committed, organization, err := profileBookings(ctx, store, profile, now.Year()+years)
+	if err != nil {
+		return storage.Profile{}, nil, err
+	}
+	if err := applyOrganization(reqBody, organization, now.Year()); err != nil {
+		return storage.Profile{}, nil, err
+	}
+	return profile, committed, nil
+}
 
-			currentBridge.End = nextDate
-			currentBridge.DaysCount++
-			nextDate = nextDate.AddDate(0, 0, 1)
-			if nextDate.Year() != currentDate.Year() {
-				isHolidays = helpers.HolidaysUtils(nextDate.Year(), daysOffMap, "IT", city)
-			}
+// cachedComputeBridges serves the computation from the cache until the end of the current
+// day of now, since past bridges are filtered out using the current date.
+// The returned slice is shared between requests and must not be modified.
+func cachedComputeBridges(ctx context.Context, bridgesCache *cache.LRU, key string, reqBody bridges.BridgesRequest, now time.Time) ([]bridges.YearBridges, error) {
+	for {
+		value, err := bridgesCache.GetOrCompute(key, nextDayBoundary(now), func() (interface{}, error) {
+			return computeBridges(ctx, reqBody)
+		})
+		// a computation shared with a request whose client went away is started again
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			continue
 		}
-		for isHolidays(currentDate) {
-			currentDate = currentDate.AddDate(0, 0, 1)
+		if err != nil {
+			return nil, err
 		}
+		return value.([]bridges.YearBridges), nil
+	}
+}
 
-		score := getBridgeScore(currentBridge)
-		// the bridge is inserted only if it is longer than daysOff (es: exlude weekend bridges)
-		// and if it is not in the past for more than maxAvailability days
-		currentBridge.Id = fmt.Sprintf("%s-%s", currentBridge.Start.Format("2006-01-02"), currentBridge.End.Format("2006-01-02"))
+var bridgesPlanner = planner.New(planner.WithObserver(observeYearComputation))
 
-		if currentBridge.DaysCount > len(daysOff) {
-			scoreMap[int(score)] = append(scoreMap[int(score)], currentBridge)
-		}
+func computeBridges(ctx context.Context, reqBody bridges.BridgesRequest) ([]bridges.YearBridges, error) {
+	options, err := plannerOptions(ctx, reqBody)
+	if err != nil {
+		return nil, err
 	}
-	topBridges = 0
-	goodBridges = 0
-	for k := range scoreMap {
-		if k > topBridges {
-			goodBridges = topBridges
-			topBridges = k
-		} else {
-			if k > goodBridges {
-				goodBridges = k
-			}
-		}
+	result, err := bridgesPlanner.Plan(ctx, options)
+	if err != nil {
+		return nil, err
 	}
+	return result.Years, nil
+}
 
-	for index := range scoreMap[topBridges] {
-		scoreMap[topBridges][index].IsTop = true
+func plannerOptions(ctx context.Context, reqBody bridges.BridgesRequest) (planner.Options, error) {
+	now, err := requestNow(reqBody)
+	if err != nil {
+		return planner.Options{}, err
 	}
-
-	calculatedBridges = append(scoreMap[topBridges], scoreMap[goodBridges]...)
-
-	return bridges.YearBridges{
-		Years:         []string{strconv.FormatInt(int64(date.Year()), 10)},
-		Bridges:       calculatedBridges,
-		HolidaysCount: 6,
-		WeekdaysCount: 4,
-		DaysCount:     10,
+	if err := checkYearsScope(reqBody.YearsScope); err != nil {
+		return planner.Options{}, err
+	}
+	customHolidays, err := customHolidayDates(ctx, reqBody.CustomHolidays, now.Year(), reqBody.YearsScope)
+	if err != nil {
+		return planner.Options{}, err
+	}
+	return planner.Options{
+		Location:       now.Location(),
+		Country:        reqBody.Country,
+		City:           reqBody.City,
+		DaysOff:        reqBody.DaysOff,
+		LeaveDays:      reqBody.DayOfHolidays,
+		Years:          reqBody.YearsScope,
+		CustomHolidays: customHolidays,
+		Explain:        reqBody.Explain,
+		Overlap:        reqBody.Overlap,
+		Search:         reqBody.Search,
 	}, nil
 }
 
-func getBridgeScore(bridge bridges.Bridge) float32 {
-	if bridge.WeekdaysCount == 0 {
-		return float32(bridge.DaysCount)
+// customHolidayDates returns the dates of the custom holidays from fromYear up to the year
+// after the last planned one, reached by the bridges across the end of the year.
+// A custom holiday is either a single day, as 2021-08-16, or a yearly one, as 08-16.
+// The expansion of the yearly ones stops when ctx is done.
+func customHolidayDates(ctx context.Context, customHolidays []bridges.CustomHolidays, fromYear, years int) ([]civil.Date, error) {
+	dates := []civil.Date{}
+	for _, customHoliday := range customHolidays {
+		if date, err := civil.ParseDate(customHoliday.Date); err == nil {
+			dates = append(dates, date)
+			continue
+		}
+		date, err := time.Parse("01-02", customHoliday.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom holiday date %q: must be YYYY-MM-DD or MM-DD", customHoliday.Date)
+		}
+		for year := fromYear; year <= fromYear+years; year++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			// a yearly 02-29 is skipped in the years it does not exist
+			if yearDate := date.AddDate(year, 0, 0); yearDate.Day() == date.Day() {
+				dates = append(dates, civil.DateOf(yearDate))
+			}
+		}
+	}
+	return dates, nil
+}
+
+func observeBridgesReturned(responseBody []bridges.YearBridges) {
+	count := 0
+	for _, yearBridges := range responseBody {
+		count += len(yearBridges.Bridges)
 	}
-	return (float32(bridge.DaysCount) / (float32(bridge.WeekdaysCount)) * (float32(bridge.DaysCount) / 30.0) * 100)
+	bridgesReturned.Observe(float64(count))
 }
 
 func writeResponse(logger *logrus.Entry, w http.ResponseWriter, statusCode int, response interface{}) {
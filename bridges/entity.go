@@ -1,15 +1,47 @@
 package bridges
 
-import "time"
+import "feriapp-backend-go/civil"
 
 type Bridge struct {
-	Start         time.Time `json:"start" bson:"start"`
-	End           time.Time `json:"end" bson:"end"`
-	HolidaysCount int       `json:"holidaysCount" bson:"holidaysCount"`
-	WeekdaysCount int       `json:"weekdaysCount" bson:"weekdaysCount"`
-	DaysCount     int       `json:"daysCount" bson:"daysCount"`
-	IsTop         bool      `json:"isTop" bson:"isTop"`
-	Id            string    `json:"id" bson:"id"`
+	Start         civil.Date `json:"start" bson:"start"`
+	End           civil.Date `json:"end" bson:"end"`
+	HolidaysCount int        `json:"holidaysCount" bson:"holidaysCount"`
+	WeekdaysCount int        `json:"weekdaysCount" bson:"weekdaysCount"`
+	DaysCount     int        `json:"daysCount" bson:"daysCount"`
+	IsTop         bool       `json:"isTop" bson:"isTop"`
+	Id            string     `json:"id" bson:"id"`
+	// Label describes the bridge in the language of the response, as "Ponte di Ognissanti".
+	Label string `json:"label,omitempty" bson:"label,omitempty"`
+	// Holidays are the holidays within the bridge, named in the language of the response.
+	Holidays []NamedDay `json:"holidays,omitempty" bson:"holidays,omitempty"`
+	// Days is the breakdown of the days of the bridge, when explained.
+	Days []Day `json:"days,omitempty" bson:"days,omitempty"`
+	// Cluster identifies the bridges sharing some days, when overlaps are resolved.
+	Cluster string `json:"cluster,omitempty" bson:"cluster,omitempty"`
+	// Alternatives are the other bridges of the cluster, when overlaps are collapsed.
+	Alternatives []Bridge `json:"alternatives,omitempty" bson:"alternatives,omitempty"`
+}
+
+// Types of the days of a bridge.
+const (
+	DayHoliday = "holiday"
+	DayOff     = "dayOff"
+	DayWorking = "working"
+)
+
+// Day explains a day of a bridge: its type, the name of the holiday falling on it and
+// whether it needs a day of leave, which is the case of working days only.
+type Day struct {
+	Date  civil.Date `json:"date" bson:"date"`
+	Type  string     `json:"type" bson:"type"`
+	Name  string     `json:"name,omitempty" bson:"name,omitempty"`
+	Leave bool       `json:"leave" bson:"leave"`
+}
+
+// NamedDay is a day with a human readable name.
+type NamedDay struct {
+	Date civil.Date `json:"date" bson:"date"`
+	Name string     `json:"name" bson:"name"`
 }
 
 type YearBridges struct {
@@ -28,9 +60,36 @@ type BridgesRequest struct {
 	City           string           `json:"city" bson:"city"`
 	DaysOff        []int            `json:"daysOff" bson:"daysOff"`
 	YearsScope     int              `json:"yearsScope" bson:"yearsScope"`
+	// Lang is the preferred language of the labels, before the ones of the Accept-Language header.
+	Lang string `json:"lang,omitempty" bson:"lang,omitempty"`
+	// Explain adds to every bridge the breakdown of its days.
+	Explain bool `json:"explain,omitempty" bson:"explain,omitempty"`
+	// Overlap is either list or collapse, telling how the bridges sharing some days are returned.
+	Overlap string `json:"overlap,omitempty" bson:"overlap,omitempty"`
+	// Search is window to also consider the bridges starting with leave days, not only with holidays.
+	Search string `json:"search,omitempty" bson:"search,omitempty"`
+	// TimeZone is the IANA name of the time zone of the user, as Europe/Rome, telling which day
+	// is today. UTC when empty.
+	TimeZone string `json:"timeZone,omitempty" bson:"timeZone,omitempty"`
+	// Country is the code of the country whose national holidays are planned around, as GB.
+	// IT when empty.
+	Country string `json:"country,omitempty" bson:"country,omitempty"`
 }
 
 type CustomHolidays struct {
 	Date string `json:"date" bson:"date"`
 	Name string `json:"name" bson:"name"`
 }
+
+// BatchBridgesRequest is an item of a batch request, identified by a client provided id.
+type BatchBridgesRequest struct {
+	Id             string `json:"id" bson:"id"`
+	BridgesRequest `bson:",inline"`
+}
+
+// BatchBridgesResult holds either the bridges or the error of a batch item.
+type BatchBridgesResult struct {
+	Id      string        `json:"id" bson:"id"`
+	Bridges []YearBridges `json:"bridges,omitempty" bson:"bridges,omitempty"`
+	Error   string        `json:"error,omitempty" bson:"error,omitempty"`
+}
@@ -2,24 +2,42 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/i18n"
+	"feriapp-backend-go/storage"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 )
 
+var testEnv = EnvironmentVariables{
+	BatchWorkers:  2,
+	BatchMaxItems: 10,
+}
+
+// testMessages are the message catalogs shipped with the service.
+var testMessages = func() *i18n.Bundle {
+	messages, err := i18n.LoadDir("./i18n/catalogs/", "it")
+	if err != nil {
+		panic(err)
+	}
+	return messages
+}()
+
 func TestBridgesRoutes(testCase *testing.T) {
 	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
 
 	testRouter := mux.NewRouter()
-	setupBridgesRouter(testRouter)
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
 
 	testCase.Run("/bridges - ok", func(t *testing.T) {
 		responseRecorder := httptest.NewRecorder()
@@ -104,102 +122,250 @@ func TestBridgesRoutes(testCase *testing.T) {
 	})
 }
 
-func TestBridgesByYear(testCase *testing.T) {
+func TestGetBridgesRoutes(testCase *testing.T) {
 	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
 
-	testCase.Run("bridgesByYear", func(t *testing.T) {
-		bridgesArray := []bridges.Bridge{
-			{Id: "2019-04-20-2019-04-25", IsTop: true, Start: time.Date(2019, 4, 20, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 4, 25, 0, 0, 0, 0, time.UTC), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
-			{Id: "2019-12-21-2019-12-26", IsTop: true, Start: time.Date(2019, 12, 21, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 12, 26, 0, 0, 0, 0, time.UTC), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
-			{Id: "2019-12-25-2019-12-30", IsTop: true, Start: time.Date(2019, 12, 25, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
-			{Id: "2019-04-25-2019-04-29", IsTop: false, Start: time.Date(2019, 4, 25, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 4, 29, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-			{Id: "2019-04-27-2019-05-01", IsTop: false, Start: time.Date(2019, 4, 27, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-			{Id: "2019-05-01-2019-05-05", IsTop: false, Start: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 5, 5, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-			{Id: "2019-08-15-2019-08-19", IsTop: false, Start: time.Date(2019, 8, 15, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 8, 19, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-			{Id: "2019-11-01-2019-11-05", IsTop: false, Start: time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 11, 5, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-			{Id: "2019-12-28-2020-01-01", IsTop: false, Start: time.Date(2019, 12, 28, 0, 0, 0, 0, time.UTC), End: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
-		}
-		YearBridges := bridges.YearBridges{
-			Years:         []string{"2019"},
-			Bridges:       bridgesArray,
-			HolidaysCount: 6,
-			WeekdaysCount: 4,
-			DaysCount:     10,
-		}
+	testRouter := mux.NewRouter()
+	bridgesCache := cache.New(16)
+	setupBridgesRouter(testRouter, bridgesCache, storage.NewMemory(), testMessages, testEnv)
+
+	testCase.Run("GET /bridges - ok with caching headers", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+
+		request, requestError := http.NewRequest(http.MethodGet, "/bridges?city=Milano&dayOfHolidays=2&daysOff=0,6&yearsScope=2", nil)
+		require.NoError(t, requestError, "Error creating the /bridges request")
 
-		expectedResponse, _ := json.Marshal(YearBridges)
+		testRouter.ServeHTTP(responseRecorder, request)
+		response := responseRecorder.Result()
+		require.Equal(t, http.StatusOK, response.StatusCode, "The response statusCode should be 200")
+		require.NotEmpty(t, response.Header.Get("ETag"))
+		require.Contains(t, response.Header.Get("Cache-Control"), "max-age=")
+		require.NotEmpty(t, response.Header.Get("Vary"))
+
+		body, readBodyError := ioutil.ReadAll(response.Body)
+		require.NoError(t, readBodyError)
+		var actualBridges []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(body, &actualBridges))
+		require.Equal(t, 2, len(actualBridges), "The response body should be the expected one")
+	})
+
+	testCase.Run("GET /bridges - equivalent queries share the ETag and answer 304", func(t *testing.T) {
+		firstRecorder := httptest.NewRecorder()
+		firstRequest, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&dayOfHolidays=2&daysOff=6&daysOff=0", nil)
+		testRouter.ServeHTTP(firstRecorder, firstRequest)
+		etag := firstRecorder.Result().Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		secondRecorder := httptest.NewRecorder()
+		secondRequest, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&dayOfHolidays=2&daysOff=0,6&yearsScope=3", nil)
+		secondRequest.Header.Set("If-None-Match", etag)
+		testRouter.ServeHTTP(secondRecorder, secondRequest)
+
+		response := secondRecorder.Result()
+		require.Equal(t, http.StatusNotModified, response.StatusCode, "The response statusCode should be 304")
+		require.Equal(t, etag, response.Header.Get("ETag"))
+		body, _ := ioutil.ReadAll(response.Body)
+		require.Empty(t, body)
+	})
 
-		result, err := bridgesByYear(
-			time.Date(2019, 4, 19, 0, 0, 0, 0, time.UTC),
-			4,
-			2,
-			"Milano",
-			[]int{0, 6},
-			false,
-		)
+	testCase.Run("GET and POST /bridges share the computation cache", func(t *testing.T) {
+		hits := bridgesCache.Stats().Hits
 
-		require.Equal(t, nil, err)
+		getRecorder := httptest.NewRecorder()
+		getRequest, _ := http.NewRequest(http.MethodGet, "/bridges?city=Roma&dayOfHolidays=1&daysOff=0,6&yearsScope=1", nil)
+		testRouter.ServeHTTP(getRecorder, getRequest)
+		require.Equal(t, http.StatusOK, getRecorder.Result().StatusCode)
 
-		actualResponse, _ := json.Marshal(result)
-		// require.Equal(t, len(bridgesArray), len(result.Bridges), "The 2019 bridges should be %v", len(bridgesArray))
-		require.Equal(t, string(expectedResponse), string(actualResponse), "The 2019 bridges should be correctly calculated")
+		requestBody, _ := json.Marshal(bridges.BridgesRequest{City: "Roma", DayOfHolidays: 1, DaysOff: []int{6, 0}, YearsScope: 1})
+		postRecorder := httptest.NewRecorder()
+		postRequest, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBuffer(requestBody))
+		testRouter.ServeHTTP(postRecorder, postRequest)
+		require.Equal(t, http.StatusOK, postRecorder.Result().StatusCode)
+
+		require.Equal(t, hits+1, bridgesCache.Stats().Hits)
+		getBody, _ := ioutil.ReadAll(getRecorder.Result().Body)
+		postBody, _ := ioutil.ReadAll(postRecorder.Result().Body)
+		require.Equal(t, string(getBody), string(postBody))
 	})
 
-	testCase.Run("bridgesByYear - max availability = 0", func(t *testing.T) {
-		bridgesArray := []bridges.Bridge{
-			{Id: "2019-04-20-2019-04-22", IsTop: true, Start: time.Date(2019, 4, 20, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 4, 22, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 0, DaysCount: 3},
-			{Id: "2019-11-01-2019-11-03", IsTop: true, Start: time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2019, 11, 3, 0, 0, 0, 0, time.UTC), HolidaysCount: 3, WeekdaysCount: 0, DaysCount: 3},
+	testCase.Run("GET /bridges - collapsed overlaps", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&dayOfHolidays=2&daysOff=0,6&yearsScope=2&overlap=collapse", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+
+		var actualBridges []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &actualBridges))
+		for _, yearBridges := range actualBridges {
+			for index, bridge := range yearBridges.Bridges {
+				require.NotEmpty(t, bridge.Cluster)
+				for _, other := range yearBridges.Bridges[index+1:] {
+					require.NotEqual(t, bridge.Cluster, other.Cluster)
+				}
+				for _, alternative := range bridge.Alternatives {
+					require.Equal(t, bridge.Cluster, alternative.Cluster)
+					require.NotEmpty(t, alternative.Label)
+				}
+			}
 		}
-		YearBridges := bridges.YearBridges{
-			Years:         []string{"2019"},
-			Bridges:       bridgesArray,
-			HolidaysCount: 6,
-			WeekdaysCount: 4,
-			DaysCount:     10,
+
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodGet, "/bridges?city=Milano&overlap=merge", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	})
+
+	testCase.Run("GET /bridges - window search", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&dayOfHolidays=2&daysOff=0,6&yearsScope=1&search=window", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var actualBridges []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &actualBridges))
+		for _, bridge := range actualBridges[0].Bridges {
+			require.LessOrEqual(t, bridge.WeekdaysCount, 2, bridge.Id)
 		}
 
-		expectedResponse, _ := json.Marshal(YearBridges)
-
-		result, err := bridgesByYear(
-			time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
-			4,
-			0,
-			"Milano",
-			[]int{0, 6},
-			false,
-		)
-		require.Equal(t, nil, err)
-
-		actualResponse, _ := json.Marshal(result)
-		require.Equal(t, string(expectedResponse), string(actualResponse), "The 2019 bridges should be 2")
-	})
-
-	testCase.Run("bridgesByYear - local city holiday - milano", func(t *testing.T) {
-		expectedBridge := bridges.Bridge{
-			Start:         time.Date(2020, 12, 5, 0, 0, 0, 0, time.UTC),
-			End:           time.Date(2020, 12, 8, 0, 0, 0, 0, time.UTC),
-			HolidaysCount: 3,
-			WeekdaysCount: 0,
-			DaysCount:     3,
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodGet, "/bridges?city=Milano&search=backward", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	})
+
+	testCase.Run("POST /bridges - time zone", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBufferString(`{"city":"Milano","daysOff":[0,6],"yearsScope":1,"timeZone":"Pacific/Kiritimati"}`))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var actualBridges []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &actualBridges))
+		require.Len(t, actualBridges, 1)
+		require.Regexp(t, `^\d{4}-\d{2}-\d{2}$`, actualBridges[0].Bridges[0].Start.String())
+
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodPost, "/bridges", bytes.NewBufferString(`{"city":"Milano","timeZone":"Mars/Olympus"}`))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+		require.Contains(t, responseRecorder.Body.String(), "must be an IANA time zone name")
+	})
+
+	testCase.Run("GET /bridges - cities match regardless of case, accents and aliases", func(t *testing.T) {
+		etags := []string{}
+		for _, city := range []string{"Milano", "milan", "MILANO%20"} {
+			responseRecorder := httptest.NewRecorder()
+			request, _ := http.NewRequest(http.MethodGet, "/bridges?dayOfHolidays=2&city="+city, nil)
+			testRouter.ServeHTTP(responseRecorder, request)
+			require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode, city)
+			etags = append(etags, responseRecorder.Result().Header.Get("ETag"))
 		}
+		require.Equal(t, etags[0], etags[1])
+		require.Equal(t, etags[0], etags[2])
+	})
+
+	testCase.Run("GET /bridges - unknown city", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milna", nil)
 
-		result, err := bridgesByYear(
-			time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
-			4,
-			0,
-			"Milano",
-			[]int{0, 6},
-			false,
-		)
-		require.Equal(t, nil, err)
-		var foundBridge = false
-
-		for _, bridge := range result.Bridges {
-			if bridge.Start.Equal(expectedBridge.Start) && bridge.End.Equal(expectedBridge.End) {
-				foundBridge = true
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+		require.Contains(t, responseRecorder.Body.String(), `unknown city "Milna": did you mean Milano`)
+	})
+
+	testCase.Run("GET /bridges - country", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		// Christmas falls on a weekend at least once in seven years
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?country=gb&dayOfHolidays=1&yearsScope=7", nil)
+		request.Header.Set("Accept-Language", "en-GB")
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var actualBridges []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &actualBridges))
+		names := map[string]bool{}
+		for _, yearBridges := range actualBridges {
+			for _, bridge := range yearBridges.Bridges {
+				for _, holiday := range bridge.Holidays {
+					names[holiday.Name] = true
+				}
 			}
 		}
+		require.True(t, names["Boxing Day"], "the bank holidays of the United Kingdom are planned around")
+		require.False(t, names["Ferragosto"])
+		require.True(t, names["Christmas Day (observed)"] || names["Boxing Day (observed)"], "the substitute days are planned around")
+
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodGet, "/bridges?country=XX", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+		require.Contains(t, responseRecorder.Body.String(), `invalid country "XX": must be one of GB, IT, US`)
+	})
+
+	testCase.Run("GET, POST and batch /bridges - too many years", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&customHolidays=12-24&yearsScope=20000000", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+		require.Contains(t, responseRecorder.Body.String(), "invalid yearsScope value 20000000: must be at most 50")
 
-		require.Equal(t, true, foundBridge, "In 2020 bridges there should be san Ambrogio bridge")
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodPost, "/bridges", bytes.NewBufferString(`{"city":"Milano","customHolidays":[{"date":"12-24"}],"yearsScope":51}`))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBufferString(`[{"id":"a","city":"Milano","yearsScope":51}]`))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var results []bridges.BatchBridgesResult
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &results))
+		require.Equal(t, "invalid yearsScope value 51: must be at most 50", results[0].Error)
+	})
+
+	testCase.Run("GET /bridges - invalid query", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&daysOff=9", nil)
+
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode, "The response statusCode should be 400")
+	})
+}
+
+func TestCustomHolidayDates(testCase *testing.T) {
+	testCase.Run("single and yearly days", func(t *testing.T) {
+		dates, err := customHolidayDates(context.Background(), []bridges.CustomHolidays{{Date: "2021-08-16"}, {Date: "12-24"}}, 2021, 1)
+		require.NoError(t, err)
+		require.Equal(t, []civil.Date{
+			civil.NewDate(2021, 8, 16),
+			civil.NewDate(2021, 12, 24),
+			civil.NewDate(2022, 12, 24),
+		}, dates)
+	})
+
+	testCase.Run("yearly 29th of February only in leap years", func(t *testing.T) {
+		dates, err := customHolidayDates(context.Background(), []bridges.CustomHolidays{{Date: "02-29"}}, 2023, 2)
+		require.NoError(t, err)
+		require.Equal(t, []civil.Date{civil.NewDate(2024, 2, 29)}, dates)
+	})
+
+	testCase.Run("invalid date", func(t *testing.T) {
+		_, err := customHolidayDates(context.Background(), []bridges.CustomHolidays{{Date: "16/08"}}, 2021, 1)
+		require.EqualError(t, err, `invalid custom holiday date "16/08": must be YYYY-MM-DD or MM-DD`)
+	})
+
+	testCase.Run("expansion stops with the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := customHolidayDates(ctx, []bridges.CustomHolidays{{Date: "12-24"}}, 2021, 1)
+		require.Equal(t, context.Canceled, err)
+	})
+
+	testCase.Run("POST /bridges - invalid custom holiday", func(t *testing.T) {
+		testRouter := mux.NewRouter()
+		setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+
+		requestBody, _ := json.Marshal(bridges.BridgesRequest{City: "Milano", CustomHolidays: []bridges.CustomHolidays{{Date: "tomorrow"}}})
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBuffer(requestBody))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
 	})
 }
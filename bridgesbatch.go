@@ -0,0 +1,210 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/glogger"
+)
+
+func createBatchBridges(bridgesCache *cache.LRU, messages *i18n.Bundle, timeout time.Duration, workers int, maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var items []bridges.BatchBridgesRequest
+
+		err := json.NewDecoder(req.Body).Decode(&items)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if maxItems > 0 && len(items) > maxItems {
+			http.Error(w, fmt.Sprintf("too many items: at most %d are allowed", maxItems), http.StatusBadRequest)
+			return
+		}
+
+		prepared := newBatchItems(items)
+		// every item is labelled in its own language, falling back to the Accept-Language header
+		localize := func(index int, result bridges.BatchBridgesResult) bridges.BatchBridgesResult {
+			if result.Bridges == nil {
+				return result
+			}
+			item := prepared[index]
+			filter := localizeFilter(glogger.Get(req.Context()), requestLocalizer(messages, req, item.lang), item.BridgesRequest, unfiltered)
+			localized := make([]bridges.YearBridges, 0, len(result.Bridges))
+			for _, yearBridges := range result.Bridges {
+				localized = append(localized, filter(yearBridges))
+			}
+			result.Bridges = localized
+			return result
+		}
+		w.Header().Add("Vary", "Accept-Language")
+
+		ctx, cancel := withComputationDeadline(req.Context(), timeout)
+		defer cancel()
+		if acceptsNDJSON(req) {
+			stream := newNDJSONStream(w)
+			streamBatchBridges(ctx, bridgesCache, prepared, workers, func(index int, result bridges.BatchBridgesResult) error {
+				return stream.Write(localize(index, result))
+			})
+			return
+		}
+
+		logger := glogger.Get(req.Context())
+
+		results := computeBatchBridges(ctx, bridgesCache, prepared, workers)
+		for index, result := range results {
+			results[index] = localize(index, result)
+		}
+		writeResponse(logger, w, 200, results)
+	}
+}
+
+// batchItem is an item of a batch request normalized once, so that it is labelled with the
+// same request it is computed with.
+type batchItem struct {
+	bridges.BatchBridgesRequest
+	// lang is the language asked by the item, cleared by the normalization
+	lang string
+	// err tells why the city or the country of the item are not valid
+	err error
+}
+
+// newBatchItems normalizes the items and resolves their cities.
+func newBatchItems(items []bridges.BatchBridgesRequest) []batchItem {
+	prepared := make([]batchItem, 0, len(items))
+	for _, item := range items {
+		lang := item.Lang
+		normalizeBridgesRequest(&item.BridgesRequest)
+		err := resolveCity(&item.BridgesRequest)
+		prepared = append(prepared, batchItem{BatchBridgesRequest: item, lang: lang, err: err})
+	}
+	return prepared
+}
+
+// computeBatchBridges computes the items with at most workers concurrent computations.
+// Results keep the order of the items, and a failing item does not affect the others.
+func computeBatchBridges(ctx context.Context, bridgesCache *cache.LRU, items []batchItem, workers int) []bridges.BatchBridgesResult {
+	results := make([]bridges.BatchBridgesResult, len(items))
+	streamBatchBridges(ctx, bridgesCache, items, workers, func(index int, result bridges.BatchBridgesResult) error {
+		results[index] = result
+		return nil
+	})
+	return results
+}
+
+// streamBatchBridges computes the items with at most workers concurrent computations, passing
+// each result to emit as soon as it is ready, from the calling goroutine.
+// No more items are started once emit fails or ctx is done: the items left unprocessed
+// are then reported with the context error.
+func streamBatchBridges(ctx context.Context, bridgesCache *cache.LRU, items []batchItem, workers int, emit func(index int, result bridges.BatchBridgesResult) error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type indexedResult struct {
+		index  int
+		result bridges.BatchBridgesResult
+	}
+	indexes := make(chan int)
+	results := make(chan indexedResult)
+
+	go func() {
+		defer close(indexes)
+		for index := range items {
+			select {
+			case indexes <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results <- indexedResult{index: index, result: computeBatchItem(ctx, bridgesCache, items[index])}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	emitted := make([]bool, len(items))
+	failed := false
+	for indexed := range results {
+		emitted[indexed.index] = true
+		if !failed && emit(indexed.index, indexed.result) != nil {
+			failed = true
+			cancel()
+		}
+	}
+	if failed {
+		return
+	}
+	for index, done := range emitted {
+		if !done {
+			emit(index, bridges.BatchBridgesResult{Id: items[index].Id, Error: ctx.Err().Error()})
+		}
+	}
+}
+
+func computeBatchItem(ctx context.Context, bridgesCache *cache.LRU, item batchItem) bridges.BatchBridgesResult {
+	result := bridges.BatchBridgesResult{Id: item.Id}
+	if item.Id == "" {
+		result.Error = "missing id"
+		return result
+	}
+	if err := ctx.Err(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	reqBody := item.BridgesRequest
+	now, err := requestNow(reqBody)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if item.err != nil {
+		result.Error = item.err.Error()
+		return result
+	}
+	cacheKey := bridgesETag(reqBody, helpers.LanguagePackVersion(reqBody.Country), now)
+	yearBridges, err := cachedComputeBridges(ctx, bridgesCache, cacheKey, reqBody, now)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Bridges = yearBridges
+	return result
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchBridgesRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+
+	testCase.Run("/bridges/batch - per item results and errors", func(t *testing.T) {
+		requestBody, _ := json.Marshal([]bridges.BatchBridgesRequest{
+			{Id: "milano", BridgesRequest: bridges.BridgesRequest{City: "Milano", DayOfHolidays: 2, DaysOff: []int{0, 6}, YearsScope: 1}},
+			{Id: "invalid", BridgesRequest: bridges.BridgesRequest{City: "Roma", DayOfHolidays: 2, DaysOff: []int{9}, YearsScope: 1}},
+			{Id: "roma", BridgesRequest: bridges.BridgesRequest{City: "Roma", DayOfHolidays: 1, DaysOff: []int{0, 6}, YearsScope: 2}},
+			{BridgesRequest: bridges.BridgesRequest{City: "Roma"}},
+			{Id: "unknown", BridgesRequest: bridges.BridgesRequest{City: "Rom", DayOfHolidays: 1}},
+		})
+
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBuffer(requestBody))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode, "The response statusCode should be 200")
+
+		body, _ := ioutil.ReadAll(responseRecorder.Result().Body)
+		var results []bridges.BatchBridgesResult
+		require.NoError(t, json.Unmarshal(body, &results))
+		require.Len(t, results, 5)
+
+		require.Equal(t, "milano", results[0].Id)
+		require.Empty(t, results[0].Error)
+		require.Len(t, results[0].Bridges, 1)
+
+		require.Equal(t, "invalid", results[1].Id)
+		require.Contains(t, results[1].Error, "invalid day off 9")
+		require.Nil(t, results[1].Bridges)
+
+		require.Equal(t, "roma", results[2].Id)
+		require.Len(t, results[2].Bridges, 2)
+
+		require.Equal(t, "missing id", results[3].Error)
+
+		require.Contains(t, results[4].Error, `unknown city "Rom": did you mean Roma`)
+	})
+
+	testCase.Run("/bridges/batch - items are labelled as single requests", func(t *testing.T) {
+		body := `{"city":"milan","dayOfHolidays":2,"daysOff":[0,6],"yearsScope":3}`
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBufferString(body))
+		request.Header.Set("Accept-Language", "it")
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var expected []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &expected))
+
+		responseRecorder = httptest.NewRecorder()
+		request, _ = http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBufferString(`[{"id":"a",`+body[1:]+`]`))
+		request.Header.Set("Accept-Language", "it")
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+		var results []bridges.BatchBridgesResult
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &results))
+		require.Len(t, results, 1)
+		require.Empty(t, results[0].Error)
+		require.Equal(t, expected, results[0].Bridges)
+
+		labels := map[string]bool{}
+		for _, yearBridges := range results[0].Bridges {
+			for _, bridge := range yearBridges.Bridges {
+				labels[bridge.Label] = true
+			}
+		}
+		require.True(t, labels["Ponte di Natale"], "the holidays of the default country are found: %v", labels)
+	})
+
+	testCase.Run("/bridges/batch - too many items", func(t *testing.T) {
+		items := []bridges.BatchBridgesRequest{}
+		for i := 0; i <= testEnv.BatchMaxItems; i++ {
+			items = append(items, bridges.BatchBridgesRequest{Id: fmt.Sprint(i)})
+		}
+		requestBody, _ := json.Marshal(items)
+
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBuffer(requestBody))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode, "The response statusCode should be 400")
+	})
+
+	testCase.Run("/bridges/batch - invalid body", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBufferString(`{"id": "not an array"}`))
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode, "The response statusCode should be 400")
+	})
+}
+
+func TestComputeBatchBridges(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := computeBatchBridges(ctx, cache.New(0), newBatchItems([]bridges.BatchBridgesRequest{{Id: "a"}, {Id: "b"}}), 4)
+	require.Equal(t, []bridges.BatchBridgesResult{
+		{Id: "a", Error: context.Canceled.Error()},
+		{Id: "b", Error: context.Canceled.Error()},
+	}, results)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+var errBridgeNotFound = errors.New("bridge not found")
+
+// explainBridge returns a bridge with the breakdown of its days. The bridge is looked up among
+// the ones computed for the query parameters of GET /bridges, profile included, so that its days
+// are explained with the same settings it was planned with.
+func explainBridge(bridgesCache *cache.LRU, store storage.Store, messages *i18n.Bundle, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		reqBody, err := parseBridgesQuery(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if profileID := query.Get("profile"); profileID != "" {
+			if _, _, err := profileRequest(req.Context(), store, profileID, &reqBody, query); err != nil {
+				writeProfileError(w, req, err)
+				return
+			}
+		}
+		now, err := requestNow(reqBody)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		localizer := requestLocalizer(messages, req, reqBody.Lang)
+		reqBody.Explain = true
+		// alternatives are looked up as well, since they are not collapsed
+		reqBody.Overlap = ""
+		normalizeBridgesRequest(&reqBody)
+		if err := resolveCity(&reqBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cacheKey := bridgesETag(reqBody, helpers.LanguagePackVersion(reqBody.Country), now)
+		setLanguageHeaders(w, localizer)
+		ctx, cancel := withComputationDeadline(req.Context(), timeout)
+		defer cancel()
+		computed, err := cachedComputeBridges(ctx, bridgesCache, cacheKey, reqBody, now)
+		if err != nil {
+			writeComputationError(w, err)
+			return
+		}
+
+		id := mux.Vars(req)["id"]
+		filter := localizeFilter(glogger.Get(req.Context()), localizer, reqBody, unfiltered)
+		for _, yearBridges := range computed {
+			for _, bridge := range yearBridges.Bridges {
+				if bridge.Id == id {
+					explained := filter(bridges.YearBridges{Bridges: []bridges.Bridge{bridge}})
+					writeResponse(glogger.Get(req.Context()), w, http.StatusOK, explained.Bridges[0])
+					return
+				}
+			}
+		}
+		http.Error(w, errBridgeNotFound.Error(), http.StatusNotFound)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainBridge(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+	query := "city=Milano&dayOfHolidays=2&daysOff=0,6&yearsScope=1&lang=en"
+
+	var explained []bridges.YearBridges
+	testCase.Run("GET /bridges - explain the days of every bridge", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges?explain=true&"+query, nil))
+		require.Equal(t, http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &explained))
+
+		for _, bridge := range explained[0].Bridges {
+			require.Len(t, bridge.Days, bridge.DaysCount, bridge.Id)
+			for _, day := range bridge.Days {
+				require.Equal(t, day.Type == bridges.DayWorking, day.Leave, bridge.Id)
+				if day.Type == bridges.DayHoliday {
+					require.NotEmpty(t, day.Name, bridge.Id)
+				}
+			}
+		}
+
+		responseRecorder = httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges?"+query, nil))
+		var plain []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &plain))
+		for _, bridge := range plain[0].Bridges {
+			require.Empty(t, bridge.Days)
+		}
+	})
+
+	testCase.Run("GET /bridges/{id}/explain", func(t *testing.T) {
+		if len(explained) == 0 || len(explained[0].Bridges) == 0 {
+			t.Skip("no bridges left this year")
+		}
+		expected := explained[0].Bridges[0]
+
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges/"+expected.Id+"/explain?"+query, nil))
+		require.Equal(t, http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+		require.Equal(t, "en", responseRecorder.Header().Get("Content-Language"))
+		var bridge bridges.Bridge
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &bridge))
+		require.Equal(t, expected, bridge)
+	})
+
+	testCase.Run("GET /bridges/{id}/explain - unknown bridge", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges/2019-04-20-2019-04-25/explain?"+query, nil))
+		require.Equal(t, http.StatusNotFound, responseRecorder.Code)
+	})
+
+	testCase.Run("invalid explain value", func(t *testing.T) {
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges?explain=maybe&"+query, nil))
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+	})
+}
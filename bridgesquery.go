@@ -0,0 +1,241 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/planner"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultYearsScope = 3
+	// maxYearsScope bounds the years computed by a request, whose holidays are all built
+	// before the computation starts
+	maxYearsScope = 50
+)
+
+// parseBridgesQuery builds a BridgesRequest from the query string of GET /bridges.
+// daysOff and customHolidays accept both repeated keys and comma separated values.
+func parseBridgesQuery(query url.Values) (bridges.BridgesRequest, error) {
+	var reqBody bridges.BridgesRequest
+	var err error
+
+	reqBody.City = query.Get("city")
+	reqBody.Lang = query.Get("lang")
+	reqBody.Overlap = query.Get("overlap")
+	reqBody.Search = query.Get("search")
+	reqBody.TimeZone = query.Get("timeZone")
+	reqBody.Country = query.Get("country")
+	if _, err = timeZoneLocation(reqBody.TimeZone); err != nil {
+		return reqBody, err
+	}
+	if reqBody.DayOfHolidays, err = parseIntParam(query, "dayOfHolidays"); err != nil {
+		return reqBody, err
+	}
+	if reqBody.YearsScope, err = parseIntParam(query, "yearsScope"); err != nil {
+		return reqBody, err
+	}
+	if err = checkYearsScope(reqBody.YearsScope); err != nil {
+		return reqBody, err
+	}
+	if value := query.Get("explain"); value != "" {
+		if reqBody.Explain, err = strconv.ParseBool(value); err != nil {
+			return reqBody, fmt.Errorf("invalid explain value %q: must be true or false", value)
+		}
+	}
+	for _, value := range splitListParam(query["daysOff"]) {
+		dayOff, err := strconv.Atoi(value)
+		if err != nil || dayOff < 0 || dayOff > 6 {
+			return reqBody, fmt.Errorf("invalid daysOff value %q: must be a weekday between 0 and 6", value)
+		}
+		reqBody.DaysOff = append(reqBody.DaysOff, dayOff)
+	}
+	for _, value := range splitListParam(query["customHolidays"]) {
+		reqBody.CustomHolidays = append(reqBody.CustomHolidays, bridges.CustomHolidays{Date: value})
+	}
+
+	return reqBody, nil
+}
+
+func parseIntParam(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s value %q: must be a non negative integer", key, value)
+	}
+	return parsed, nil
+}
+
+// checkYearsScope fails when more than maxYearsScope years are asked.
+func checkYearsScope(years int) error {
+	if years > maxYearsScope {
+		return fmt.Errorf("invalid yearsScope value %d: must be at most %d", years, maxYearsScope)
+	}
+	return nil
+}
+
+// timeZoneLocation returns the location of an IANA time zone name, UTC when the name is empty.
+func timeZoneLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	// Local is the time zone of the server, not of the user
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("invalid timeZone %q: must be an IANA time zone name", name)
+	}
+	return location, nil
+}
+
+// requestNow returns the current time in the time zone of the request, which tells the day
+// the bridges are computed on.
+func requestNow(reqBody bridges.BridgesRequest) (time.Time, error) {
+	location, err := timeZoneLocation(reqBody.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(location), nil
+}
+
+func splitListParam(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// normalizeBridgesRequest applies defaults and a canonical ordering to the request,
+// so that equivalent requests produce the same cache keys.
+func normalizeBridgesRequest(reqBody *bridges.BridgesRequest) {
+	if reqBody.YearsScope == 0 {
+		reqBody.YearsScope = defaultYearsScope
+	}
+	// the language only changes the labels added to the computed bridges
+	reqBody.Lang = ""
+	reqBody.City = strings.TrimSpace(reqBody.City)
+	reqBody.Country = strings.ToUpper(strings.TrimSpace(reqBody.Country))
+	if reqBody.Country == "" {
+		reqBody.Country = planner.DefaultCountry
+	}
+
+	seen := map[int]bool{}
+	daysOff := []int{}
+	for _, dayOff := range reqBody.DaysOff {
+		if !seen[dayOff] {
+			seen[dayOff] = true
+			daysOff = append(daysOff, dayOff)
+		}
+	}
+	sort.Ints(daysOff)
+	reqBody.DaysOff = daysOff
+
+	if reqBody.CustomHolidays == nil {
+		reqBody.CustomHolidays = []bridges.CustomHolidays{}
+	}
+	sort.SliceStable(reqBody.CustomHolidays, func(i, j int) bool {
+		return reqBody.CustomHolidays[i].Date < reqBody.CustomHolidays[j].Date
+	})
+}
+
+// resolveCity replaces the city of the request with its name in the language pack of its
+// country, matched regardless of case and accents or by an alias, so that "milano" and "Milan"
+// share the cached bridges of Milano. An unknown city fails with the cities of similar name,
+// while the city is left as it is when the pack cannot be read or the country has none.
+// An unknown country fails as well. The request must be normalized.
+func resolveCity(reqBody *bridges.BridgesRequest) error {
+	if err := helpers.CheckCountry(reqBody.Country); err != nil {
+		return err
+	}
+	if reqBody.City == "" {
+		return nil
+	}
+	city, err := helpers.FindCity(reqBody.Country, reqBody.City)
+	var unknownCity *helpers.UnknownCityError
+	if errors.As(err, &unknownCity) {
+		return err
+	}
+	if err == nil {
+		reqBody.City = city.City
+	}
+	return nil
+}
+
+// bridgesETag identifies a response by its normalized input, the holidays data version
+// and the day it is computed on, since past bridges are filtered out using the current date
+// in the time zone of the request.
+func bridgesETag(reqBody bridges.BridgesRequest, dataVersion string, now time.Time) string {
+	key, _ := json.Marshal(reqBody)
+	hash := sha256.New()
+	hash.Write(key)
+	hash.Write([]byte(dataVersion))
+	hash.Write([]byte(civil.DateOf(now).String()))
+	return fmt.Sprintf("%q", hex.EncodeToString(hash.Sum(nil)[:16]))
+}
+
+// setCacheHeaders makes the response cacheable until the next day boundary of now,
+// only by the client when the response is private.
+func setCacheHeaders(w http.ResponseWriter, etag string, now time.Time, private bool) {
+	maxAge := int(nextDayBoundary(now).Sub(now).Seconds())
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept, Accept-Encoding, Accept-Language")
+}
+
+// nextDayBoundary returns the next midnight in the location of now.
+func nextDayBoundary(now time.Time) time.Time {
+	return civil.DateOf(now).AddDays(1).In(now.Location())
+}
+
+// etagMatches implements the weak comparison required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
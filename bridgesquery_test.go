@@ -0,0 +1,100 @@
+package main
+
+import (
+	"feriapp-backend-go/bridges"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBridgesQuery(testCase *testing.T) {
+	testCase.Run("repeated and comma separated values", func(t *testing.T) {
+		query, _ := url.ParseQuery("city=Milano&dayOfHolidays=3&yearsScope=1&daysOff=0&daysOff=6,5&customHolidays=12-24")
+
+		reqBody, err := parseBridgesQuery(query)
+		require.NoError(t, err)
+		require.Equal(t, bridges.BridgesRequest{
+			City:           "Milano",
+			DayOfHolidays:  3,
+			YearsScope:     1,
+			DaysOff:        []int{0, 6, 5},
+			CustomHolidays: []bridges.CustomHolidays{{Date: "12-24"}},
+		}, reqBody)
+	})
+
+	testCase.Run("time zone", func(t *testing.T) {
+		query, _ := url.ParseQuery("timeZone=America/New_York")
+		reqBody, err := parseBridgesQuery(query)
+		require.NoError(t, err)
+		require.Equal(t, "America/New_York", reqBody.TimeZone)
+	})
+
+	testCase.Run("invalid values", func(t *testing.T) {
+		for _, rawQuery := range []string{"dayOfHolidays=abc", "yearsScope=-1", "daysOff=7", "daysOff=x", "timeZone=Mars/Olympus", "timeZone=Local"} {
+			query, _ := url.ParseQuery(rawQuery)
+			_, err := parseBridgesQuery(query)
+			require.Error(t, err, rawQuery)
+		}
+	})
+}
+
+func TestNormalizeBridgesRequest(t *testing.T) {
+	reqBody := bridges.BridgesRequest{City: " Milano ", DaysOff: []int{6, 0, 6}}
+
+	normalizeBridgesRequest(&reqBody)
+	require.Equal(t, bridges.BridgesRequest{
+		City:           "Milano",
+		DaysOff:        []int{0, 6},
+		YearsScope:     defaultYearsScope,
+		CustomHolidays: []bridges.CustomHolidays{},
+		Country:        "IT",
+	}, reqBody)
+
+	reqBody = bridges.BridgesRequest{Country: " gb "}
+	normalizeBridgesRequest(&reqBody)
+	require.Equal(t, "GB", reqBody.Country)
+}
+
+func TestBridgesETag(testCase *testing.T) {
+	reqBody := bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}, YearsScope: 3}
+	now := time.Date(2021, 3, 10, 15, 0, 0, 0, time.UTC)
+	etag := bridgesETag(reqBody, "v1", now)
+
+	testCase.Run("stable within the same day", func(t *testing.T) {
+		require.Equal(t, etag, bridgesETag(reqBody, "v1", now.Add(8*time.Hour)))
+	})
+
+	testCase.Run("changes with day, data version and input", func(t *testing.T) {
+		require.NotEqual(t, etag, bridgesETag(reqBody, "v1", now.AddDate(0, 0, 1)))
+		require.NotEqual(t, etag, bridgesETag(reqBody, "v2", now))
+		require.NotEqual(t, etag, bridgesETag(bridges.BridgesRequest{City: "Roma", DaysOff: []int{0, 6}, YearsScope: 3}, "v1", now))
+	})
+
+	testCase.Run("cache headers expire at the day boundary", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		setCacheHeaders(recorder, etag, now, false)
+		require.Equal(t, "public, max-age=32400", recorder.Header().Get("Cache-Control"))
+		require.Equal(t, etag, recorder.Header().Get("ETag"))
+	})
+
+	testCase.Run("day of the time zone of the request", func(t *testing.T) {
+		newYork, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		// 03:00 UTC is still the day before in New York
+		late := time.Date(2021, 3, 11, 3, 0, 0, 0, time.UTC)
+		require.Equal(t, etag, bridgesETag(reqBody, "v1", late.In(newYork)))
+		require.NotEqual(t, etag, bridgesETag(reqBody, "v1", late))
+		require.Equal(t, time.Date(2021, 3, 11, 5, 0, 0, 0, time.UTC), nextDayBoundary(late.In(newYork)).UTC())
+	})
+
+	testCase.Run("If-None-Match comparison", func(t *testing.T) {
+		require.True(t, etagMatches(etag, etag))
+		require.True(t, etagMatches(`"other", W/`+etag, etag))
+		require.True(t, etagMatches("*", etag))
+		require.False(t, etagMatches("", etag))
+		require.False(t, etagMatches(`"other"`, etag))
+	})
+}
@@ -0,0 +1,140 @@
+// Package cache provides a bounded, expiring LRU cache with deduplication
+// of concurrent computations for the same key.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats reports the cache usage counters.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// Shared counts the calls served by a computation started by a concurrent caller.
+	Shared  uint64 `json:"shared"`
+	Entries int    `json:"entries"`
+}
+
+// LRU is a least recently used cache where each entry has its own expiration.
+// A capacity lower or equal to zero disables storage, while still deduplicating
+// concurrent computations in GetOrCompute.
+type LRU struct {
+	// counters are kept first to guarantee 64-bit alignment for atomic operations.
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	shared    uint64
+
+	mutex    sync.Mutex
+	capacity int
+	entries  *list.List
+	items    map[string]*list.Element
+	group    singleflight.Group
+	now      func() time.Time
+}
+
+type entry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// New creates an LRU cache holding at most capacity entries.
+func New(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		entries:  list.New(),
+		items:    map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+// Get returns the value stored for key, if present and not expired.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	cached := element.Value.(*entry)
+	if !c.now().Before(cached.expiresAt) {
+		c.removeElement(element)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	atomic.AddUint64(&c.hits, 1)
+	return cached.value, true
+}
+
+// Set stores value for key until expiresAt, evicting the least recently used entry when full.
+func (c *LRU) Set(key string, value interface{}, expiresAt time.Time) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		cached := element.Value.(*entry)
+		cached.value = value
+		cached.expiresAt = expiresAt
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.entries.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for c.entries.Len() > c.capacity {
+		c.removeElement(c.entries.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// GetOrCompute returns the cached value for key or computes it, making concurrent
+// callers for the same key wait for a single computation. Errors are not cached.
+func (c *LRU) GetOrCompute(key string, expiresAt time.Time, compute func() (interface{}, error)) (interface{}, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	computed := false
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		computed = true
+		value, err := compute()
+		if err == nil {
+			c.Set(key, value, expiresAt)
+		}
+		return value, err
+	})
+	if !computed {
+		atomic.AddUint64(&c.shared, 1)
+	}
+	return value, err
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *LRU) Stats() Stats {
+	c.mutex.Lock()
+	entries := c.entries.Len()
+	c.mutex.Unlock()
+
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Shared:    atomic.LoadUint64(&c.shared),
+		Entries:   entries,
+	}
+}
+
+func (c *LRU) removeElement(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.items, element.Value.(*entry).key)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(testCase *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+
+	testCase.Run("get and set", func(t *testing.T) {
+		lru := New(2)
+		lru.now = func() time.Time { return now }
+
+		_, found := lru.Get("a")
+		require.False(t, found)
+
+		lru.Set("a", 1, expiresAt)
+		value, found := lru.Get("a")
+		require.True(t, found)
+		require.Equal(t, 1, value)
+		require.Equal(t, Stats{Hits: 1, Misses: 1, Entries: 1}, lru.Stats())
+	})
+
+	testCase.Run("evicts the least recently used entry", func(t *testing.T) {
+		lru := New(2)
+		lru.now = func() time.Time { return now }
+
+		lru.Set("a", 1, expiresAt)
+		lru.Set("b", 2, expiresAt)
+		lru.Get("a")
+		lru.Set("c", 3, expiresAt)
+
+		_, found := lru.Get("b")
+		require.False(t, found, "b should have been evicted")
+		_, found = lru.Get("a")
+		require.True(t, found)
+		_, found = lru.Get("c")
+		require.True(t, found)
+		require.Equal(t, uint64(1), lru.Stats().Evictions)
+	})
+
+	testCase.Run("expired entries are not returned", func(t *testing.T) {
+		lru := New(2)
+		currentTime := now
+		lru.now = func() time.Time { return currentTime }
+
+		lru.Set("a", 1, expiresAt)
+		currentTime = expiresAt
+
+		_, found := lru.Get("a")
+		require.False(t, found)
+		require.Equal(t, 0, lru.Stats().Entries)
+	})
+
+	testCase.Run("zero capacity disables storage", func(t *testing.T) {
+		lru := New(0)
+		lru.Set("a", 1, expiresAt)
+
+		_, found := lru.Get("a")
+		require.False(t, found)
+	})
+}
+
+func TestGetOrCompute(testCase *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	testCase.Run("computes once and caches the result", func(t *testing.T) {
+		lru := New(2)
+		var calls int32
+		compute := func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return "value", nil
+		}
+
+		for i := 0; i < 3; i++ {
+			value, err := lru.GetOrCompute("key", expiresAt, compute)
+			require.NoError(t, err)
+			require.Equal(t, "value", value)
+		}
+		require.Equal(t, int32(1), calls)
+	})
+
+	testCase.Run("errors are not cached", func(t *testing.T) {
+		lru := New(2)
+		_, err := lru.GetOrCompute("key", expiresAt, func() (interface{}, error) {
+			return nil, errors.New("failure")
+		})
+		require.Error(t, err)
+
+		value, err := lru.GetOrCompute("key", expiresAt, func() (interface{}, error) {
+			return "value", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "value", value)
+	})
+
+	testCase.Run("deduplicates concurrent computations", func(t *testing.T) {
+		lru := New(0)
+		var calls int32
+		release := make(chan struct{})
+		compute := func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "value", nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := lru.GetOrCompute("key", expiresAt, compute)
+				require.NoError(t, err)
+				require.Equal(t, "value", value)
+			}()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, int32(1), calls)
+		require.Equal(t, uint64(4), lru.Stats().Shared)
+	})
+}
@@ -0,0 +1,174 @@
+// Package calendar represents a period as bitmaps of its holidays and days off, answering in
+// constant time which is the next working or non working day of the period and how far a window
+// of days can be extended with a given number of days of leave.
+package calendar
+
+import (
+	"math/bits"
+	"time"
+
+	"feriapp-backend-go/civil"
+)
+
+// Type tells whether a day is worked.
+type Type uint8
+
+const (
+	// Working days need a day of leave to be away.
+	Working Type = iota
+	// DayOff is a weekday which is not worked.
+	DayOff
+	// Holiday is a day which is not worked, even when it falls on a day off.
+	Holiday
+)
+
+const wordSize = 64
+
+// Range holds the type of the consecutive days of a period, each one identified by its index
+// from the first day. It is immutable, and safe for concurrent use.
+type Range struct {
+	start civil.Date
+	// origin is the midnight UTC starting the first day, from which the days are computed.
+	origin   time.Time
+	length   int
+	holidays []uint64
+	daysOff  []uint64
+	// working holds the indexes of the working days, in order.
+	working []int
+	// rank holds for every index the number of working days before it, plus one for the end.
+	rank []int
+}
+
+// New returns the range of days days starting from start, typed by dayType.
+func New(start civil.Date, days int, dayType func(date civil.Date) Type) *Range {
+	if days < 0 {
+		days = 0
+	}
+	words := (days + wordSize - 1) / wordSize
+	r := &Range{
+		start:    start,
+		origin:   start.In(time.UTC),
+		length:   days,
+		holidays: make([]uint64, words),
+		daysOff:  make([]uint64, words),
+		working:  make([]int, 0, days),
+		rank:     make([]int, days+1),
+	}
+	for index := 0; index < days; index++ {
+		r.rank[index] = len(r.working)
+		switch dayType(r.Date(index)) {
+		case Holiday:
+			r.holidays[index/wordSize] |= 1 << uint(index%wordSize)
+		case DayOff:
+			r.daysOff[index/wordSize] |= 1 << uint(index%wordSize)
+		default:
+			r.working = append(r.working, index)
+		}
+	}
+	r.rank[days] = len(r.working)
+	return r
+}
+
+// Len returns the number of days of the range.
+func (r *Range) Len() int {
+	return r.length
+}
+
+// Date returns the day at index.
+func (r *Range) Date(index int) civil.Date {
+	// days in UTC always last 24 hours
+	return civil.DateOf(r.origin.Add(time.Duration(index) * 24 * time.Hour))
+}
+
+// Index returns the index of date, which may be out of the range.
+func (r *Range) Index(date civil.Date) int {
+	return date.DaysSince(r.start)
+}
+
+// Type returns the type of the day at index, Working when out of the range.
+func (r *Range) Type(index int) Type {
+	if index < 0 || index >= r.length {
+		return Working
+	}
+	word, bit := index/wordSize, uint64(1)<<uint(index%wordSize)
+	switch {
+	case r.holidays[word]&bit != 0:
+		return Holiday
+	case r.daysOff[word]&bit != 0:
+		return DayOff
+	default:
+		return Working
+	}
+}
+
+// Off tells whether the day at index is not worked.
+func (r *Range) Off(index int) bool {
+	return r.Type(index) != Working
+}
+
+// NextWorking returns the index of the first working day from index included, or Len when
+// all the following days are not worked.
+func (r *Range) NextWorking(index int) int {
+	if index < 0 {
+		index = 0
+	}
+	if index >= r.length {
+		return r.length
+	}
+	if next := r.rank[index]; next < len(r.working) {
+		return r.working[next]
+	}
+	return r.length
+}
+
+// NextOff returns the index of the first non working day from index included, or Len when
+// all the following days are worked.
+func (r *Range) NextOff(index int) int {
+	if index < 0 {
+		index = 0
+	}
+	for word := index / wordSize; word < len(r.holidays); word++ {
+		off := r.holidays[word] | r.daysOff[word]
+		if word == index/wordSize {
+			off &^= (1 << uint(index%wordSize)) - 1
+		}
+		if off != 0 {
+			if next := word*wordSize + bits.TrailingZeros64(off); next < r.length {
+				return next
+			}
+			return r.length
+		}
+	}
+	return r.length
+}
+
+// WorkingCount returns the number of working days from start to end included.
+func (r *Range) WorkingCount(start, end int) int {
+	if start < 0 {
+		start = 0
+	}
+	if end >= r.length {
+		end = r.length - 1
+	}
+	if end < start {
+		return 0
+	}
+	return r.rank[end+1] - r.rank[start]
+}
+
+// WindowEnd returns the index of the last day of the window starting at start which spends
+// at most leave working days, extended through the non working days following them.
+// The window ends before start when leave is zero and start is a working day, and at the last
+// day of the range when it runs out of working days.
+func (r *Range) WindowEnd(start, leave int) int {
+	if start < 0 {
+		start = 0
+	}
+	if start >= r.length {
+		return r.length - 1
+	}
+	if next := r.rank[start] + leave; next < len(r.working) {
+		return r.working[next] - 1
+	}
+	return r.length - 1
+}
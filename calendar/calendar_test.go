@@ -0,0 +1,74 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"feriapp-backend-go/civil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRange(testCase *testing.T) {
+	start := civil.NewDate(2021, 12, 20)
+	// from Monday 2021-12-20, with Christmas on Saturday and St. Stephen on Sunday
+	weekends := func(date civil.Date) Type {
+		switch {
+		case date.Month == 12 && (date.Day == 25 || date.Day == 26):
+			return Holiday
+		case date.Month == 1 && date.Day == 1:
+			return Holiday
+		case date.Weekday() == time.Saturday || date.Weekday() == time.Sunday:
+			return DayOff
+		default:
+			return Working
+		}
+	}
+	days := New(start, 70, weekends)
+
+	testCase.Run("types and dates", func(t *testing.T) {
+		require.Equal(t, 70, days.Len())
+		require.Equal(t, Working, days.Type(0))
+		require.Equal(t, Holiday, days.Type(5))
+		require.Equal(t, DayOff, days.Type(13))
+		require.Equal(t, Working, days.Type(-1))
+		require.Equal(t, Working, days.Type(70))
+		require.True(t, days.Off(6))
+		require.Equal(t, civil.NewDate(2022, 1, 1), days.Date(12))
+		require.Equal(t, 12, days.Index(civil.NewDate(2022, 1, 1)))
+		require.Equal(t, -1, days.Index(civil.NewDate(2021, 12, 19)))
+	})
+
+	testCase.Run("next working and non working days", func(t *testing.T) {
+		require.Equal(t, 5, days.NextOff(0))
+		require.Equal(t, 5, days.NextOff(5))
+		require.Equal(t, 12, days.NextOff(7))
+		// across the words of the bitmaps
+		require.Equal(t, 68, days.NextOff(64))
+		require.Equal(t, 70, days.NextOff(70))
+		require.Equal(t, 0, days.NextWorking(0))
+		require.Equal(t, 7, days.NextWorking(5))
+		require.Equal(t, 14, days.NextWorking(12))
+		require.Equal(t, 70, days.NextWorking(69))
+	})
+
+	testCase.Run("windows", func(t *testing.T) {
+		require.Equal(t, 6, days.WindowEnd(5, 0))
+		// Monday 27 and Tuesday 28 of leave
+		require.Equal(t, 8, days.WindowEnd(5, 2))
+		// up to Sunday 2 January, spending the whole week
+		require.Equal(t, 13, days.WindowEnd(5, 5))
+		require.Equal(t, 5, days.WorkingCount(0, 6))
+		require.Equal(t, 5, days.WorkingCount(5, 13))
+		// the working day starting the window is not taken without leave
+		require.Equal(t, -1, days.WindowEnd(0, 0))
+		require.Equal(t, 69, days.WindowEnd(5, 100))
+	})
+
+	testCase.Run("empty", func(t *testing.T) {
+		empty := New(start, 0, weekends)
+		require.Equal(t, 0, empty.NextOff(0))
+		require.Equal(t, 0, empty.NextWorking(0))
+		require.Equal(t, 0, empty.WorkingCount(0, 10))
+	})
+}
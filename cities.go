@@ -0,0 +1,70 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/planner"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+const (
+	defaultCitiesLimit = 10
+	maxCitiesLimit     = 100
+)
+
+func setupCitiesRouter(router *mux.Router) {
+	router.HandleFunc("/cities", searchCities).Methods(http.MethodGet)
+}
+
+// searchCities lists the cities of the language pack matching the q query parameter, for
+// autocompletion, with their patron day. The limit query parameter caps the results and the
+// country one selects the language pack, IT when missing.
+func searchCities(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	country := strings.ToUpper(strings.TrimSpace(query.Get("country")))
+	if country == "" {
+		country = planner.DefaultCountry
+	}
+	if err := helpers.CheckCountry(country); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseIntParam(query, "limit")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultCitiesLimit
+	}
+	if limit > maxCitiesLimit {
+		limit = maxCitiesLimit
+	}
+	cities, err := helpers.SearchCities(country, query.Get("q"), limit)
+	if err != nil {
+		glogger.Get(req.Context()).WithError(err).Error("failed reading the language pack")
+		http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(glogger.Get(req.Context()), w, http.StatusOK, cities)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"feriapp-backend-go/helpers"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCitiesRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupCitiesRouter(testRouter)
+
+	search := func(target string) *httptest.ResponseRecorder {
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return responseRecorder
+	}
+
+	testCase.Run("autocomplete", func(t *testing.T) {
+		responseRecorder := search("/cities?q=Flor")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var found []helpers.Holiday
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &found))
+		require.Len(t, found, 1)
+		require.Equal(t, "Firenze", found[0].City)
+		require.Equal(t, "06-24", found[0].Date)
+		require.Equal(t, []string{"Florence"}, found[0].Aliases)
+	})
+
+	testCase.Run("limit", func(t *testing.T) {
+		var found []helpers.Holiday
+		require.NoError(t, json.Unmarshal(search("/cities").Body.Bytes(), &found))
+		require.Len(t, found, defaultCitiesLimit)
+		require.NoError(t, json.Unmarshal(search("/cities?q=a&limit=2").Body.Bytes(), &found))
+		require.Len(t, found, 2)
+		require.NoError(t, json.Unmarshal(search("/cities?limit=1000").Body.Bytes(), &found))
+		require.Len(t, found, maxCitiesLimit)
+
+		require.Equal(t, http.StatusBadRequest, search("/cities?limit=-1").Code)
+	})
+
+	testCase.Run("country", func(t *testing.T) {
+		responseRecorder := search("/cities?country=us&q=a")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.JSONEq(t, "[]", responseRecorder.Body.String(), "the United States have no language pack")
+
+		require.Equal(t, http.StatusBadRequest, search("/cities?country=../IT").Code)
+	})
+
+	testCase.Run("no matches", func(t *testing.T) {
+		responseRecorder := search("/cities?q=Springfield")
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.JSONEq(t, "[]", responseRecorder.Body.String())
+	})
+}
@@ -0,0 +1,129 @@
+// Package civil provides the days of the calendar, independent of any time zone, so that
+// comparing them and adding days to them never depends on the hour they were taken at.
+package civil
+
+import (
+	"fmt"
+	"time"
+)
+
+const layout = "2006-01-02"
+
+// Date is a day of the Gregorian calendar, as 2021-08-16 is everywhere.
+// The zero value is not a valid day, and is used for unset dates.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// NewDate returns the day of year, month and day, normalized like time.Date does:
+// 2021-12-32 is 2022-01-01.
+func NewDate(year int, month time.Month, day int) Date {
+	return DateOf(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// DateOf returns the day of t in the location of t.
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+// Today returns the current day of now in location, UTC when nil.
+func Today(now time.Time, location *time.Location) Date {
+	if location == nil {
+		location = time.UTC
+	}
+	return DateOf(now.In(location))
+}
+
+// ParseDate parses a day formatted as 2006-01-02.
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// String formats the day as 2006-01-02.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// IsZero tells whether the date is unset.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// In returns the midnight starting the day in location.
+func (d Date) In(location *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, location)
+}
+
+// AddDate returns the day years, months and days after d, normalized like time.Time.AddDate.
+func (d Date) AddDate(years, months, days int) Date {
+	return NewDate(d.Year+years, d.Month+time.Month(months), d.Day+days)
+}
+
+// AddDays returns the day days after d, or before it when days is negative.
+func (d Date) AddDays(days int) Date {
+	return NewDate(d.Year, d.Month, d.Day+days)
+}
+
+// DaysSince returns the number of days from other to d, negative when d is before other.
+func (d Date) DaysSince(other Date) int {
+	return int(d.In(time.UTC).Sub(other.In(time.UTC)).Hours() / 24)
+}
+
+// Before tells whether d is before other.
+func (d Date) Before(other Date) bool {
+	if d.Year != other.Year {
+		return d.Year < other.Year
+	}
+	if d.Month != other.Month {
+		return d.Month < other.Month
+	}
+	return d.Day < other.Day
+}
+
+// After tells whether d is after other.
+func (d Date) After(other Date) bool {
+	return other.Before(d)
+}
+
+// Weekday returns the day of the week of d.
+func (d Date) Weekday() time.Weekday {
+	return d.In(time.UTC).Weekday()
+}
+
+// MarshalText formats the day as 2006-01-02, and the unset day as an empty string.
+func (d Date) MarshalText() ([]byte, error) {
+	if d.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses a day formatted as 2006-01-02, and an empty string as the unset day.
+// Timestamps formatted as RFC 3339, used for the days before this type existed, are accepted
+// as well and taken in their own offset, the zero time.Time being the unset day.
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseDate(string(text))
+	if err != nil {
+		t, timestampErr := time.Parse(time.RFC3339, string(text))
+		if timestampErr != nil {
+			return fmt.Errorf("invalid date %q: must be formatted as YYYY-MM-DD", text)
+		}
+		parsed = Date{}
+		if !t.IsZero() {
+			parsed = DateOf(t)
+		}
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,68 @@
+package civil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDate(testCase *testing.T) {
+	testCase.Run("normalized like time.Date", func(t *testing.T) {
+		require.Equal(t, Date{Year: 2022, Month: time.January, Day: 1}, NewDate(2021, time.December, 32))
+		require.Equal(t, Date{Year: 2024, Month: time.February, Day: 29}, NewDate(2024, time.March, 0))
+	})
+
+	testCase.Run("today depends on the location", func(t *testing.T) {
+		rome, err := time.LoadLocation("Europe/Rome")
+		require.NoError(t, err)
+		now := time.Date(2021, 12, 31, 23, 30, 0, 0, time.UTC)
+		require.Equal(t, NewDate(2021, time.December, 31), Today(now, nil))
+		require.Equal(t, NewDate(2022, time.January, 1), Today(now, rome))
+	})
+
+	testCase.Run("days across daylight saving time", func(t *testing.T) {
+		// the last Sunday of March lasts 23 hours in Rome
+		start := NewDate(2021, time.March, 27)
+		require.Equal(t, NewDate(2021, time.March, 29), start.AddDays(2))
+		require.Equal(t, 2, start.AddDays(2).DaysSince(start))
+		require.Equal(t, -365, NewDate(2020, time.March, 27).DaysSince(start))
+	})
+
+	testCase.Run("comparisons", func(t *testing.T) {
+		first, second := NewDate(2021, time.August, 16), NewDate(2021, time.September, 1)
+		require.True(t, first.Before(second))
+		require.True(t, second.After(first))
+		require.False(t, first.Before(first))
+		require.Equal(t, time.Monday, first.Weekday())
+		require.True(t, Date{}.IsZero())
+	})
+
+	testCase.Run("text encoding", func(t *testing.T) {
+		encoded, err := json.Marshal(NewDate(2021, time.August, 6))
+		require.NoError(t, err)
+		require.Equal(t, `"2021-08-06"`, string(encoded))
+
+		var date Date
+		require.NoError(t, json.Unmarshal(encoded, &date))
+		require.Equal(t, NewDate(2021, time.August, 6), date)
+		// timestamps are taken in their own offset
+		require.NoError(t, json.Unmarshal([]byte(`"2021-08-06T00:00:00+02:00"`), &date))
+		require.Equal(t, NewDate(2021, time.August, 6), date)
+		require.Error(t, json.Unmarshal([]byte(`"06/08/2021"`), &date))
+	})
+
+	testCase.Run("unset day", func(t *testing.T) {
+		encoded, err := json.Marshal(Date{})
+		require.NoError(t, err)
+		require.Equal(t, `""`, string(encoded))
+
+		date := NewDate(2021, time.August, 6)
+		require.NoError(t, json.Unmarshal(encoded, &date))
+		require.True(t, date.IsZero())
+		date = NewDate(2021, time.August, 6)
+		require.NoError(t, json.Unmarshal([]byte(`"0001-01-01T00:00:00Z"`), &date))
+		require.True(t, date.IsZero())
+	})
+}
@@ -0,0 +1,391 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command feriapp computes bridges and holidays without running the HTTP server.
+package main
+
+import (
+	"context"
+	"errors"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
+	"feriapp-backend-go/planner"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: feriapp <command> [flags]
+
+Commands:
+  bridges   compute the best bridges for the next years
+  holidays  list the holidays of a year
+  easter    print the date of Catholic Easter of a year
+  import    import the patron days of the cities from a CSV or iCalendar file
+  lint      validate the language packs of the patron days
+
+Run "feriapp <command> -h" to list the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "bridges":
+		err = runBridges(args[1:], stdout, stderr)
+	case "holidays":
+		err = runHolidays(args[1:], stdout, stderr)
+	case "easter":
+		err = runEaster(args[1:], stdout, stderr)
+	case "import":
+		err = runImport(args[1:], stdout, stderr)
+	case "lint":
+		err = runLint(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", formatTable, "output format: table, json, csv or ical")
+	return flags, format
+}
+
+func addLanguagePackFlag(flags *flag.FlagSet) *string {
+	defaultPath := os.Getenv("LANGUAGE_PACK_FILE_PATH")
+	if defaultPath == "" {
+		defaultPath = "./"
+	}
+	return flags.String("language-pack-path", defaultPath, "directory containing the language packs, with trailing slash")
+}
+
+// addLanguageFlags adds the flags choosing the language of the output and its message catalogs.
+func addLanguageFlags(flags *flag.FlagSet) (*string, *string) {
+	defaultPath := os.Getenv("MESSAGE_CATALOGS_PATH")
+	if defaultPath == "" {
+		defaultPath = "./i18n/catalogs/"
+	}
+	lang := flags.String("lang", "", "language of the names, as en or it; the names of the language pack when empty")
+	catalogsPath := flags.String("catalogs-path", defaultPath, "directory containing the message catalogs")
+	return lang, catalogsPath
+}
+
+// useLanguagePack points the helpers package to the language packs in path.
+func useLanguagePack(path string) error {
+	return os.Setenv("LANGUAGE_PACK_FILE_PATH", path)
+}
+
+// checkCity fails with the cities of similar name when city is missing from the language pack
+// of the country, which would leave out its patron day.
+func checkCity(country string, city string) error {
+	if city == "" || !helpers.HasLanguagePack(country) {
+		return nil
+	}
+	_, err := helpers.FindCity(country, city)
+	var unknownCity *helpers.UnknownCityError
+	if errors.As(err, &unknownCity) {
+		return err
+	}
+	return nil
+}
+
+func runBridges(args []string, stdout, stderr io.Writer) error {
+	flags, format := newFlagSet("bridges", stderr)
+	country := flags.String("country", planner.DefaultCountry, "country code of the holidays: IT, GB or US")
+	city := flags.String("city", "", "city whose patron day is an holiday")
+	days := flags.Int("days", 0, "days of leave available for each bridge")
+	years := flags.Int("years", 3, "number of years to compute, starting from the current one")
+	daysOff := flags.String("days-off", "0,6", "comma separated weekdays off, from 0 (Sunday) to 6 (Saturday)")
+	search := flags.String("search", "", "window to consider leave taken before the holidays too; from the holidays forward when empty")
+	packPath := addLanguagePackFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	*country = strings.ToUpper(strings.TrimSpace(*country))
+	if *days < 0 || *years < 1 {
+		return errors.New("days must not be negative and years must be at least 1")
+	}
+	weekdaysOff, err := parseDaysOff(*daysOff)
+	if err != nil {
+		return err
+	}
+	if err := useLanguagePack(*packPath); err != nil {
+		return err
+	}
+	if err := checkCity(*country, *city); err != nil {
+		return err
+	}
+
+	result, err := planner.Plan(context.Background(), planner.Options{
+		Country:   *country,
+		City:      *city,
+		DaysOff:   weekdaysOff,
+		LeaveDays: *days,
+		Years:     *years,
+		Search:    *search,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeReport(stdout, *format, bridgesReport(result.Years))
+}
+
+func runHolidays(args []string, stdout, stderr io.Writer) error {
+	flags, format := newFlagSet("holidays", stderr)
+	country := flags.String("country", "IT", "country code of the holidays: IT, GB or US")
+	year := flags.Int("year", time.Now().Year(), "year of the holidays")
+	city := flags.String("city", "", "city whose patron day should be listed")
+	packPath := addLanguagePackFlag(flags)
+	lang, catalogsPath := addLanguageFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	*country = strings.ToUpper(strings.TrimSpace(*country))
+	if err := helpers.CheckCountry(*country); err != nil {
+		return err
+	}
+	if err := useLanguagePack(*packPath); err != nil {
+		return err
+	}
+	if err := checkCity(*country, *city); err != nil {
+		return err
+	}
+
+	holidays, err := helpers.LoadHolidays(*year, *country, *city)
+	if err != nil {
+		return err
+	}
+	if *lang != "" {
+		// names missing from the catalogs of lang fall back to Italian, the language of the pack
+		messages, err := i18n.LoadDir(*catalogsPath, "it")
+		if err != nil {
+			return err
+		}
+		localizer := messages.Localizer(*lang)
+		for index, holiday := range holidays {
+			holidays[index].Name = localizer.HolidayName(holiday)
+		}
+	}
+	return writeReport(stdout, *format, holidaysReport(holidays))
+}
+
+func runEaster(args []string, stdout, stderr io.Writer) error {
+	flags, format := newFlagSet("easter", stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: feriapp easter [flags] <year>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing year")
+	}
+	year, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid year %q", flags.Arg(0))
+	}
+	// flags are accepted after the year too
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return err
+	}
+
+	easter, err := helpers.CatholicByYear(year)
+	if err != nil {
+		return err
+	}
+	return writeReport(stdout, *format, easterReport(year, easter))
+}
+
+func runImport(args []string, stdout, stderr io.Writer) error {
+	flags, format := newFlagSet("import", stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: feriapp import [flags] <file>")
+		flags.PrintDefaults()
+	}
+	locale := flags.String("locale", "IT", "locale of the language pack to import into")
+	inputFormat := flags.String("input-format", "", "format of the file, csv or ics; taken from its extension when empty")
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing the language pack")
+	replace := flags.Bool("replace", false, "remove the cities missing from the file")
+	packPath := addLanguagePackFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("missing file to import")
+	}
+	fileName := flags.Arg(0)
+	if *inputFormat == "" {
+		*inputFormat = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+	}
+	if !helpers.HasLanguagePack(*locale) {
+		return fmt.Errorf("locale %q has no language pack of patron days", *locale)
+	}
+	if err := useLanguagePack(*packPath); err != nil {
+		return err
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	holidays, err := helpers.ParseHolidays(file, *inputFormat)
+	if err != nil {
+		return err
+	}
+	current, err := helpers.LoadLanguagePack(*locale)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	merged, diff := helpers.MergeLanguagePack(current, holidays, *replace)
+	warnings, err := helpers.ValidateHolidays(*locale, merged)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(stderr, "warning: %s\n", warning)
+	}
+	if !*dryRun {
+		if err := helpers.WriteLanguagePack(*locale, merged); err != nil {
+			return err
+		}
+	}
+	return writeReport(stdout, *format, packDiffReport(diff))
+}
+
+func runLint(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: feriapp lint [flags] [file]")
+		flags.PrintDefaults()
+	}
+	locale := flags.String("locale", "", "locale of the language pack to validate; all of them when empty")
+	schema := flags.Bool("schema", false, "print the JSON Schema of the language packs")
+	packPath := addLanguagePackFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *schema {
+		_, err := fmt.Fprint(stdout, helpers.LanguagePackSchema)
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("too many files to validate")
+	}
+	locales := helpers.LanguagePackLocales()
+	if *locale != "" {
+		if !helpers.HasLanguagePack(*locale) {
+			return fmt.Errorf("locale %q has no language pack of patron days", *locale)
+		}
+		locales = []string{*locale}
+	}
+
+	if flags.NArg() == 1 {
+		if len(locales) != 1 {
+			return errors.New("missing locale of the file to validate")
+		}
+		content, err := ioutil.ReadFile(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		warnings, err := helpers.ValidateLanguagePack(locales[0], content)
+		return lintResult(stdout, flags.Arg(0), warnings, err)
+	}
+	if err := useLanguagePack(*packPath); err != nil {
+		return err
+	}
+	invalid := 0
+	for _, locale := range locales {
+		warnings, err := helpers.CheckLanguagePack(locale)
+		if err := lintResult(stdout, locale, warnings, err); err != nil {
+			fmt.Fprintln(stderr, err)
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d language packs are invalid", invalid, len(locales))
+	}
+	return nil
+}
+
+// lintResult prints the warnings and the problems found in the language pack named name,
+// one per line. Warnings alone leave the pack valid.
+func lintResult(w io.Writer, name string, warnings []string, err error) error {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s: warning: %s\n", name, warning)
+	}
+	var packError *helpers.PackError
+	if !errors.As(err, &packError) {
+		if err == nil {
+			fmt.Fprintf(w, "%s: ok\n", name)
+		}
+		return err
+	}
+	for _, problem := range packError.Problems {
+		fmt.Fprintf(w, "%s: %s\n", name, problem)
+	}
+	return fmt.Errorf("%s: %d problems found", name, len(packError.Problems))
+}
+
+func parseDaysOff(value string) ([]int, error) {
+	daysOff := []int{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		dayOff, err := strconv.Atoi(item)
+		if err != nil || dayOff < 0 || dayOff > 6 {
+			return nil, fmt.Errorf("invalid day off %q: must be a weekday between 0 and 6", item)
+		}
+		daysOff = append(daysOff, dayOff)
+	}
+	return daysOff, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testLanguagePackPath = "../../helpers/"
+
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(testCase *testing.T) {
+	testCase.Run("usage without command", func(t *testing.T) {
+		code, _, stderr := runCommand()
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, "Usage: feriapp")
+	})
+
+	testCase.Run("unknown command", func(t *testing.T) {
+		code, _, stderr := runCommand("unknown")
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, `unknown command "unknown"`)
+	})
+
+	testCase.Run("unknown format", func(t *testing.T) {
+		code, _, stderr := runCommand("easter", "2030", "--format", "xml")
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `unknown format "xml"`)
+	})
+}
+
+func TestEasterCommand(testCase *testing.T) {
+	testCase.Run("table", func(t *testing.T) {
+		code, stdout, _ := runCommand("easter", "2030")
+		require.Equal(t, 0, code)
+		require.Equal(t, "YEAR  DATE\n2030  2030-04-21\n", stdout)
+	})
+
+	testCase.Run("json with flags before the year", func(t *testing.T) {
+		code, stdout, _ := runCommand("easter", "--format", "json", "2019")
+		require.Equal(t, 0, code)
+		require.JSONEq(t, `{"year":2019,"date":"2019-04-21"}`, stdout)
+	})
+
+	testCase.Run("ical", func(t *testing.T) {
+		code, stdout, _ := runCommand("easter", "2030", "--format", "ical")
+		require.Equal(t, 0, code)
+		require.Contains(t, stdout, "BEGIN:VCALENDAR\r\n")
+		require.Contains(t, stdout, "DTSTART;VALUE=DATE:20300421\r\n")
+		require.Contains(t, stdout, "DTEND;VALUE=DATE:20300422\r\n")
+		require.Contains(t, stdout, "SUMMARY:Easter\r\n")
+	})
+
+	testCase.Run("invalid year", func(t *testing.T) {
+		code, _, stderr := runCommand("easter", "next")
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `invalid year "next"`)
+	})
+}
+
+func TestHolidaysCommand(testCase *testing.T) {
+	testCase.Run("csv sorted by date", func(t *testing.T) {
+		code, stdout, _ := runCommand("holidays", "--country", "IT", "--year", "2027", "--city", "Milano", "--format", "csv", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 14)
+		require.Equal(t, []string{"date", "weekday", "name"}, records[0])
+		require.Equal(t, []string{"2027-01-01", "Friday", "Capodanno"}, records[1])
+		require.Equal(t, []string{"2027-12-07", "Tuesday", "Sant'Ambrogio"}, records[10])
+	})
+
+	testCase.Run("json", func(t *testing.T) {
+		code, stdout, _ := runCommand("holidays", "--year", "2027", "--format", "json", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		var holidays []helpers.HolidayDate
+		require.NoError(t, json.Unmarshal([]byte(stdout), &holidays))
+		require.Len(t, holidays, 12)
+	})
+
+	testCase.Run("translated", func(t *testing.T) {
+		code, stdout, _ := runCommand("holidays", "--year", "2027", "--city", "Milano", "--format", "csv", "--lang", "en-GB", "--catalogs-path", "../../i18n/catalogs/", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, []string{"2027-01-01", "Friday", "New Year's Day"}, records[1])
+		require.Equal(t, []string{"2027-12-07", "Tuesday", "Sant'Ambrogio (patron saint's day)"}, records[10])
+	})
+
+	testCase.Run("observed days", func(t *testing.T) {
+		code, stdout, _ := runCommand("holidays", "--country", "GB", "--year", "2027", "--format", "csv", "--lang", "en", "--catalogs-path", "../../i18n/catalogs/", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 11)
+		// Christmas falls on Saturday and Boxing Day on Sunday
+		require.Equal(t, []string{"2027-12-27", "Monday", "Christmas Day (observed)"}, records[9])
+		require.Equal(t, []string{"2027-12-28", "Tuesday", "Boxing Day (observed)"}, records[10])
+	})
+
+	testCase.Run("city alias", func(t *testing.T) {
+		code, stdout, _ := runCommand("holidays", "--year", "2027", "--city", "turin", "--format", "csv", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+		require.Contains(t, stdout, "2027-06-24,Thursday,San Giovanni Battista")
+	})
+
+	testCase.Run("unknown city", func(t *testing.T) {
+		code, _, stderr := runCommand("holidays", "--city", "Torinoo", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `unknown city "Torinoo": did you mean Torino?`)
+	})
+
+	testCase.Run("unknown country", func(t *testing.T) {
+		code, _, stderr := runCommand("holidays", "--country", "XX", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `invalid country "XX": must be one of GB, IT, US`)
+	})
+
+	testCase.Run("lower case country", func(t *testing.T) {
+		code, lower, stderr := runCommand("holidays", "--country", "gb", "--year", "2027", "--format", "csv", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code, stderr)
+		_, upper, _ := runCommand("holidays", "--country", "GB", "--year", "2027", "--format", "csv", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, upper, lower)
+	})
+}
+
+func TestBridgesCommand(testCase *testing.T) {
+	testCase.Run("json", func(t *testing.T) {
+		code, stdout, _ := runCommand("bridges", "--city", "Milano", "--days", "3", "--years", "2", "--format", "json", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		var result []bridges.YearBridges
+		require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+		require.Len(t, result, 2)
+		require.NotEmpty(t, result[1].Bridges)
+		for _, bridge := range result[1].Bridges {
+			require.True(t, bridge.WeekdaysCount <= 3)
+		}
+	})
+
+	testCase.Run("country", func(t *testing.T) {
+		code, stdout, _ := runCommand("bridges", "--country", "US", "--days", "1", "--years", "2", "--format", "json", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code)
+
+		var result []bridges.YearBridges
+		require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+		require.Len(t, result, 2)
+		// Thanksgiving of next year, always on Thursday, is joined to the weekend by a day of leave
+		thanksgiving := helpers.NthWeekday(time.Now().Year()+1, time.November, time.Thursday, 4)
+		found := false
+		for _, bridge := range result[1].Bridges {
+			found = found || bridge.Start == thanksgiving
+		}
+		require.True(t, found)
+	})
+
+	testCase.Run("lower case and unknown countries", func(t *testing.T) {
+		code, lower, stderr := runCommand("bridges", "--country", "it", "--city", "Milano", "--days", "1", "--years", "1", "--format", "json", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code, stderr)
+		_, upper, _ := runCommand("bridges", "--country", "IT", "--city", "Milano", "--days", "1", "--years", "1", "--format", "json", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, upper, lower)
+
+		code, _, stderr = runCommand("bridges", "--country", "XX", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `invalid country "XX": must be one of GB, IT, US`)
+	})
+
+	testCase.Run("invalid days off", func(t *testing.T) {
+		code, _, stderr := runCommand("bridges", "--days-off", "0,8")
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `invalid day off "8"`)
+	})
+}
+
+func TestImportCommand(testCase *testing.T) {
+	dir, err := ioutil.TempDir("", "feriapp-import")
+	require.NoError(testCase, err)
+	defer os.RemoveAll(dir)
+	pack, err := ioutil.ReadFile(filepath.Join(testLanguagePackPath, "IT.json"))
+	require.NoError(testCase, err)
+	require.NoError(testCase, ioutil.WriteFile(filepath.Join(dir, "IT.json"), pack, 0600))
+	packPath := dir + string(filepath.Separator)
+
+	calendar := filepath.Join(dir, "patrons.ics")
+	require.NoError(testCase, ioutil.WriteFile(calendar, []byte(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20210610",
+		"SUMMARY:San Pantaleone",
+		"LOCATION:Crema",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")), 0600))
+
+	testCase.Run("dry run", func(t *testing.T) {
+		code, stdout, stderr := runCommand("import", "--dry-run", "--format", "csv", "--language-pack-path", packPath, calendar)
+		require.Equal(t, 0, code, stderr)
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			{"change", "city", "before", "after"},
+			{"added", "Crema", "", "06-10, San Pantaleone"},
+		}, records)
+		require.Contains(t, stderr, `(Asti): date "1st tuesday in May" is not a fixed MM-DD day`, "the warnings of the pack are printed")
+
+		current, err := ioutil.ReadFile(filepath.Join(dir, "IT.json"))
+		require.NoError(t, err)
+		require.Equal(t, pack, current)
+	})
+
+	testCase.Run("import", func(t *testing.T) {
+		code, _, stderr := runCommand("import", "--language-pack-path", packPath, calendar)
+		require.Equal(t, 0, code, stderr)
+
+		code, stdout, _ := runCommand("holidays", "--year", "2030", "--city", "Crema", "--format", "csv", "--language-pack-path", packPath)
+		require.Equal(t, 0, code)
+		require.Contains(t, stdout, "2030-06-10,Monday,San Pantaleone")
+	})
+
+	testCase.Run("invalid file", func(t *testing.T) {
+		invalid := filepath.Join(dir, "patrons.csv")
+		require.NoError(t, ioutil.WriteFile(invalid, []byte("city,name,date\nCrema,San Pantaleone,13-10\n"), 0600))
+		code, _, stderr := runCommand("import", "--language-pack-path", packPath, invalid)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `line 2: invalid date "13-10"`)
+
+		code, _, stderr = runCommand("import", "--language-pack-path", packPath, filepath.Join(dir, "patrons.xlsx"))
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "no such file")
+	})
+}
+
+func TestLintCommand(testCase *testing.T) {
+	testCase.Run("shipped pack", func(t *testing.T) {
+		code, stdout, stderr := runCommand("lint", "--language-pack-path", testLanguagePackPath)
+		require.Equal(t, 0, code, stderr)
+		require.Contains(t, stdout, `IT: warning: entry 7 (Asti): date "1st tuesday in May" is not a fixed MM-DD day: the patron day is left out`+"\n")
+		require.True(t, strings.HasSuffix(stdout, "\nIT: ok\n"), stdout)
+	})
+
+	testCase.Run("invalid file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "feriapp-lint")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		invalid := filepath.Join(dir, "IT.json")
+		require.NoError(t, ioutil.WriteFile(invalid, []byte(`[
+			{"city": "Crema", "name": "San Pantaleone", "date": "02-30", "region": "Lombardia", "province": "CR"},
+			{"city": "Lodi", "name": "San Bassiano", "date": "01-19", "region": "Lombardia", "province": "XX"}
+		]`), 0600))
+
+		code, stdout, stderr := runCommand("lint", "--locale", "IT", invalid)
+		require.Equal(t, 1, code)
+		require.Equal(t, invalid+`: warning: entry 2 (Lodi): unknown province "XX"`+"\n"+
+			invalid+`: entry 1 (Crema): invalid date "02-30": must be formatted as MM-DD or YYYY-MM-DD`+"\n", stdout)
+		require.Contains(t, stderr, "1 problems found")
+
+		code, _, stderr = runCommand("lint", "--language-pack-path", dir+string(filepath.Separator))
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "1 of 1 language packs are invalid")
+
+		code, _, stderr = runCommand("lint", "--locale", "GB")
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, "has no language pack")
+	})
+
+	testCase.Run("schema", func(t *testing.T) {
+		code, stdout, _ := runCommand("lint", "--schema")
+		require.Equal(t, 0, code)
+		require.True(t, json.Valid([]byte(stdout)))
+		require.Contains(t, stdout, `"required": ["city", "name", "date"]`)
+	})
+}
+
+func TestWriteICal(t *testing.T) {
+	var output bytes.Buffer
+	day := civil.NewDate(2027, 12, 7)
+
+	err := writeICal(&output, []calendarEvent{{uid: "id", start: day, end: day.AddDays(1), summary: "Ponte; lungo, bello"}}, day.In(time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//feriapp//feriapp-backend-go//IT",
+		"CALSCALE:GREGORIAN",
+		"BEGIN:VEVENT",
+		"UID:id@feriapp",
+		"DTSTAMP:20271207T000000Z",
+		"DTSTART;VALUE=DATE:20271207",
+		"DTEND;VALUE=DATE:20271209",
+		`SUMMARY:Ponte\; lungo\, bello`,
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")+"\r\n", output.String())
+}
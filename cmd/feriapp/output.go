@@ -0,0 +1,219 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatICal  = "ical"
+
+	dateLayout = "2006-01-02"
+)
+
+// report holds the same result in the shapes needed by every output format.
+type report struct {
+	data    interface{}
+	headers []string
+	rows    [][]string
+	events  []calendarEvent
+}
+
+type calendarEvent struct {
+	uid     string
+	start   civil.Date
+	end     civil.Date
+	summary string
+}
+
+func writeReport(w io.Writer, format string, r report) error {
+	switch format {
+	case formatTable:
+		return writeTable(w, r)
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r.data)
+	case formatCSV:
+		return writeCSV(w, r)
+	case formatICal:
+		return writeICal(w, r.events, time.Now().UTC())
+	default:
+		return fmt.Errorf("unknown format %q: use table, json, csv or ical", format)
+	}
+}
+
+func writeTable(w io.Writer, r report) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, strings.ToUpper(strings.Join(r.headers, "\t")))
+	for _, row := range r.rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	return table.Flush()
+}
+
+func writeCSV(w io.Writer, r report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(r.headers); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(r.rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// writeICal writes the events as all-day VEVENTs of an RFC 5545 calendar.
+func writeICal(w io.Writer, events []calendarEvent, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//feriapp//feriapp-backend-go//IT",
+		"CALSCALE:GREGORIAN",
+	}
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%s@feriapp", event.uid),
+			fmt.Sprintf("DTSTAMP:%s", now.Format("20060102T150405Z")),
+			fmt.Sprintf("DTSTART;VALUE=DATE:%s", icalDate(event.start)),
+			// DTEND is exclusive for all-day events
+			fmt.Sprintf("DTEND;VALUE=DATE:%s", icalDate(event.end.AddDays(1))),
+			fmt.Sprintf("SUMMARY:%s", escapeICalText(event.summary)),
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	_, err := io.WriteString(w, strings.Join(lines, "\r\n")+"\r\n")
+	return err
+}
+
+func icalDate(date civil.Date) string {
+	return fmt.Sprintf("%04d%02d%02d", date.Year, date.Month, date.Day)
+}
+
+func escapeICalText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+func bridgesReport(result []bridges.YearBridges) report {
+	r := report{
+		data:    result,
+		headers: []string{"year", "start", "end", "days", "leave", "holidays", "top"},
+	}
+	for _, yearBridges := range result {
+		year := strings.Join(yearBridges.Years, ",")
+		for _, bridge := range yearBridges.Bridges {
+			r.rows = append(r.rows, []string{
+				year,
+				bridge.Start.String(),
+				bridge.End.String(),
+				strconv.Itoa(bridge.DaysCount),
+				strconv.Itoa(bridge.WeekdaysCount),
+				strconv.Itoa(bridge.HolidaysCount),
+				strconv.FormatBool(bridge.IsTop),
+			})
+			r.events = append(r.events, calendarEvent{
+				uid:     bridge.Id,
+				start:   bridge.Start,
+				end:     bridge.End,
+				summary: fmt.Sprintf("Bridge: %d days off with %d days of leave", bridge.DaysCount, bridge.WeekdaysCount),
+			})
+		}
+	}
+	return r
+}
+
+func holidaysReport(holidays []helpers.HolidayDate) report {
+	sort.SliceStable(holidays, func(i, j int) bool {
+		return holidays[i].Date.Before(holidays[j].Date)
+	})
+	r := report{
+		data:    holidays,
+		headers: []string{"date", "weekday", "name"},
+	}
+	for _, holiday := range holidays {
+		r.rows = append(r.rows, []string{holiday.Date.String(), holiday.Date.Weekday().String(), holiday.Name})
+		r.events = append(r.events, calendarEvent{
+			uid:     fmt.Sprintf("holiday-%s", holiday.Date),
+			start:   holiday.Date,
+			end:     holiday.Date,
+			summary: holiday.Name,
+		})
+	}
+	return r
+}
+
+func easterReport(year int, easter time.Time) report {
+	return report{
+		data: struct {
+			Year int    `json:"year"`
+			Date string `json:"date"`
+		}{Year: year, Date: easter.Format(dateLayout)},
+		headers: []string{"year", "date"},
+		rows:    [][]string{{strconv.Itoa(year), easter.Format(dateLayout)}},
+		events: []calendarEvent{{
+			uid:     fmt.Sprintf("easter-%d", year),
+			start:   civil.DateOf(easter),
+			end:     civil.DateOf(easter),
+			summary: "Easter",
+		}},
+	}
+}
+
+func packDiffReport(diff helpers.PackDiff) report {
+	r := report{
+		data:    diff,
+		headers: []string{"change", "city", "before", "after"},
+	}
+	describe := func(holiday helpers.Holiday) string {
+		fields := []string{}
+		for _, field := range []string{holiday.Date, holiday.Name, holiday.Region, holiday.Province} {
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+		return strings.Join(fields, ", ")
+	}
+	for _, holiday := range diff.Added {
+		r.rows = append(r.rows, []string{"added", holiday.City, "", describe(holiday)})
+	}
+	for _, change := range diff.Changed {
+		r.rows = append(r.rows, []string{"changed", change.After.City, describe(change.Before), describe(change.After)})
+	}
+	for _, holiday := range diff.Removed {
+		r.rows = append(r.rows, []string{"removed", holiday.City, describe(holiday), ""})
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	errComputationTimeout  = errors.New("bridges computation exceeded the configured deadline")
+	errComputationCanceled = errors.New("bridges computation canceled")
+)
+
+// withComputationDeadline bounds the computations of a request to timeout.
+// A timeout lower or equal to zero disables the deadline.
+func withComputationDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// computationErrorStatus maps the errors of a computation to the response status code:
+// exceeded deadlines are a 504, cancellations a 503 and anything else an invalid request.
+func computationErrorStatus(err error) (int, error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errComputationTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, errComputationCanceled
+	default:
+		return http.StatusBadRequest, err
+	}
+}
+
+func writeComputationError(w http.ResponseWriter, err error) {
+	statusCode, err := computationErrorStatus(err)
+	http.Error(w, err.Error(), statusCode)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputationErrorStatus(testCase *testing.T) {
+	testCase.Run("deadline exceeded", func(t *testing.T) {
+		statusCode, err := computationErrorStatus(context.DeadlineExceeded)
+		require.Equal(t, http.StatusGatewayTimeout, statusCode)
+		require.Equal(t, errComputationTimeout, err)
+	})
+
+	testCase.Run("canceled", func(t *testing.T) {
+		statusCode, err := computationErrorStatus(context.Canceled)
+		require.Equal(t, http.StatusServiceUnavailable, statusCode)
+		require.Equal(t, errComputationCanceled, err)
+	})
+
+	testCase.Run("invalid request", func(t *testing.T) {
+		invalid := errors.New("invalid leave days")
+		statusCode, err := computationErrorStatus(invalid)
+		require.Equal(t, http.StatusBadRequest, statusCode)
+		require.Equal(t, invalid, err)
+	})
+}
+
+func TestWithComputationDeadline(testCase *testing.T) {
+	testCase.Run("no deadline when disabled", func(t *testing.T) {
+		ctx, cancel := withComputationDeadline(context.Background(), 0)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+	})
+
+	testCase.Run("deadline when configured", func(t *testing.T) {
+		ctx, cancel := withComputationDeadline(context.Background(), time.Second)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+	})
+}
+
+func TestBridgesDeadline(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+
+	testCase.Run("504 when the deadline is exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/bridges?city=Milano&yearsScope=50", nil).WithContext(ctx)
+
+		testRouter.ServeHTTP(responseRecorder, request)
+
+		require.Equal(t, http.StatusGatewayTimeout, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), errComputationTimeout.Error())
+	})
+
+	testCase.Run("503 when the request is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/bridges?city=Milano&yearsScope=50", nil).WithContext(ctx)
+
+		testRouter.ServeHTTP(responseRecorder, request)
+
+		require.Equal(t, http.StatusServiceUnavailable, responseRecorder.Code)
+	})
+
+	testCase.Run("stream reports the deadline before writing", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/bridges?city=Milano&yearsScope=49", nil).WithContext(ctx)
+		request.Header.Set("Accept", ndjsonContentType)
+
+		testRouter.ServeHTTP(responseRecorder, request)
+
+		require.Equal(t, http.StatusGatewayTimeout, responseRecorder.Code)
+	})
+}
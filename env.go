@@ -29,6 +29,32 @@ type EnvironmentVariables struct {
 	ServiceVersion       string
 	DelayShutdownSeconds int
 	LanguagePackFilePath string
+	MessageCatalogsPath  string
+	DefaultLanguage      string
+	BridgesCacheSize     int
+	TracingExporter      string
+	TracingSampleRatio   float64
+	OTLPEndpoint         string
+	OTLPInsecure         bool
+	BatchWorkers         int
+	BatchMaxItems        int
+	ComputationTimeoutMs int
+
+	RateLimitRequestsPerSecond float64
+	RateLimitBurst             int
+	RateLimitTrustForwardedFor bool
+	MaxRequestBodyBytes        int
+
+	AuthRequired        bool
+	AuthAPIKeysFilePath string
+	AuthJWTSecret       string
+	AuthJWKSFilePath    string
+	AuthJWTIssuer       string
+	AuthJWTAudience     string
+	AdminSubjects       string
+
+	StorageBackend  string
+	StorageFilePath string
 }
 
 var envVariablesConfig = []configlib.EnvConfig{
@@ -60,4 +86,111 @@ var envVariablesConfig = []configlib.EnvConfig{
 		Variable:     "LanguagePackFilePath",
 		DefaultValue: "./",
 	},
+	{
+		Key:          "BRIDGES_CACHE_SIZE",
+		Variable:     "BridgesCacheSize",
+		DefaultValue: "1024",
+	},
+	{
+		Key:          "TRACING_EXPORTER",
+		Variable:     "TracingExporter",
+		DefaultValue: "none",
+	},
+	{
+		Key:          "TRACING_SAMPLE_RATIO",
+		Variable:     "TracingSampleRatio",
+		DefaultValue: "1",
+	},
+	{
+		Key:      "OTLP_ENDPOINT",
+		Variable: "OTLPEndpoint",
+	},
+	{
+		Key:      "OTLP_INSECURE",
+		Variable: "OTLPInsecure",
+	},
+	{
+		Key:          "BATCH_WORKERS",
+		Variable:     "BatchWorkers",
+		DefaultValue: "4",
+	},
+	{
+		Key:          "BATCH_MAX_ITEMS",
+		Variable:     "BatchMaxItems",
+		DefaultValue: "500",
+	},
+	{
+		Key:          "COMPUTATION_TIMEOUT_MS",
+		Variable:     "ComputationTimeoutMs",
+		DefaultValue: "5000",
+	},
+	{
+		Key:          "RATE_LIMIT_REQUESTS_PER_SECOND",
+		Variable:     "RateLimitRequestsPerSecond",
+		DefaultValue: "10",
+	},
+	{
+		Key:          "RATE_LIMIT_BURST",
+		Variable:     "RateLimitBurst",
+		DefaultValue: "20",
+	},
+	{
+		Key:          "RATE_LIMIT_TRUST_FORWARDED_FOR",
+		Variable:     "RateLimitTrustForwardedFor",
+		DefaultValue: "false",
+	},
+	{
+		Key:          "MAX_REQUEST_BODY_BYTES",
+		Variable:     "MaxRequestBodyBytes",
+		DefaultValue: "1048576",
+	},
+	{
+		Key:          "AUTH_REQUIRED",
+		Variable:     "AuthRequired",
+		DefaultValue: "false",
+	},
+	{
+		Key:      "AUTH_API_KEYS_FILE_PATH",
+		Variable: "AuthAPIKeysFilePath",
+	},
+	{
+		Key:      "AUTH_JWT_SECRET",
+		Variable: "AuthJWTSecret",
+	},
+	{
+		Key:      "AUTH_JWKS_FILE_PATH",
+		Variable: "AuthJWKSFilePath",
+	},
+	{
+		Key:      "AUTH_JWT_ISSUER",
+		Variable: "AuthJWTIssuer",
+	},
+	{
+		Key:      "AUTH_JWT_AUDIENCE",
+		Variable: "AuthJWTAudience",
+	},
+	{
+		Key:      "ADMIN_SUBJECTS",
+		Variable: "AdminSubjects",
+	},
+	{
+		Key:          "MESSAGE_CATALOGS_PATH",
+		Variable:     "MessageCatalogsPath",
+		DefaultValue: "./i18n/catalogs/",
+	},
+	{
+		Key:          "DEFAULT_LANGUAGE",
+		Variable:     "DefaultLanguage",
+		DefaultValue: "it",
+	},
+	{
+		Key:          "STORAGE_BACKEND",
+		Variable:     "StorageBackend",
+		DefaultValue: "memory",
+	},
+	{
+		Key:          "STORAGE_FILE_PATH",
+		Variable:     "StorageFilePath",
+		DefaultValue: "./feriapp-data.json",
+	},
 }
@@ -0,0 +1,184 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// UnknownCityError tells that a city is missing from the language pack, suggesting the
+// cities with a similar name.
+type UnknownCityError struct {
+	City        string
+	Suggestions []string
+}
+
+func (e *UnknownCityError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("unknown city %q", e.City)
+	}
+	return fmt.Sprintf("unknown city %q: did you mean %s?", e.City, strings.Join(e.Suggestions, ", "))
+}
+
+// maxSuggestions is the number of cities suggested in place of an unknown one.
+const maxSuggestions = 5
+
+// FindCity returns the entry of the language pack of the locale for city, matched regardless
+// of case, accents, spaces and punctuation, also by the aliases of the cities. An unknown city
+// fails with an *UnknownCityError, while the errors reading the pack are returned as they are.
+func FindCity(locale string, city string) (Holiday, error) {
+	holidays, err := LoadLanguagePack(locale)
+	if err != nil {
+		return Holiday{}, err
+	}
+	if holiday, ok := matchCity(holidays, city); ok {
+		return holiday, nil
+	}
+	suggestions := []string{}
+	for _, holiday := range rankCities(holidays, city, maxSuggestions) {
+		suggestions = append(suggestions, holiday.City)
+	}
+	return Holiday{}, &UnknownCityError{City: city, Suggestions: suggestions}
+}
+
+// SearchCities returns at most limit cities of the language pack of the locale matching query,
+// for autocompletion: first the cities whose name or alias starts with query, then those
+// containing it and last those differing from it by a few letters. An empty query lists
+// every city, while a missing pack has no cities.
+func SearchCities(locale string, query string, limit int) ([]Holiday, error) {
+	holidays, err := LoadLanguagePack(locale)
+	if os.IsNotExist(err) {
+		return []Holiday{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rankCities(holidays, query, limit), nil
+}
+
+func matchCity(holidays []Holiday, city string) (Holiday, bool) {
+	key := matchKey(city)
+	if key == "" {
+		return Holiday{}, false
+	}
+	for _, holiday := range holidays {
+		for _, name := range cityNames(holiday) {
+			if matchKey(name) == key {
+				return holiday, true
+			}
+		}
+	}
+	return Holiday{}, false
+}
+
+func cityNames(holiday Holiday) []string {
+	return append([]string{holiday.City}, holiday.Aliases...)
+}
+
+// rankCities returns at most limit cities matching query, best first. A city ranks by the best
+// of its names: 0 when the name starts with query, 1 when it contains it, and 1 plus the edit
+// distance when the two differ by about a letter every three.
+func rankCities(holidays []Holiday, query string, limit int) []Holiday {
+	key := matchKey(query)
+	maxDistance := (len([]rune(key)) + 1) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	type rankedCity struct {
+		holiday Holiday
+		rank    int
+	}
+	ranked := []rankedCity{}
+	for _, holiday := range holidays {
+		best := -1
+		for _, name := range cityNames(holiday) {
+			nameKey := matchKey(name)
+			rank := -1
+			switch {
+			case strings.HasPrefix(nameKey, key):
+				rank = 0
+			case strings.Contains(nameKey, key):
+				rank = 1
+			default:
+				if distance := editDistance(key, nameKey); distance <= maxDistance {
+					rank = 1 + distance
+				}
+			}
+			if rank >= 0 && (best < 0 || rank < best) {
+				best = rank
+			}
+		}
+		if best >= 0 {
+			ranked = append(ranked, rankedCity{holiday: holiday, rank: best})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank < ranked[j].rank
+		}
+		return matchKey(ranked[i].holiday.City) < matchKey(ranked[j].holiday.City)
+	})
+	if limit >= 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	cities := make([]Holiday, 0, len(ranked))
+	for _, city := range ranked {
+		cities = append(cities, city.holiday)
+	}
+	return cities
+}
+
+// matchKey reduces the name of a city to its lower case letters and digits without accents,
+// so that "Forlì", "forli" and "FORLI'" match, as "Reggio-Calabria" and "reggio calabria".
+func matchKey(name string) string {
+	var key strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := accentFolds[r]; ok {
+			r = folded
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+var accentFolds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n', 'ÿ': 'y',
+}
+
+// editDistance is the Levenshtein distance between a and b, counted in runes.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindCity(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+
+	testCase.Run("matches", func(t *testing.T) {
+		for city, expected := range map[string]string{
+			"Milano":             "Milano",
+			" milano ":           "Milano",
+			"MILAN":              "Milano",
+			"forli":              "Forlì",
+			"FORLI'":             "Forlì",
+			"Reggio nell'Emilia": "Reggio Emilia",
+			"reggio-calabria":    "Reggio Calabria",
+			"laquila":            "L'Aquila",
+			"Florence":           "Firenze",
+		} {
+			holiday, err := FindCity("IT", city)
+			require.NoError(t, err, city)
+			require.Equal(t, expected, holiday.City, city)
+		}
+	})
+
+	testCase.Run("unknown city", func(t *testing.T) {
+		_, err := FindCity("IT", "Veronna")
+		var unknownCity *UnknownCityError
+		require.True(t, errors.As(err, &unknownCity), err)
+		require.Equal(t, "Veronna", unknownCity.City)
+		require.Equal(t, []string{"Verona"}, unknownCity.Suggestions)
+		require.EqualError(t, err, `unknown city "Veronna": did you mean Verona?`)
+
+		_, err = FindCity("IT", "Springfield")
+		require.EqualError(t, err, `unknown city "Springfield"`)
+	})
+
+	testCase.Run("patron day of an alias", func(t *testing.T) {
+		holidays := ListHolidays(2021, "IT", "milan")
+		require.Equal(t, "2021-12-07", holidays[len(holidays)-1].Date.String())
+		require.Equal(t, PatronKey, holidays[len(holidays)-1].Key)
+	})
+}
+
+func TestSearchCities(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+
+	testCase.Run("prefix before substring and typos", func(t *testing.T) {
+		found, err := SearchCities("IT", "ver", 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Verbania", "Vercelli", "Verona"}, cities(found))
+
+		found, err = SearchCities("IT", "ena", 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Cesena", "Modena", "Siena", "Enna"}, cities(found))
+
+		found, err = SearchCities("IT", "Pescra", 1)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Pescara"}, cities(found))
+	})
+
+	testCase.Run("aliases", func(t *testing.T) {
+		found, err := SearchCities("IT", "nap", 10)
+		require.NoError(t, err)
+		require.Equal(t, "Napoli", found[0].City)
+		require.Equal(t, "San Gennaro", found[0].Name)
+	})
+
+	testCase.Run("empty query and limit", func(t *testing.T) {
+		found, err := SearchCities("IT", "", 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Agrigento", "Alessandria", "Ancona"}, cities(found))
+	})
+
+	testCase.Run("missing pack", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "feriapp-packs")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+		defer os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+
+		found, err := SearchCities("IT", "mil", 10)
+		require.NoError(t, err)
+		require.Empty(t, found)
+		_, err = FindCity("IT", "Milano")
+		require.True(t, os.IsNotExist(err), err)
+	})
+}
+
+func TestEditDistance(t *testing.T) {
+	require.Equal(t, 0, editDistance("milano", "milano"))
+	require.Equal(t, 1, editDistance("milno", "milano"))
+	require.Equal(t, 2, editDistance("milna", "milano"))
+	require.Equal(t, 3, editDistance("", "bar"))
+}
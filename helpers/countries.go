@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"feriapp-backend-go/civil"
+)
+
+// ObservedRule tells the day an holiday falling on a weekend is observed on, when the
+// country gives a working day in its place.
+type ObservedRule string
+
+const (
+	// NotObserved leaves the holidays on their day, as in Italy.
+	NotObserved ObservedRule = ""
+	// NextWeekday observes an holiday falling on a weekend on the first following weekday
+	// which is not an holiday already, as the substitute days of the United Kingdom.
+	NextWeekday ObservedRule = "nextWeekday"
+	// NearestWeekday observes an holiday falling on Saturday on the Friday before and one
+	// falling on Sunday on the Monday after, as the federal holidays of the United States.
+	NearestWeekday ObservedRule = "nearestWeekday"
+)
+
+// country describes the national holidays of a locale.
+type country struct {
+	holidays func(year int, easter civil.Date) []HolidayDate
+	observed ObservedRule
+	// patrons tells whether the language pack of the locale lists the patron days of its cities
+	patrons bool
+	// provinces maps the codes of the provinces to their region, to validate the language pack
+	provinces map[string]string
+}
+
+var countries = map[string]country{
+	"IT": {holidays: italianHolidays, patrons: true, provinces: italianProvinces},
+	"GB": {holidays: britishHolidays, observed: NextWeekday},
+	"US": {holidays: americanHolidays, observed: NearestWeekday},
+}
+
+// Countries returns the codes of the countries whose national holidays are known, sorted.
+func Countries() []string {
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// CheckCountry fails when the national holidays of the country code are unknown.
+func CheckCountry(code string) error {
+	if _, ok := countries[code]; !ok {
+		return fmt.Errorf("invalid country %q: must be one of %s", code, strings.Join(Countries(), ", "))
+	}
+	return nil
+}
+
+func italianHolidays(year int, easter civil.Date) []HolidayDate {
+	return []HolidayDate{
+		{Date: easter, Name: "Pasqua", Key: "easter"},
+		{Date: easter.AddDays(1), Name: "Lunedì dell'Angelo", Key: "easter-monday"},
+		{Date: civil.NewDate(year, 1, 1), Name: "Capodanno", Key: "new-year"},
+		{Date: civil.NewDate(year, 1, 6), Name: "Epifania", Key: "epiphany"},
+		{Date: civil.NewDate(year, 4, 25), Name: "Festa della Liberazione", Key: "liberation"},
+		{Date: civil.NewDate(year, 5, 1), Name: "Festa dei Lavoratori", Key: "labour"},
+		{Date: civil.NewDate(year, 6, 2), Name: "Festa della Repubblica", Key: "republic"},
+		{Date: civil.NewDate(year, 8, 15), Name: "Ferragosto", Key: "assumption"},
+		{Date: civil.NewDate(year, 11, 1), Name: "Ognissanti", Key: "all-saints"},
+		{Date: civil.NewDate(year, 12, 8), Name: "Immacolata Concezione", Key: "immaculate"},
+		{Date: civil.NewDate(year, 12, 25), Name: "Natale", Key: "christmas"},
+		{Date: civil.NewDate(year, 12, 26), Name: "Santo Stefano", Key: "st-stephen"},
+	}
+}
+
+// britishHolidays returns the bank holidays of England and Wales.
+func britishHolidays(year int, easter civil.Date) []HolidayDate {
+	return []HolidayDate{
+		{Date: civil.NewDate(year, 1, 1), Name: "New Year's Day", Key: "new-year"},
+		{Date: easter.AddDays(-2), Name: "Good Friday", Key: "good-friday"},
+		{Date: easter.AddDays(1), Name: "Easter Monday", Key: "easter-monday"},
+		{Date: NthWeekday(year, time.May, time.Monday, 1), Name: "Early May bank holiday", Key: "early-may"},
+		{Date: NthWeekday(year, time.May, time.Monday, -1), Name: "Spring bank holiday", Key: "spring-bank"},
+		{Date: NthWeekday(year, time.August, time.Monday, -1), Name: "Summer bank holiday", Key: "summer-bank"},
+		{Date: civil.NewDate(year, 12, 25), Name: "Christmas Day", Key: "christmas"},
+		{Date: civil.NewDate(year, 12, 26), Name: "Boxing Day", Key: "boxing-day"},
+	}
+}
+
+// americanHolidays returns the federal holidays of the United States.
+func americanHolidays(year int, easter civil.Date) []HolidayDate {
+	return []HolidayDate{
+		{Date: civil.NewDate(year, 1, 1), Name: "New Year's Day", Key: "new-year"},
+		{Date: NthWeekday(year, time.January, time.Monday, 3), Name: "Martin Luther King Jr. Day", Key: "mlk"},
+		{Date: NthWeekday(year, time.February, time.Monday, 3), Name: "Washington's Birthday", Key: "washington"},
+		{Date: NthWeekday(year, time.May, time.Monday, -1), Name: "Memorial Day", Key: "memorial"},
+		{Date: civil.NewDate(year, 6, 19), Name: "Juneteenth", Key: "juneteenth"},
+		{Date: civil.NewDate(year, 7, 4), Name: "Independence Day", Key: "independence"},
+		{Date: NthWeekday(year, time.September, time.Monday, 1), Name: "Labor Day", Key: "labor"},
+		{Date: NthWeekday(year, time.October, time.Monday, 2), Name: "Columbus Day", Key: "columbus"},
+		{Date: civil.NewDate(year, 11, 11), Name: "Veterans Day", Key: "veterans"},
+		{Date: NthWeekday(year, time.November, time.Thursday, 4), Name: "Thanksgiving Day", Key: "thanksgiving"},
+		{Date: civil.NewDate(year, 12, 25), Name: "Christmas Day", Key: "christmas"},
+	}
+}
+
+// NthWeekday returns the nth weekday of the month, as the first Monday of May,
+// or the last one of the month when n is negative.
+func NthWeekday(year int, month time.Month, weekday time.Weekday, n int) civil.Date {
+	if n < 0 {
+		last := civil.NewDate(year, month+1, 0)
+		return last.AddDays(-((int(last.Weekday()) - int(weekday) + 7) % 7))
+	}
+	first := civil.NewDate(year, month, 1)
+	return first.AddDays((int(weekday)-int(first.Weekday())+7)%7 + 7*(n-1))
+}
+
+func isWeekend(date civil.Date) bool {
+	return date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+}
+
+// ObservedDays returns the days the holidays falling on a weekend are observed on as told
+// by rule, in chronological order, each named after its holiday and flagged as Observed.
+func ObservedDays(holidays []HolidayDate, rule ObservedRule) []HolidayDate {
+	if rule == NotObserved {
+		return []HolidayDate{}
+	}
+	sorted := append([]HolidayDate{}, holidays...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	taken := map[civil.Date]bool{}
+	for _, holiday := range sorted {
+		taken[holiday.Date] = true
+	}
+
+	observed := []HolidayDate{}
+	for _, holiday := range sorted {
+		if !isWeekend(holiday.Date) {
+			continue
+		}
+		var date civil.Date
+		switch rule {
+		case NextWeekday:
+			// the substitute of an holiday is not given on the substitute of another one
+			date = holiday.Date.AddDays(1)
+			for isWeekend(date) || taken[date] {
+				date = date.AddDays(1)
+			}
+		case NearestWeekday:
+			if date = holiday.Date.AddDays(1); holiday.Date.Weekday() == time.Saturday {
+				date = holiday.Date.AddDays(-1)
+			}
+		default:
+			continue
+		}
+		taken[date] = true
+		observed = append(observed, HolidayDate{Date: date, Name: holiday.Name, Key: holiday.Key, Observed: true})
+	}
+	return observed
+}
+
+// nationalHolidays returns the national holidays of the year for the locale, followed by
+// the days they are observed on, also when observing the holidays of the years around it.
+// It returns nil for unknown locales.
+func nationalHolidays(year int, locale string) ([]HolidayDate, error) {
+	country, ok := countries[locale]
+	if !ok {
+		return nil, nil
+	}
+	easter, err := easterDate(year)
+	if err != nil {
+		return nil, err
+	}
+	holidays := country.holidays(year, easter)
+	if country.observed == NotObserved {
+		return holidays, nil
+	}
+	around := []HolidayDate{}
+	for aroundYear := year - 1; aroundYear <= year+1; aroundYear++ {
+		aroundEaster, err := easterDate(aroundYear)
+		if err != nil {
+			return nil, err
+		}
+		around = append(around, country.holidays(aroundYear, aroundEaster)...)
+	}
+	for _, observed := range ObservedDays(around, country.observed) {
+		if observed.Date.Year == year {
+			holidays = append(holidays, observed)
+		}
+	}
+	return holidays, nil
+}
+
+func easterDate(year int) (civil.Date, error) {
+	easterTime, err := CatholicByYear(year)
+	if err != nil {
+		return civil.Date{}, fmt.Errorf("computing the Easter of %d: %w", year, err)
+	}
+	return civil.DateOf(easterTime), nil
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"feriapp-backend-go/civil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNthWeekday(t *testing.T) {
+	require.Equal(t, civil.NewDate(2021, 5, 3), NthWeekday(2021, time.May, time.Monday, 1))
+	require.Equal(t, civil.NewDate(2021, 5, 31), NthWeekday(2021, time.May, time.Monday, -1))
+	require.Equal(t, civil.NewDate(2021, 8, 30), NthWeekday(2021, time.August, time.Monday, -1))
+	require.Equal(t, civil.NewDate(2021, 11, 25), NthWeekday(2021, time.November, time.Thursday, 4))
+	require.Equal(t, civil.NewDate(2019, 5, 7), NthWeekday(2019, time.May, time.Tuesday, 1))
+}
+
+func TestObservedDays(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+	observed := func(holidays []HolidayDate) []HolidayDate {
+		days := []HolidayDate{}
+		for _, holiday := range holidays {
+			if holiday.Observed {
+				days = append(days, holiday)
+			}
+		}
+		return days
+	}
+
+	testCase.Run("substitute days of the United Kingdom", func(t *testing.T) {
+		// Christmas on Saturday and Boxing Day on Sunday
+		require.Equal(t, []HolidayDate{
+			{Date: civil.NewDate(2021, 12, 27), Name: "Christmas Day", Key: "christmas", Observed: true},
+			{Date: civil.NewDate(2021, 12, 28), Name: "Boxing Day", Key: "boxing-day", Observed: true},
+		}, observed(ListHolidays(2021, "GB", "")))
+		// New Year on Saturday, and Christmas on Sunday before Boxing Day on Monday
+		require.Equal(t, []HolidayDate{
+			{Date: civil.NewDate(2022, 1, 3), Name: "New Year's Day", Key: "new-year", Observed: true},
+			{Date: civil.NewDate(2022, 12, 27), Name: "Christmas Day", Key: "christmas", Observed: true},
+		}, observed(ListHolidays(2022, "GB", "")))
+	})
+
+	testCase.Run("federal holidays of the United States", func(t *testing.T) {
+		// the New Year of 2022, on Saturday, is observed on the last day of 2021
+		require.Equal(t, []HolidayDate{
+			{Date: civil.NewDate(2021, 6, 18), Name: "Juneteenth", Key: "juneteenth", Observed: true},
+			{Date: civil.NewDate(2021, 7, 5), Name: "Independence Day", Key: "independence", Observed: true},
+			{Date: civil.NewDate(2021, 12, 24), Name: "Christmas Day", Key: "christmas", Observed: true},
+			{Date: civil.NewDate(2021, 12, 31), Name: "New Year's Day", Key: "new-year", Observed: true},
+		}, observed(ListHolidays(2021, "US", "")))
+		require.Len(t, ListHolidays(2021, "US", ""), 15)
+	})
+
+	testCase.Run("holidays stay on their day in Italy", func(t *testing.T) {
+		require.Empty(t, observed(ListHolidays(2021, "IT", "Milano")))
+		require.Equal(t, []HolidayDate{}, ObservedDays(ListHolidays(2021, "IT", "Milano"), NotObserved))
+	})
+
+	testCase.Run("countries without language pack", func(t *testing.T) {
+		before := LanguagePackStats()
+		ListHolidays(2021, "GB", "London")
+		require.Equal(t, before, LanguagePackStats())
+	})
+}
+
+func TestCheckCountry(t *testing.T) {
+	require.Equal(t, []string{"GB", "IT", "US"}, Countries())
+	require.NoError(t, CheckCountry("GB"))
+	require.EqualError(t, CheckCountry("gb"), `invalid country "gb": must be one of GB, IT, US`)
+}
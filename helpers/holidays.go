@@ -1,95 +1,141 @@
 package helpers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
+
+	"feriapp-backend-go/civil"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func HolidaysUtils(year int, daysOffMap map[int]bool, locale string, city string) func(date time.Time) bool {
-	holidays := getHolidays(year, locale, city)
+var tracer = otel.Tracer("feriapp-backend-go/helpers")
+
+var languagePackLoads, languagePackErrors uint64
+
+// PackStats reports how many times the language packs have been read from disk
+// and how many of those reads failed.
+type PackStats struct {
+	Loads  uint64
+	Errors uint64
+}
+
+// LanguagePackStats returns a snapshot of the language pack load counters.
+func LanguagePackStats() PackStats {
+	return PackStats{
+		Loads:  atomic.LoadUint64(&languagePackLoads),
+		Errors: atomic.LoadUint64(&languagePackErrors),
+	}
+}
 
-	return func(date time.Time) bool {
-		return daysOffMap[int(date.Weekday())] || isCurrentDateAnHolidays(date, holidays)
+// Holidays returns the days of the year that are holidays for the locale and the city,
+// failing as LoadHolidays does.
+func Holidays(ctx context.Context, year int, locale string, city string) ([]civil.Date, error) {
+	_, span := tracer.Start(ctx, "loadHolidays")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("feriapp.year", year),
+		attribute.String("feriapp.locale", locale),
+		attribute.String("feriapp.city", city),
+	)
+	listed, err := LoadHolidays(year, locale, city)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
 	}
+	holidays := make([]civil.Date, 0, len(listed))
+	for _, holiday := range listed {
+		holidays = append(holidays, holiday.Date)
+	}
+	span.SetAttributes(attribute.Int("feriapp.holidays_count", len(holidays)))
+	return holidays, nil
+}
+
+// HolidayDate is an holiday occurring on a specific day. Name is the name in the language
+// of the country, while Key identifies the holiday across languages.
+type HolidayDate struct {
+	Date civil.Date `json:"date"`
+	Name string     `json:"name"`
+	Key  string     `json:"key,omitempty"`
+	// Observed marks the working day given in place of the holiday, which fell on a weekend.
+	Observed bool `json:"observed,omitempty"`
 }
 
-func isCurrentDateAnHolidays(date time.Time, holidays []time.Time) bool {
-	isHoliday := false
+// PatronKey is the key of the patron day of a city, whose name is the one of the saint.
+const PatronKey = "patron"
 
-	for _, holiday := range holidays {
-		if date.Equal(holiday) {
-			return true
-		}
+// ListHolidays returns the holidays of LoadHolidays, leaving out the patron day of the city
+// when the language pack cannot be read.
+func ListHolidays(year int, locale string, city string) []HolidayDate {
+	holidays, _ := LoadHolidays(year, locale, city)
+	if holidays == nil {
+		return []HolidayDate{}
 	}
-	return isHoliday
+	return holidays
 }
 
-func getHolidays(year int, locale string, city string) []time.Time {
-	localHolidays := readFile(locale)
-	var holidays []time.Time
-	var localCityHoliday Holiday
-	for _, localHoliday := range localHolidays {
-		if localHoliday.City == city {
-			localCityHoliday = localHoliday
-		}
+// LoadHolidays returns the national holidays of the year for the locale, with the days they
+// are observed on in the countries moving the holidays falling on weekends, followed by the
+// patron day of the city when the language pack defines one. The city is matched regardless
+// of case and accents, also by its aliases. A missing language pack only leaves out the
+// patron day, while a pack that cannot be read or parsed fails together with the national
+// holidays, so that the caller can report the error and go on without the patron day.
+func LoadHolidays(year int, locale string, city string) ([]HolidayDate, error) {
+	holidays, err := nationalHolidays(year, locale)
+	if holidays == nil || err != nil {
+		return holidays, err
 	}
-	easterDate, err := CatholicByYear(year)
-	if err != nil {
-		fmt.Printf("error calculating easter")
+	if !countries[locale].patrons {
+		return holidays, nil
 	}
-	switch locale {
-	case "IT":
-		holidays = []time.Time{
-			easterDate,
-			easterDate.AddDate(0, 0, 1),
-			time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 1, 6, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 4, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 5, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 6, 2, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 8, 15, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 11, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 8, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 26, 0, 0, 0, 0, time.UTC),
-		}
-	default:
-		return []time.Time{}
+	localHolidays, err := readFile(locale)
+	if err != nil {
+		return holidays, err
 	}
-	splittedDate := strings.Split(localCityHoliday.Date, "-")
-	month, montErr := strconv.Atoi(splittedDate[0])
-	if montErr != nil {
-		fmt.Printf("error parsing local city holiday month: %s\n", splittedDate[0])
-		return holidays
+	localHoliday, ok := matchCity(localHolidays, city)
+	if !ok {
+		return holidays, nil
 	}
-	day, dayErr := strconv.Atoi(splittedDate[1])
-	if dayErr != nil {
-		fmt.Printf("error parsing local city holiday day: %s\n", splittedDate[1])
-		return holidays
+	// the language pack is validated at startup, but a malformed date must not break the holidays
+	date, err := time.Parse("01-02", localHoliday.Date)
+	if err != nil {
+		return holidays, nil
 	}
-	localCityHolidayDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
-
-	return append(holidays, localCityHolidayDate.UTC())
+	localCityHolidayDate := civil.NewDate(year, date.Month(), date.Day())
+	return append(holidays, HolidayDate{Date: localCityHolidayDate, Name: localHoliday.Name, Key: PatronKey}), nil
 }
 
-func readFile(locale string) []Holiday {
-	jsonFile, err := os.Open(fmt.Sprintf("%s%s.json", os.Getenv("LANGUAGE_PACK_FILE_PATH"), locale))
+// LanguagePackVersion returns a short digest of the language pack content for the given locale,
+// so that anything derived from the holidays data can be invalidated when the pack changes.
+// An empty string is returned when the pack does not exist. The digest is computed once per
+// read of the pack, which is read again only when the file changes.
+func LanguagePackVersion(locale string) string {
+	pack, err := loadPack(locale)
 	if err != nil {
-		fmt.Println(err)
-		return []Holiday{}
+		return ""
 	}
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-
-	var holidays []Holiday
+	return pack.version
+}
 
-	json.Unmarshal(byteValue, &holidays)
+func languagePackPath(locale string) string {
+	return fmt.Sprintf("%s%s.json", os.Getenv("LANGUAGE_PACK_FILE_PATH"), locale)
+}
 
-	return holidays
+// readFile returns the entries of the language pack of the locale, none when it is missing.
+// They are shared with the cache of the parsed packs, so they must not be modified.
+func readFile(locale string) ([]Holiday, error) {
+	pack, err := loadPack(locale)
+	if os.IsNotExist(err) {
+		return []Holiday{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pack.holidays, pack.err
 }
 
 type Holiday struct {
@@ -98,4 +144,6 @@ type Holiday struct {
 	Date     string `json:"date" bson:"date"`
 	Region   string `json:"region" bson:"region"`
 	Province string `json:"province" bson:"daysCount"`
+	// Aliases are the other names the city is known by, as the English one.
+	Aliases []string `json:"aliases,omitempty" bson:"aliases,omitempty"`
 }
@@ -1,62 +1,41 @@
 package helpers
 
 import (
+	"feriapp-backend-go/civil"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetHolidays(testCase *testing.T) {
+func TestLanguagePackVersion(t *testing.T) {
 	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
-	testCase.Run("wrong locale", func(t *testing.T) {
-		actualHolidays := getHolidays(2019, "wrong_locale", "Milano")
-
-		expectedHolidays := []time.Time{}
-		require.Equal(t, expectedHolidays, actualHolidays, "Should return empty list")
-	})
-
-	testCase.Run("wrong city", func(t *testing.T) {
-		year := 2019
-		actualHolidays := getHolidays(year, "IT", "wrong_city")
-
-		expectedHolidays := []time.Time{
-			time.Date(year, 4, 21, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 4, 22, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 1, 6, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 4, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 5, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 6, 2, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 8, 15, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 11, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 8, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 26, 0, 0, 0, 0, time.UTC),
-		}
-		require.Equal(t, expectedHolidays, actualHolidays, "Should return correct list except local city holiday")
-	})
-
-	testCase.Run("correct city - Milano", func(t *testing.T) {
-		year := 2019
-		actualHolidays := getHolidays(year, "IT", "Milano")
-
-		expectedHolidays := []time.Time{
-			time.Date(year, 4, 21, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 4, 22, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 1, 6, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 4, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 5, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 6, 2, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 8, 15, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 11, 1, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 8, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 25, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 26, 0, 0, 0, 0, time.UTC),
-			time.Date(year, 12, 7, 0, 0, 0, 0, time.UTC),
-		}
-		require.Equal(t, expectedHolidays, actualHolidays, "Should return correct list except local city holiday")
-	})
+
+	version := LanguagePackVersion("IT")
+	require.Len(t, version, 16)
+	require.Equal(t, version, LanguagePackVersion("IT"), "Version should be stable")
+	require.Equal(t, "", LanguagePackVersion("wrong_locale"))
+}
+
+func TestLanguagePackStats(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+	readFile("IT")
+	before := LanguagePackStats()
+
+	readFile("IT")
+	readFile("wrong_locale")
+
+	after := LanguagePackStats()
+	require.Equal(t, before.Loads+1, after.Loads, "an unchanged pack is not read again")
+	require.Equal(t, before.Errors+1, after.Errors)
+}
+
+func TestListHolidays(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+
+	holidays := ListHolidays(2019, "IT", "Milano")
+	require.Len(t, holidays, 13)
+	require.Equal(t, HolidayDate{Date: civil.NewDate(2019, 4, 21), Name: "Pasqua", Key: "easter"}, holidays[0])
+	require.Equal(t, HolidayDate{Date: civil.NewDate(2019, 12, 7), Name: "Sant'Ambrogio", Key: PatronKey}, holidays[12])
+	require.Equal(t, []HolidayDate{}, ListHolidays(2019, "wrong_locale", "Milano"))
 }
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loadedPack is a language pack as read from disk, with the digest of its content and the
+// holidays it lists, or the error parsing them.
+type loadedPack struct {
+	modTime  time.Time
+	size     int64
+	version  string
+	holidays []Holiday
+	err      error
+}
+
+// packCache keeps the language packs read from disk by path, so that they are read, hashed
+// and parsed again only when the file changes.
+var packCache = struct {
+	sync.Mutex
+	packs map[string]loadedPack
+}{packs: map[string]loadedPack{}}
+
+// loadPack returns the language pack of the locale, reading it from disk only when its size
+// or modification time changed since the last read. Reads from disk are counted by the
+// language pack stats.
+func loadPack(locale string) (loadedPack, error) {
+	path := languagePackPath(locale)
+	info, err := os.Stat(path)
+	if err != nil {
+		atomic.AddUint64(&languagePackLoads, 1)
+		atomic.AddUint64(&languagePackErrors, 1)
+		return loadedPack{}, err
+	}
+
+	packCache.Lock()
+	defer packCache.Unlock()
+	if cached, ok := packCache.packs[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached, nil
+	}
+	atomic.AddUint64(&languagePackLoads, 1)
+	// the file is stat-ed before being read, so that a change in between is read again next time
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		atomic.AddUint64(&languagePackErrors, 1)
+		return loadedPack{}, err
+	}
+	sum := sha256.Sum256(content)
+	pack := loadedPack{modTime: info.ModTime(), size: info.Size(), version: hex.EncodeToString(sum[:8])}
+	if err := json.Unmarshal(content, &pack.holidays); err != nil {
+		atomic.AddUint64(&languagePackErrors, 1)
+		pack.holidays = nil
+		pack.err = fmt.Errorf("parsing language pack %s: %w", locale, err)
+	}
+	packCache.packs[path] = pack
+	return pack, nil
+}
+
+// forgetPack drops the language pack at path from the cache, once it has been rewritten.
+func forgetPack(path string) {
+	packCache.Lock()
+	defer packCache.Unlock()
+	delete(packCache.packs, path)
+}
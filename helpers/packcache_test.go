@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feriapp-packs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+	defer os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+	path := filepath.Join(dir, "IT.json")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"city": "Asti", "name": "San Secondo", "date": "05-07"}]`), 0600))
+	before := LanguagePackStats()
+	version := LanguagePackVersion("IT")
+	require.Len(t, version, 16)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, version, LanguagePackVersion("IT"))
+		_, err := FindCity("IT", "asti")
+		require.NoError(t, err)
+	}
+	require.Equal(t, before.Loads+1, LanguagePackStats().Loads, "the pack is read once while it does not change")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"city": "Lodi", "name": "San Bassiano", "date": "01-19"}]`), 0600))
+	require.NotEqual(t, version, LanguagePackVersion("IT"))
+	_, err = FindCity("IT", "Lodi")
+	require.NoError(t, err)
+
+	holidays, err := LoadLanguagePack("IT")
+	require.NoError(t, err)
+	holidays[0].City = "Changed"
+	_, err = FindCity("IT", "Lodi")
+	require.NoError(t, err, "the cached pack is not shared with the callers")
+}
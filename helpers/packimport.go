@@ -0,0 +1,435 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// ImportCSV reads the patron days from a CSV file with a header naming the city, name and
+	// date columns, and optionally the region and province ones.
+	ImportCSV = "csv"
+	// ImportICS reads the patron days from the events of an iCalendar file: LOCATION is the
+	// city, SUMMARY the name and DTSTART the date, while X-FERIAPP-REGION and
+	// X-FERIAPP-PROVINCE fill the region and the province.
+	ImportICS = "ics"
+)
+
+// ImportError lists the problems found in the holidays being imported, each telling the line
+// of the entry it was found in.
+type ImportError struct {
+	Problems []string
+}
+
+func (e *ImportError) Error() string {
+	return "invalid holidays: " + strings.Join(e.Problems, "; ")
+}
+
+// HasLanguagePack tells whether the holidays of the locale include the patron days of the
+// cities listed by its language pack.
+func HasLanguagePack(locale string) bool {
+	return countries[locale].patrons
+}
+
+// ParseHolidays reads the patron days of the cities in format, ImportCSV or ImportICS.
+// Dates are either yearly, as 12-07, or dated, as 2021-12-07, whose year is dropped.
+// City names are normalized, and every city can appear only once.
+func ParseHolidays(r io.Reader, format string) ([]Holiday, error) {
+	var entries []importEntry
+	var err error
+	switch format {
+	case ImportCSV:
+		entries, err = readCSVEntries(r)
+	case ImportICS:
+		entries, err = readICSEntries(r)
+	default:
+		return nil, fmt.Errorf("unknown import format %q: use %s or %s", format, ImportCSV, ImportICS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	holidays := make([]Holiday, 0, len(entries))
+	problems := []string{}
+	lines := map[string]int{}
+	for _, entry := range entries {
+		holiday, problem := entry.holiday()
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("line %d: %s", entry.line, problem))
+			continue
+		}
+		key := cityKey(holiday.City)
+		if line, ok := lines[key]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: duplicate city %q, already at line %d", entry.line, holiday.City, line))
+			continue
+		}
+		lines[key] = entry.line
+		holidays = append(holidays, holiday)
+	}
+	if len(problems) > 0 {
+		return nil, &ImportError{Problems: problems}
+	}
+	return holidays, nil
+}
+
+// importEntry is a patron day as read from an import, before its validation.
+type importEntry struct {
+	line                               int
+	city, name, date, region, province string
+}
+
+func (e importEntry) holiday() (Holiday, string) {
+	city := NormalizeCity(e.city)
+	if city == "" {
+		return Holiday{}, "missing city"
+	}
+	name := strings.Join(strings.Fields(e.name), " ")
+	if name == "" {
+		return Holiday{}, fmt.Sprintf("missing name of the patron day of %s", city)
+	}
+	date, err := parsePatronDate(e.date)
+	if err != nil {
+		return Holiday{}, err.Error()
+	}
+	province := strings.ToUpper(strings.TrimSpace(e.province))
+	if province != "" && len(province) != 2 {
+		return Holiday{}, fmt.Sprintf("invalid province %q: must be a two letters code", e.province)
+	}
+	return Holiday{
+		City:     city,
+		Name:     name,
+		Date:     date,
+		Region:   strings.Join(strings.Fields(e.region), " "),
+		Province: province,
+	}, ""
+}
+
+// parsePatronDate returns the day of the year of value, formatted as MM-DD.
+func parsePatronDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	invalid := fmt.Errorf("invalid date %q: must be formatted as MM-DD or YYYY-MM-DD", value)
+	var month, day int
+	var err error
+	switch len(value) {
+	case len("12-07"):
+		if value[2] != '-' {
+			return "", invalid
+		}
+		if month, err = strconv.Atoi(value[:2]); err == nil {
+			day, err = strconv.Atoi(value[3:])
+		}
+		// a leap year accepts the 29th of February
+		if err != nil || time.Date(2000, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format("01-02") != value {
+			return "", invalid
+		}
+	case len("2021-12-07"):
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", invalid
+		}
+		month, day = int(date.Month()), date.Day()
+	default:
+		return "", invalid
+	}
+	return fmt.Sprintf("%02d-%02d", month, day), nil
+}
+
+// cityParticles are the words written lowercase within the names of the cities.
+var cityParticles = map[string]bool{
+	"di": true, "del": true, "della": true, "dei": true, "degli": true, "delle": true,
+	"d": true, "dell": true, "nel": true, "nell": true, "sul": true, "sull": true,
+	"in": true, "e": true, "al": true, "all": true,
+}
+
+// NormalizeCity trims the spaces of a city name and capitalizes its words, as L'Aquila or
+// Reggio nell'Emilia, leaving the particles following the first word lowercase.
+func NormalizeCity(name string) string {
+	words := strings.Fields(name)
+	for index, word := range words {
+		var normalized, segment strings.Builder
+		first := true
+		flush := func() {
+			if index > 0 && first && cityParticles[segment.String()] {
+				normalized.WriteString(segment.String())
+			} else {
+				normalized.WriteString(capitalize(segment.String()))
+			}
+			segment.Reset()
+			first = false
+		}
+		for _, r := range strings.ToLower(word) {
+			if r == '\'' || r == '’' || r == '-' {
+				flush()
+				normalized.WriteRune(r)
+				continue
+			}
+			segment.WriteRune(r)
+		}
+		flush()
+		words[index] = normalized.String()
+	}
+	return strings.Join(words, " ")
+}
+
+func capitalize(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return word
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// cityKey identifies a city regardless of the case and the spaces of its name.
+func cityKey(city string) string {
+	return strings.ToLower(strings.Join(strings.Fields(city), " "))
+}
+
+func readCSVEntries(r io.Reader) ([]importEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV: a header with the city, name and date columns is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for index, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = index
+	}
+	for _, required := range []string{"city", "name", "date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column in the CSV header", required)
+		}
+	}
+
+	entries := []importEntry{}
+	// the header is the first line, and the records are expected on a line each
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return record[index]
+		}
+		line++
+		entries = append(entries, importEntry{
+			line:     line,
+			city:     field("city"),
+			name:     field("name"),
+			date:     field("date"),
+			region:   field("region"),
+			province: field("province"),
+		})
+	}
+}
+
+// readICSEntries reads the events of an iCalendar file, unfolding its lines as RFC 5545 tells.
+func readICSEntries(r io.Reader) ([]importEntry, error) {
+	type contentLine struct {
+		number int
+		text   string
+	}
+	lines := []contentLine{}
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, contentLine{number: number, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[0].text) != "BEGIN:VCALENDAR" {
+		return nil, errors.New("not an iCalendar file: BEGIN:VCALENDAR is missing")
+	}
+
+	entries := []importEntry{}
+	var event *importEntry
+	for _, line := range lines {
+		colon := strings.Index(line.text, ":")
+		if colon < 0 {
+			continue
+		}
+		name, value := strings.ToUpper(line.text[:colon]), line.text[colon+1:]
+		if semicolon := strings.Index(name, ";"); semicolon >= 0 {
+			name = name[:semicolon]
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &importEntry{line: line.number}
+		case name == "END" && value == "VEVENT" && event != nil:
+			entries = append(entries, *event)
+			event = nil
+		case event == nil:
+			// the properties of the calendar and of other components are ignored
+		case name == "LOCATION":
+			event.city = unescapeICalText(value)
+		case name == "SUMMARY":
+			event.name = unescapeICalText(value)
+		case name == "DTSTART":
+			event.date = value
+			// dates and date times start with YYYYMMDD
+			if len(value) >= 8 {
+				event.date = value[:4] + "-" + value[4:6] + "-" + value[6:8]
+			}
+		case name == "X-FERIAPP-REGION":
+			event.region = unescapeICalText(value)
+		case name == "X-FERIAPP-PROVINCE":
+			event.province = unescapeICalText(value)
+		}
+	}
+	return entries, nil
+}
+
+func unescapeICalText(text string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, " ", `\N`, " ").Replace(text)
+}
+
+// PackChange is a city whose patron day is changed by an import.
+type PackChange struct {
+	Before Holiday `json:"before"`
+	After  Holiday `json:"after"`
+}
+
+// PackDiff tells how an import changes a language pack.
+type PackDiff struct {
+	Added   []Holiday    `json:"added"`
+	Changed []PackChange `json:"changed"`
+	// Removed are the cities missing from an import replacing the whole pack.
+	Removed   []Holiday `json:"removed"`
+	Unchanged int       `json:"unchanged"`
+}
+
+// MergeLanguagePack returns the language pack resulting from importing holidays into current,
+// sorted by city, and how it differs from current. Imported cities match the current ones
+// regardless of case, keeping their region and province when the import has none; the cities
+// of current missing from holidays are kept, unless replace is set.
+func MergeLanguagePack(current, holidays []Holiday, replace bool) ([]Holiday, PackDiff) {
+	diff := PackDiff{Added: []Holiday{}, Changed: []PackChange{}, Removed: []Holiday{}}
+	imported := make(map[string]bool, len(holidays))
+	currentByCity := make(map[string]Holiday, len(current))
+	for _, holiday := range current {
+		currentByCity[cityKey(holiday.City)] = holiday
+	}
+
+	merged := make([]Holiday, 0, len(current)+len(holidays))
+	for _, holiday := range holidays {
+		key := cityKey(holiday.City)
+		imported[key] = true
+		before, ok := currentByCity[key]
+		if !ok {
+			diff.Added = append(diff.Added, holiday)
+			merged = append(merged, holiday)
+			continue
+		}
+		holiday.City = before.City
+		if holiday.Region == "" {
+			holiday.Region = before.Region
+		}
+		if holiday.Province == "" {
+			holiday.Province = before.Province
+		}
+		// the imported files have no aliases
+		holiday.Aliases = before.Aliases
+		if reflect.DeepEqual(holiday, before) {
+			diff.Unchanged++
+		} else {
+			diff.Changed = append(diff.Changed, PackChange{Before: before, After: holiday})
+		}
+		merged = append(merged, holiday)
+	}
+	for _, holiday := range current {
+		if imported[cityKey(holiday.City)] {
+			continue
+		}
+		if replace {
+			diff.Removed = append(diff.Removed, holiday)
+			continue
+		}
+		diff.Unchanged++
+		merged = append(merged, holiday)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return cityKey(merged[i].City) < cityKey(merged[j].City) })
+	for _, list := range [][]Holiday{diff.Added, diff.Removed} {
+		sort.SliceStable(list, func(i, j int) bool { return cityKey(list[i].City) < cityKey(list[j].City) })
+	}
+	sort.SliceStable(diff.Changed, func(i, j int) bool {
+		return cityKey(diff.Changed[i].After.City) < cityKey(diff.Changed[j].After.City)
+	})
+	return merged, diff
+}
+
+// LoadLanguagePack reads the language pack of the locale, failing when it is missing or malformed.
+// The pack is parsed once per change of the file, and a copy of its holidays is returned.
+func LoadLanguagePack(locale string) ([]Holiday, error) {
+	pack, err := loadPack(locale)
+	if err != nil {
+		return nil, err
+	}
+	if pack.err != nil {
+		return nil, pack.err
+	}
+	return append([]Holiday{}, pack.holidays...), nil
+}
+
+// WriteLanguagePack replaces the language pack of the locale with holidays. The pack is
+// written to a temporary file renamed over the current one, so that it is never read
+// half written.
+func WriteLanguagePack(locale string, holidays []Holiday) error {
+	content, err := json.MarshalIndent(holidays, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	path := languagePackPath(locale)
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+locale+"-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	forgetPack(path)
+	return nil
+}
@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCity(t *testing.T) {
+	require.Equal(t, "Milano", NormalizeCity("  milano "))
+	require.Equal(t, "L'Aquila", NormalizeCity("L'AQUILA"))
+	require.Equal(t, "Reggio nell'Emilia", NormalizeCity("reggio   NELL'EMILIA"))
+	require.Equal(t, "Barcellona Pozzo di Gotto", NormalizeCity("barcellona pozzo di gotto"))
+	require.Equal(t, "Forlì-Cesena", NormalizeCity("FORLÌ-CESENA"))
+	require.Equal(t, "Di Milano", NormalizeCity("di milano"))
+}
+
+func TestParseHolidays(testCase *testing.T) {
+	testCase.Run("csv", func(t *testing.T) {
+		holidays, err := ParseHolidays(strings.NewReader("City,Date,Name,Province\n"+
+			" asti ,05-07,San Secondo,at\n"+
+			"Reggio nell'emilia,2021-11-24,San Prospero,\n"), ImportCSV)
+		require.NoError(t, err)
+		require.Equal(t, []Holiday{
+			{City: "Asti", Name: "San Secondo", Date: "05-07", Province: "AT"},
+			{City: "Reggio nell'Emilia", Name: "San Prospero", Date: "11-24"},
+		}, holidays)
+	})
+
+	testCase.Run("csv problems", func(t *testing.T) {
+		_, err := ParseHolidays(strings.NewReader("city,name,date\n"+
+			"Asti,San Secondo,02-30\n"+
+			"Milano,Sant'Ambrogio,12-07\n"+
+			"MILANO,Sant'Ambrogio,12-07\n"+
+			",San Nessuno,01-01\n"+
+			"Roma,,06-29\n"), ImportCSV)
+		var importError *ImportError
+		require.True(t, errors.As(err, &importError), err)
+		require.Equal(t, []string{
+			`line 2: invalid date "02-30": must be formatted as MM-DD or YYYY-MM-DD`,
+			`line 4: duplicate city "Milano", already at line 3`,
+			"line 5: missing city",
+			"line 6: missing name of the patron day of Roma",
+		}, importError.Problems)
+
+		_, err = ParseHolidays(strings.NewReader("city,name\nAsti,San Secondo\n"), ImportCSV)
+		require.EqualError(t, err, "missing date column in the CSV header")
+		_, err = ParseHolidays(strings.NewReader(""), ImportCSV)
+		require.Error(t, err)
+		_, err = ParseHolidays(strings.NewReader(""), "xlsx")
+		require.Error(t, err)
+	})
+
+	testCase.Run("leap day", func(t *testing.T) {
+		holidays, err := ParseHolidays(strings.NewReader("city,name,date\nAsti,San Secondo,02-29\n"), ImportCSV)
+		require.NoError(t, err)
+		require.Equal(t, "02-29", holidays[0].Date)
+	})
+
+	testCase.Run("ics", func(t *testing.T) {
+		calendar := strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VEVENT",
+			"DTSTART;VALUE=DATE:20210507",
+			"SUMMARY:San Secondo",
+			"LOCATION:asti",
+			"X-FERIAPP-PROVINCE:AT",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"DTSTART:20211124T000000Z",
+			"SUMMARY:San Prospero\\, patrono",
+			"LOCATION:Reggio nell'Emilia, ",
+			" Italia",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n")
+		holidays, err := ParseHolidays(strings.NewReader(calendar), ImportICS)
+		require.NoError(t, err)
+		require.Equal(t, []Holiday{
+			{City: "Asti", Name: "San Secondo", Date: "05-07", Province: "AT"},
+			{City: "Reggio nell'Emilia, Italia", Name: "San Prospero, patrono", Date: "11-24"},
+		}, holidays)
+
+		_, err = ParseHolidays(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:San Secondo\nLOCATION:Asti\nEND:VEVENT\nEND:VCALENDAR\n"), ImportICS)
+		require.EqualError(t, err, `invalid holidays: line 2: invalid date "": must be formatted as MM-DD or YYYY-MM-DD`)
+		_, err = ParseHolidays(strings.NewReader("city,name,date\n"), ImportICS)
+		require.Error(t, err)
+	})
+}
+
+func TestMergeLanguagePack(testCase *testing.T) {
+	current := []Holiday{
+		{City: "Milano", Name: "Sant'Ambrogio", Date: "12-07", Region: "Lombardia", Province: "MI"},
+		{City: "Asti", Name: "San Secondo", Date: "05-07", Region: "Piemonte", Province: "AT", Aliases: []string{"Asti Town"}},
+		{City: "Roma", Name: "Santi Pietro e Paolo", Date: "06-29", Region: "Lazio", Province: "RM", Aliases: []string{"Rome"}},
+	}
+	imported := []Holiday{
+		{City: "Asti", Name: "San Secondo", Date: "05-04"},
+		{City: "Roma", Name: "Santi Pietro e Paolo", Date: "06-29"},
+		{City: "Lodi", Name: "San Bassiano", Date: "01-19", Province: "LO"},
+	}
+
+	testCase.Run("merge", func(t *testing.T) {
+		merged, diff := MergeLanguagePack(current, imported, false)
+		require.Equal(t, []string{"Asti", "Lodi", "Milano", "Roma"}, cities(merged))
+		require.Equal(t, PackDiff{
+			Added: []Holiday{imported[2]},
+			Changed: []PackChange{{
+				Before: current[1],
+				After:  Holiday{City: "Asti", Name: "San Secondo", Date: "05-04", Region: "Piemonte", Province: "AT", Aliases: []string{"Asti Town"}},
+			}},
+			Removed:   []Holiday{},
+			Unchanged: 2,
+		}, diff)
+	})
+
+	testCase.Run("replace", func(t *testing.T) {
+		merged, diff := MergeLanguagePack(current, imported, true)
+		require.Equal(t, []string{"Asti", "Lodi", "Roma"}, cities(merged))
+		require.Equal(t, []Holiday{current[0]}, diff.Removed)
+		require.Equal(t, 1, diff.Unchanged)
+	})
+}
+
+func cities(holidays []Holiday) []string {
+	names := []string{}
+	for _, holiday := range holidays {
+		names = append(names, holiday.City)
+	}
+	return names
+}
+
+func TestWriteLanguagePack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feriapp-packs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Setenv("LANGUAGE_PACK_FILE_PATH", os.Getenv("LANGUAGE_PACK_FILE_PATH"))
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+
+	_, err = LoadLanguagePack("IT")
+	require.True(t, os.IsNotExist(err), err)
+
+	holidays := []Holiday{{City: "Asti", Name: "San Secondo", Date: "05-07", Region: "Piemonte", Province: "AT"}}
+	require.NoError(t, WriteLanguagePack("IT", holidays))
+	loaded, err := LoadLanguagePack("IT")
+	require.NoError(t, err)
+	require.Equal(t, holidays, loaded)
+	require.Equal(t, "05-07", ListHolidays(2021, "IT", "Asti")[12].Date.String()[5:])
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1, "no temporary file is left behind")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "IT.json"), []byte("{"), 0600))
+	_, err = LoadLanguagePack("IT")
+	require.Error(t, err)
+}
@@ -0,0 +1,248 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// LanguagePackSchema is the JSON Schema of the language packs listing the patron days of
+// the cities: an array of entries with the city, the name of the patron day and its date
+// as MM-DD, and optionally the region, the code of the province and the other names of the
+// city. The patron days falling on a different day every year, or on more days, keep their
+// date as text, as "1st tuesday in May", and are left out of the holidays.
+const LanguagePackSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "feriapp language pack",
+  "type": "array",
+  "items": {
+    "type": "object",
+    "required": ["city", "name", "date"],
+    "additionalProperties": false,
+    "properties": {
+      "city": {"type": "string", "minLength": 1},
+      "name": {"type": "string", "minLength": 1},
+      "date": {"type": "string", "minLength": 1},
+      "region": {"type": "string"},
+      "province": {"type": "string"},
+      "aliases": {"type": "array", "items": {"type": "string", "minLength": 1}}
+    }
+  }
+}
+`
+
+var languagePackSchema = mustLoadSchema(LanguagePackSchema)
+
+func mustLoadSchema(schema string) *gojsonschema.Schema {
+	loaded, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+// PackError lists the problems found in the language pack of a locale, each telling the
+// entry it was found in.
+type PackError struct {
+	Locale   string
+	Problems []string
+}
+
+func (e *PackError) Error() string {
+	return fmt.Sprintf("invalid language pack %s: %s", e.Locale, strings.Join(e.Problems, "; "))
+}
+
+// LanguagePackLocales returns the locales whose holidays include the patron days listed by
+// a language pack.
+func LanguagePackLocales() []string {
+	locales := []string{}
+	for locale, country := range countries {
+		if country.patrons {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// CheckLanguagePack reads and validates the language pack of the locale, failing when it is
+// missing, malformed or invalid, and returns the warnings found in a valid one.
+func CheckLanguagePack(locale string) ([]string, error) {
+	content, err := ioutil.ReadFile(languagePackPath(locale))
+	if err != nil {
+		return nil, err
+	}
+	return ValidateLanguagePack(locale, content)
+}
+
+// ValidateHolidays validates holidays as the language pack of the locale.
+func ValidateHolidays(locale string, holidays []Holiday) ([]string, error) {
+	content, err := json.Marshal(holidays)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateLanguagePack(locale, content)
+}
+
+type packProblem struct {
+	entry   int
+	message string
+}
+
+// ValidateLanguagePack checks content against LanguagePackSchema, and then that the MM-DD
+// dates of the entries exist, that every city is named only once, aliases included, and, for
+// the locales whose regions are known, that regions exist and each province lies in the
+// region of the entry. The problems are returned as a *PackError.
+// The entries which are valid but only in part, as the patron days without a fixed date,
+// which are left out, and the provinces not known, are returned as warnings.
+func ValidateLanguagePack(locale string, content []byte) ([]string, error) {
+	result, err := languagePackSchema.Validate(gojsonschema.NewBytesLoader(content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing language pack %s: %w", locale, err)
+	}
+	problems := []packProblem{}
+	warnings := []packProblem{}
+	invalid := map[int]bool{}
+	for _, resultError := range result.Errors() {
+		entry, field := schemaErrorEntry(resultError.Field())
+		invalid[entry] = true
+		message := resultError.Description()
+		if field != "" {
+			message = field + ": " + message
+		}
+		problems = append(problems, packProblem{entry: entry, message: message})
+	}
+
+	var holidays []Holiday
+	if err := json.Unmarshal(content, &holidays); err != nil {
+		// the schema problems already tell what is wrong with the types of the entries
+		return nil, packError(locale, nil, problems)
+	}
+	provinces := countries[locale].provinces
+	regions := map[string]bool{}
+	for _, region := range provinces {
+		regions[region] = true
+	}
+	seen := map[string]int{}
+	for i, holiday := range holidays {
+		if invalid[i] {
+			continue
+		}
+		if !fixedDatePattern.MatchString(holiday.Date) {
+			warnings = append(warnings, packProblem{entry: i, message: fmt.Sprintf("date %q is not a fixed MM-DD day: the patron day is left out", holiday.Date)})
+		} else if _, err := parsePatronDate(holiday.Date); err != nil {
+			problems = append(problems, packProblem{entry: i, message: err.Error()})
+		}
+		for n, name := range cityNames(holiday) {
+			key := matchKey(name)
+			first, ok := seen[key]
+			switch {
+			case !ok:
+				seen[key] = i
+			case n == 0:
+				problems = append(problems, packProblem{entry: i, message: fmt.Sprintf("duplicate city, already at entry %d", first+1)})
+			default:
+				problems = append(problems, packProblem{entry: i, message: fmt.Sprintf("alias %q already names entry %d", name, first+1)})
+			}
+		}
+		if provinces == nil {
+			continue
+		}
+		if holiday.Region != "" && !regions[holiday.Region] {
+			problems = append(problems, packProblem{entry: i, message: fmt.Sprintf("unknown region %q", holiday.Region)})
+		}
+		if holiday.Province == "" {
+			continue
+		}
+		region, ok := provinces[holiday.Province]
+		if !ok {
+			warnings = append(warnings, packProblem{entry: i, message: fmt.Sprintf("unknown province %q", holiday.Province)})
+		} else if holiday.Region != "" && regions[holiday.Region] && region != holiday.Region {
+			problems = append(problems, packProblem{entry: i, message: fmt.Sprintf("province %s is in %s, not in %s", holiday.Province, region, holiday.Region)})
+		}
+	}
+	return packMessages(holidays, warnings), packError(locale, holidays, problems)
+}
+
+// fixedDatePattern matches the dates of the patron days falling on the same day every year.
+var fixedDatePattern = regexp.MustCompile(`^[0-9]{2}-[0-9]{2}$`)
+
+// schemaErrorEntry splits the field of a schema error, as 3.date, into the index of the entry
+// and the property. Errors about the whole pack have entry -1.
+func schemaErrorEntry(field string) (int, string) {
+	parts := strings.SplitN(field, ".", 2)
+	entry, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return -1, ""
+	}
+	if len(parts) == 1 {
+		return entry, ""
+	}
+	return entry, parts[1]
+}
+
+func packError(locale string, holidays []Holiday, problems []packProblem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &PackError{Locale: locale, Problems: packMessages(holidays, problems)}
+}
+
+// packMessages sorts the problems by entry and tells the entry of each, with its city.
+func packMessages(holidays []Holiday, problems []packProblem) []string {
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].entry < problems[j].entry })
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		switch {
+		case problem.entry < 0:
+			messages = append(messages, problem.message)
+		case problem.entry < len(holidays) && holidays[problem.entry].City != "":
+			messages = append(messages, fmt.Sprintf("entry %d (%s): %s", problem.entry+1, holidays[problem.entry].City, problem.message))
+		default:
+			messages = append(messages, fmt.Sprintf("entry %d: %s", problem.entry+1, problem.message))
+		}
+	}
+	return messages
+}
+
+// italianProvinces maps the codes of the Italian provinces to their region, spelled as in
+// the language pack. The codes of the Sardinian provinces abolished in 2016 are kept, as
+// the pack still refers to them.
+var italianProvinces = map[string]string{
+	"AQ": "Abruzzo", "CH": "Abruzzo", "PE": "Abruzzo", "TE": "Abruzzo",
+	"MT": "Basilicata", "PZ": "Basilicata",
+	"CS": "Calabria", "CZ": "Calabria", "KR": "Calabria", "RC": "Calabria", "VV": "Calabria",
+	"AV": "Campania", "BN": "Campania", "CE": "Campania", "NA": "Campania", "SA": "Campania",
+	"BO": "Emilia Romagna", "FC": "Emilia Romagna", "FE": "Emilia Romagna", "MO": "Emilia Romagna",
+	"PC": "Emilia Romagna", "PR": "Emilia Romagna", "RA": "Emilia Romagna", "RE": "Emilia Romagna",
+	"RN": "Emilia Romagna",
+	"GO": "Friuli Venezia Giulia", "PN": "Friuli Venezia Giulia", "TS": "Friuli Venezia Giulia",
+	"UD": "Friuli Venezia Giulia",
+	"FR": "Lazio", "LT": "Lazio", "RI": "Lazio", "RM": "Lazio", "VT": "Lazio",
+	"GE": "Liguria", "IM": "Liguria", "SP": "Liguria", "SV": "Liguria",
+	"BG": "Lombardia", "BS": "Lombardia", "CO": "Lombardia", "CR": "Lombardia", "LC": "Lombardia",
+	"LO": "Lombardia", "MB": "Lombardia", "MI": "Lombardia", "MN": "Lombardia", "PV": "Lombardia",
+	"SO": "Lombardia", "VA": "Lombardia",
+	"AN": "Marche", "AP": "Marche", "FM": "Marche", "MC": "Marche", "PU": "Marche",
+	"CB": "Molise", "IS": "Molise",
+	"AL": "Piemonte", "AT": "Piemonte", "BI": "Piemonte", "CN": "Piemonte", "NO": "Piemonte",
+	"TO": "Piemonte", "VB": "Piemonte", "VC": "Piemonte",
+	"BA": "Puglia", "BR": "Puglia", "BT": "Puglia", "FG": "Puglia", "LE": "Puglia", "TA": "Puglia",
+	"CA": "Sardegna", "NU": "Sardegna", "OR": "Sardegna", "SS": "Sardegna", "SU": "Sardegna",
+	"CI": "Sardegna", "OG": "Sardegna", "OT": "Sardegna", "VS": "Sardegna",
+	"AG": "Sicilia", "CL": "Sicilia", "CT": "Sicilia", "EN": "Sicilia", "ME": "Sicilia",
+	"PA": "Sicilia", "RG": "Sicilia", "SR": "Sicilia", "TP": "Sicilia",
+	"AR": "Toscana", "FI": "Toscana", "GR": "Toscana", "LI": "Toscana", "LU": "Toscana",
+	"MS": "Toscana", "PI": "Toscana", "PO": "Toscana", "PT": "Toscana", "SI": "Toscana",
+	"BZ": "Trentino Alto Adige", "TN": "Trentino Alto Adige",
+	"PG": "Umbria", "TR": "Umbria",
+	"AO": "Valle d'Aosta",
+	"BL": "Veneto", "PD": "Veneto", "RO": "Veneto", "TV": "Veneto", "VE": "Veneto", "VI": "Veneto",
+	"VR": "Veneto",
+}
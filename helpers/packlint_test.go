@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLanguagePack(testCase *testing.T) {
+	testCase.Run("shipped pack", func(t *testing.T) {
+		os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+		require.Equal(t, []string{"IT"}, LanguagePackLocales())
+		warnings, err := CheckLanguagePack("IT")
+		require.NoError(t, err)
+		// the patron days without a fixed date and the province of Roma
+		require.Len(t, warnings, 6)
+		require.Contains(t, warnings, `entry 7 (Asti): date "1st tuesday in May" is not a fixed MM-DD day: the patron day is left out`)
+	})
+
+	testCase.Run("schema", func(t *testing.T) {
+		_, err := ValidateLanguagePack("IT", []byte(`[
+			{"city": "Asti", "name": "San Secondo", "date": "", "region": "Piemonte", "province": "AT"},
+			{"city": "Lodi", "name": "San Bassiano"},
+			{"city": "Roma", "name": "Santi Pietro e Paolo", "date": "06-29", "aliases": [""]},
+			{"city": "Milano", "name": "Sant'Ambrogio", "date": "12-07", "saint": "Ambrogio"}
+		]`))
+		var packError *PackError
+		require.True(t, errors.As(err, &packError), err)
+		require.Equal(t, "IT", packError.Locale)
+		require.Equal(t, []string{
+			"entry 1 (Asti): date: String length must be greater than or equal to 1",
+			"entry 2 (Lodi): date is required",
+			"entry 3 (Roma): aliases.0: String length must be greater than or equal to 1",
+			"entry 4 (Milano): Additional property saint is not allowed",
+		}, packError.Problems)
+
+		_, err = ValidateLanguagePack("IT", []byte(`{"city": "Asti"}`))
+		require.True(t, errors.As(err, &packError), err)
+		require.Equal(t, []string{"Invalid type. Expected: array, given: object"}, packError.Problems)
+		_, err = ValidateLanguagePack("IT", []byte(`[{"city": 1, "name": "San Secondo", "date": "05-07"}]`))
+		require.True(t, errors.As(err, &packError), err)
+		require.Equal(t, []string{"entry 1: city: Invalid type. Expected: string, given: integer"}, packError.Problems)
+
+		_, err = ValidateLanguagePack("IT", []byte(`[`))
+		require.Error(t, err)
+		require.False(t, errors.As(err, &packError))
+	})
+
+	testCase.Run("content", func(t *testing.T) {
+		warnings, err := ValidateHolidays("IT", []Holiday{
+			{City: "Asti", Name: "San Secondo", Date: "02-30", Region: "Piemonte", Province: "AT"},
+			{City: "Milano", Name: "Sant'Ambrogio", Date: "12-07", Region: "Lombardia", Province: "MI"},
+			{City: "MILANO", Name: "Sant'Ambrogio", Date: "12-07"},
+			{City: "Crema", Name: "San Pantaleone", Date: "06-10", Region: "Lombardy", Province: "CR"},
+			{City: "Lodi", Name: "San Bassiano", Date: "01-19", Region: "Piemonte", Province: "LO"},
+			{City: "Novara", Name: "San Gaudenzio", Date: "01-22", Province: "NX"},
+			{City: "Leap", Name: "San Leap", Date: "02-29"},
+			{City: "Bolzano", Name: "Santa Maria Assunta", Date: "lunedi di Pentecoste", Region: "Trentino Alto Adige", Province: "BZ"},
+		})
+		var packError *PackError
+		require.True(t, errors.As(err, &packError), err)
+		require.Equal(t, []string{
+			`entry 1 (Asti): invalid date "02-30": must be formatted as MM-DD or YYYY-MM-DD`,
+			"entry 3 (MILANO): duplicate city, already at entry 2",
+			`entry 4 (Crema): unknown region "Lombardy"`,
+			"entry 5 (Lodi): province LO is in Lombardia, not in Piemonte",
+		}, packError.Problems)
+		require.Equal(t, []string{
+			`entry 6 (Novara): unknown province "NX"`,
+			`entry 8 (Bolzano): date "lunedi di Pentecoste" is not a fixed MM-DD day: the patron day is left out`,
+		}, warnings)
+	})
+
+	testCase.Run("warnings only", func(t *testing.T) {
+		warnings, err := ValidateHolidays("IT", []Holiday{
+			{City: "Pordenone", Name: "San Marco Evangelista", Date: "04-25 e 09-08", Region: "Friuli Venezia Giulia", Province: "PN"},
+			{City: "Roma", Name: "Santi Pietro e Paolo", Date: "06-29", Region: "Lazio", Province: "Roma"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`entry 1 (Pordenone): date "04-25 e 09-08" is not a fixed MM-DD day: the patron day is left out`,
+			`entry 2 (Roma): unknown province "Roma"`,
+		}, warnings)
+	})
+
+	testCase.Run("missing pack", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "feriapp-packs")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+		defer os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+		_, err = CheckLanguagePack("IT")
+		require.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestListHolidaysMalformedPack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feriapp-packs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+	defer os.Setenv("LANGUAGE_PACK_FILE_PATH", "./")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "IT.json"), []byte(`[{"city": "Asti", "name": "San Secondo", "date": "12"}]`), 0600))
+	require.Len(t, ListHolidays(2021, "IT", "Asti"), 12, "a malformed date is skipped")
+
+	before := LanguagePackStats()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "IT.json"), []byte(`{`), 0600))
+	require.Len(t, ListHolidays(2021, "IT", "Asti"), 12)
+	require.Equal(t, before.Errors+1, LanguagePackStats().Errors)
+	holidays, err := LoadHolidays(2021, "IT", "Asti")
+	require.Error(t, err)
+	require.Len(t, holidays, 12, "the national holidays are returned with the error")
+
+	holidays, err = LoadHolidays(-1, "IT", "Asti")
+	require.EqualError(t, err, "computing the Easter of -1: year have to be greater than 0")
+	require.Nil(t, holidays)
+}
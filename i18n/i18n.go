@@ -0,0 +1,232 @@
+// Package i18n translates the text of the responses using message catalogs,
+// one JSON file per language mapping message keys to their text.
+//
+// Languages are chosen with a fallback chain: every preferred language, first with
+// its region (en-GB) and then without it (en), followed by the default language of
+// the bundle. Messages missing from every catalog of the chain are returned as keys.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"feriapp-backend-go/helpers"
+)
+
+// Catalog maps message keys to their text in a language. Texts can contain
+// placeholders, as {holiday}, replaced by the arguments of the message.
+type Catalog map[string]string
+
+// Bundle holds the catalogs of every supported language.
+type Bundle struct {
+	catalogs        map[string]Catalog
+	defaultLanguage string
+}
+
+// NewBundle creates a bundle without catalogs, falling back to defaultLanguage.
+func NewBundle(defaultLanguage string) *Bundle {
+	return &Bundle{catalogs: map[string]Catalog{}, defaultLanguage: normalize(defaultLanguage)}
+}
+
+// LoadDir creates a bundle with the catalogs of dir, named after their language as it.json.
+// The catalog of the default language must exist.
+func LoadDir(dir string, defaultLanguage string) (*Bundle, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	bundle := NewBundle(defaultLanguage)
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading message catalog: %w", err)
+		}
+		var catalog Catalog
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			return nil, fmt.Errorf("parsing message catalog %s: %w", path, err)
+		}
+		bundle.Add(strings.TrimSuffix(filepath.Base(path), ".json"), catalog)
+	}
+	if _, ok := bundle.catalogs[bundle.defaultLanguage]; !ok {
+		return nil, fmt.Errorf("missing message catalog of the default language %q in %s", defaultLanguage, dir)
+	}
+	return bundle, nil
+}
+
+// Add sets the catalog of a language, replacing the previous one.
+func (b *Bundle) Add(language string, catalog Catalog) {
+	b.catalogs[normalize(language)] = catalog
+}
+
+// Languages returns the languages with a catalog, sorted.
+func (b *Bundle) Languages() []string {
+	languages := make([]string, 0, len(b.catalogs))
+	for language := range b.catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Localizer returns a localizer for the preferred languages, in order of preference.
+func (b *Bundle) Localizer(preferences ...string) *Localizer {
+	localizer := &Localizer{}
+	seen := map[string]bool{}
+	add := func(language string) {
+		catalog, ok := b.catalogs[language]
+		if !ok || seen[language] {
+			return
+		}
+		seen[language] = true
+		if localizer.language == "" {
+			localizer.language = language
+		}
+		localizer.chain = append(localizer.chain, catalog)
+	}
+	for _, preference := range preferences {
+		language := normalize(preference)
+		add(language)
+		if base := strings.SplitN(language, "-", 2)[0]; base != language {
+			add(base)
+		}
+	}
+	add(b.defaultLanguage)
+	if localizer.language == "" {
+		localizer.language = b.defaultLanguage
+	}
+	return localizer
+}
+
+func normalize(language string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(language), "_", "-", -1))
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header sorted by preference.
+// Wildcards and languages with zero quality are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		quality  float64
+	}
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		language := strings.TrimSpace(fields[0])
+		if language == "" || language == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		if quality > 0 {
+			languages = append(languages, weighted{language: language, quality: quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+	result := make([]string, 0, len(languages))
+	for _, language := range languages {
+		result = append(result, language.language)
+	}
+	return result
+}
+
+// Localizer translates messages following a fallback chain of catalogs.
+type Localizer struct {
+	language string
+	chain    []Catalog
+}
+
+// Language returns the first language of the chain, the one the messages are meant to be in.
+func (l *Localizer) Language() string {
+	return l.language
+}
+
+// Lookup returns the text of a message from the first catalog of the chain defining it.
+func (l *Localizer) Lookup(key string) (string, bool) {
+	for _, catalog := range l.chain {
+		if text, ok := catalog[key]; ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+// lookupAny returns the text of the first of keys defined by a catalog, trying every key in a
+// catalog before moving to the next one, so that a generic message of a preferred language
+// wins over a specific one of a fallback language.
+func (l *Localizer) lookupAny(keys ...string) (string, string, bool) {
+	for _, catalog := range l.chain {
+		for _, key := range keys {
+			if text, ok := catalog[key]; ok {
+				return text, key, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Message returns the text of a message with its placeholders replaced by args,
+// given as name and value pairs. The key is returned when no catalog defines the message.
+func (l *Localizer) Message(key string, args ...string) string {
+	text, ok := l.Lookup(key)
+	if !ok {
+		return key
+	}
+	return replace(text, args)
+}
+
+func replace(text string, args []string) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		text = strings.Replace(text, "{"+args[i]+"}", args[i+1], -1)
+	}
+	return text
+}
+
+// HolidayName returns the name of the holiday in the language of the localizer. Holidays
+// without a key, as the custom ones, keep their name. The days an holiday is observed on
+// are named after it.
+func (l *Localizer) HolidayName(holiday helpers.HolidayDate) string {
+	name := holiday.Name
+	if holiday.Key != "" {
+		if text, ok := l.Lookup("holiday." + holiday.Key); ok {
+			name = replace(text, []string{"name", holiday.Name})
+		}
+	}
+	if holiday.Observed {
+		if text, ok := l.Lookup("holiday.observed"); ok {
+			return replace(text, []string{"name", name})
+		}
+	}
+	return name
+}
+
+// BridgeLabel returns the label of a bridge built around the holiday, as "Ponte di Ognissanti",
+// or a generic label when holiday is nil.
+func (l *Localizer) BridgeLabel(holiday *helpers.HolidayDate) string {
+	if holiday == nil {
+		return l.Message("bridge.generic")
+	}
+	keys := []string{"bridge"}
+	if holiday.Key != "" {
+		keys = []string{"bridge." + holiday.Key, "bridge"}
+	}
+	text, key, ok := l.lookupAny(keys...)
+	if !ok {
+		return "bridge"
+	}
+	if key != "bridge" {
+		return replace(text, []string{"name", holiday.Name})
+	}
+	return replace(text, []string{"holiday", l.HolidayName(*holiday)})
+}
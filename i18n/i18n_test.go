@@ -0,0 +1,112 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBundle() *Bundle {
+	bundle := NewBundle("it")
+	bundle.Add("it", Catalog{"bridge": "Ponte di {holiday}", "bridge.generic": "Ponte", "holiday.all-saints": "Ognissanti", "only.it": "solo italiano", "holiday.patron": "{name}"})
+	bundle.Add("en", Catalog{"bridge": "{holiday} bridge", "bridge.all-saints": "All Saints' bridge", "holiday.all-saints": "All Saints' Day", "holiday.patron": "{name} (patron saint's day)", "bridge.patron": "{name} bridge", "holiday.observed": "{name} (observed)"})
+	bundle.Add("en-US", Catalog{"holiday.all-saints": "All Saints Day"})
+	return bundle
+}
+
+func TestLocalizer(testCase *testing.T) {
+	testCase.Run("regional catalogs fall back to their language and to the default one", func(t *testing.T) {
+		localizer := testBundle().Localizer("en-US")
+		require.Equal(t, "en-us", localizer.Language())
+		require.Equal(t, "All Saints Day", localizer.Message("holiday.all-saints"))
+		require.Equal(t, "All Saints' bridge", localizer.Message("bridge.all-saints"))
+		require.Equal(t, "solo italiano", localizer.Message("only.it"))
+		require.Equal(t, "missing", localizer.Message("missing"))
+	})
+
+	testCase.Run("unknown languages are skipped", func(t *testing.T) {
+		localizer := testBundle().Localizer("fr-FR", "en_GB")
+		require.Equal(t, "en", localizer.Language())
+		require.Equal(t, "it", testBundle().Localizer("fr").Language())
+		require.Equal(t, "it", testBundle().Localizer().Language())
+	})
+
+	testCase.Run("placeholders", func(t *testing.T) {
+		require.Equal(t, "Ponte di Natale", testBundle().Localizer("it").Message("bridge", "holiday", "Natale"))
+	})
+
+	testCase.Run("holidays and bridges", func(t *testing.T) {
+		allSaints := helpers.HolidayDate{Date: civil.NewDate(2030, 11, 1), Name: "Ognissanti", Key: "all-saints"}
+		patron := helpers.HolidayDate{Date: civil.NewDate(2030, 12, 7), Name: "Sant'Ambrogio", Key: helpers.PatronKey}
+		custom := helpers.HolidayDate{Date: civil.NewDate(2030, 8, 16), Name: "San Rocco"}
+
+		en := testBundle().Localizer("en")
+		require.Equal(t, "All Saints' Day", en.HolidayName(allSaints))
+		require.Equal(t, "Sant'Ambrogio (patron saint's day)", en.HolidayName(patron))
+		require.Equal(t, "San Rocco", en.HolidayName(custom))
+		observed := allSaints
+		observed.Observed = true
+		require.Equal(t, "All Saints' Day (observed)", en.HolidayName(observed))
+		require.Equal(t, "Ognissanti", testBundle().Localizer("it").HolidayName(observed))
+		require.Equal(t, "All Saints' bridge", en.BridgeLabel(&allSaints))
+		require.Equal(t, "San Rocco bridge", en.BridgeLabel(&custom))
+		require.Equal(t, "Ponte", en.BridgeLabel(nil))
+
+		it := testBundle().Localizer("it")
+		require.Equal(t, "Ponte di Ognissanti", it.BridgeLabel(&allSaints))
+		require.Equal(t, "Ponte di Sant'Ambrogio", it.BridgeLabel(&patron))
+		// the generic label of a preferred language wins over the specific one of a fallback
+		require.Equal(t, "Ponte di Sant'Ambrogio", testBundle().Localizer("it", "en").BridgeLabel(&patron))
+	})
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	require.Equal(t, []string{"fr-CH", "en", "de"}, ParseAcceptLanguage("de;q=0.7, fr-CH, en;q=0.9, *;q=0.5, it;q=0"))
+	require.Equal(t, []string{}, ParseAcceptLanguage(""))
+}
+
+func TestLoadDir(testCase *testing.T) {
+	testCase.Run("shipped catalogs", func(t *testing.T) {
+		bundle, err := LoadDir("./catalogs/", "it")
+		require.NoError(t, err)
+		require.Equal(t, []string{"en", "it"}, bundle.Languages())
+
+		// every message of the default language is translated
+		for key := range bundle.catalogs["it"] {
+			_, ok := bundle.catalogs["en"][key]
+			require.True(t, ok, key)
+		}
+		// every national holiday of every country is named in every language
+		for _, country := range helpers.Countries() {
+			holidays, err := helpers.LoadHolidays(2030, country, "")
+			require.NoError(t, err)
+			for _, holiday := range holidays {
+				for _, language := range bundle.Languages() {
+					_, ok := bundle.catalogs[language]["holiday."+holiday.Key]
+					require.True(t, ok, "%s: holiday.%s of %s", language, holiday.Key, country)
+				}
+			}
+		}
+	})
+
+	testCase.Run("missing default language", func(t *testing.T) {
+		_, err := LoadDir("./catalogs/", "de")
+		require.Error(t, err)
+	})
+
+	testCase.Run("invalid catalog", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "feriapp-catalogs")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "it.json"), []byte("{"), 0600))
+
+		_, err = LoadDir(dir, "it")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"feriapp-backend-go/helpers"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+var errAdminNeeded = errors.New("administrator rights required")
+
+// packImportResult tells how an import changed, or would change when DryRun is set,
+// the language pack of Locale. Version is the digest of the pack written and Warnings
+// lists the entries of the pack which are not fully usable.
+type packImportResult struct {
+	Locale   string   `json:"locale"`
+	DryRun   bool     `json:"dryRun"`
+	Version  string   `json:"version,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	helpers.PackDiff
+}
+
+func setupLanguagePacksRouter(router *mux.Router, env EnvironmentVariables) {
+	admins := map[string]bool{}
+	for _, subject := range strings.Split(env.AdminSubjects, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			admins[subject] = true
+		}
+	}
+	router.HandleFunc("/admin/language-packs/{locale}/import", importLanguagePack(admins, &sync.Mutex{})).Methods(http.MethodPost)
+}
+
+// importLanguagePack merges the patron days of the request body, in CSV or iCalendar format,
+// into the language pack of the locale. Only the subjects in admins are allowed, and imports
+// are serialized by lock since each of them rewrites the whole pack, which must still be a
+// valid language pack after the import.
+func importLanguagePack(admins map[string]bool, lock *sync.Mutex) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		identity, ok := identityFromContext(req.Context())
+		if !ok {
+			http.Error(w, errAuthenticationNeeded.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !admins[identity.Subject] {
+			http.Error(w, errAdminNeeded.Error(), http.StatusForbidden)
+			return
+		}
+		locale := mux.Vars(req)["locale"]
+		if !helpers.HasLanguagePack(locale) {
+			http.Error(w, fmt.Sprintf("locale %q has no language pack of patron days", locale), http.StatusNotFound)
+			return
+		}
+		format, dryRun, replace, err := parsePackImportQuery(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		holidays, err := helpers.ParseHolidays(req.Body, format)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		current, err := helpers.LoadLanguagePack(locale)
+		if err != nil && !os.IsNotExist(err) {
+			glogger.Get(req.Context()).WithError(err).Error("failed reading the language pack")
+			http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+			return
+		}
+		merged, diff := helpers.MergeLanguagePack(current, holidays, replace)
+		warnings, err := helpers.ValidateHolidays(locale, merged)
+		if err != nil {
+			var packError *helpers.PackError
+			if errors.As(err, &packError) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			glogger.Get(req.Context()).WithError(err).Error("failed validating the language pack")
+			http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+			return
+		}
+		result := packImportResult{Locale: locale, DryRun: dryRun, Warnings: warnings, PackDiff: diff}
+		if !dryRun {
+			if err := helpers.WriteLanguagePack(locale, merged); err != nil {
+				glogger.Get(req.Context()).WithError(err).Error("failed writing the language pack")
+				http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+				return
+			}
+			result.Version = helpers.LanguagePackVersion(locale)
+			glogger.Get(req.Context()).WithField("subject", identity.Subject).WithField("locale", locale).
+				WithField("added", len(diff.Added)).WithField("changed", len(diff.Changed)).WithField("removed", len(diff.Removed)).
+				Info("language pack imported")
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, result)
+	}
+}
+
+// parsePackImportQuery reads the options of an import: the format, taken from the Content-Type
+// header when the format query parameter is missing, and the dryRun and replace flags.
+func parsePackImportQuery(req *http.Request) (string, bool, bool, error) {
+	query := req.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = helpers.ImportCSV
+		case "text/calendar":
+			format = helpers.ImportICS
+		default:
+			return "", false, false, fmt.Errorf("unknown import format: set the format query parameter to %s or %s", helpers.ImportCSV, helpers.ImportICS)
+		}
+	}
+	if format != helpers.ImportCSV && format != helpers.ImportICS {
+		return "", false, false, fmt.Errorf("invalid format %q: must be %s or %s", format, helpers.ImportCSV, helpers.ImportICS)
+	}
+	dryRun, err := parseBoolParam(query, "dryRun")
+	if err != nil {
+		return "", false, false, err
+	}
+	replace, err := parseBoolParam(query, "replace")
+	if err != nil {
+		return "", false, false, err
+	}
+	return format, dryRun, replace, nil
+}
+
+func parseBoolParam(query url.Values, name string) (bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q: must be true or false", name, value)
+	}
+	return parsed, nil
+}
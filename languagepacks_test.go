@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"feriapp-backend-go/helpers"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguagePacksRoutes(testCase *testing.T) {
+	dir, err := ioutil.TempDir("", "feriapp-packs")
+	require.NoError(testCase, err)
+	defer os.RemoveAll(dir)
+	pack, err := ioutil.ReadFile("./helpers/IT.json")
+	require.NoError(testCase, err)
+	require.NoError(testCase, ioutil.WriteFile(filepath.Join(dir, "IT.json"), pack, 0600))
+	defer os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", dir+string(filepath.Separator))
+
+	testRouter := mux.NewRouter()
+	// the test subject header stands in for the authentication middleware
+	testRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if subject := req.Header.Get("X-Test-Subject"); subject != "" {
+				req = req.WithContext(contextWithIdentity(req.Context(), Identity{Subject: subject, Method: authMethodAPIKey}))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	setupLanguagePacksRouter(testRouter, EnvironmentVariables{AdminSubjects: "ops, data-team"})
+
+	serve := func(target, subject, contentType, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		if subject != "" {
+			request.Header.Set("X-Test-Subject", subject)
+		}
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	csv := "city,name,date,region,province\nMilano,Sant'Ambrogio,12-08,,\ncrema,San Pantaleone,06-10,Lombardia,CR\n"
+
+	testCase.Run("administrators only", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve("/admin/language-packs/IT/import", "", "text/csv", csv).Code)
+		require.Equal(t, http.StatusForbidden, serve("/admin/language-packs/IT/import", "someone", "text/csv", csv).Code)
+	})
+
+	testCase.Run("invalid imports", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, serve("/admin/language-packs/GB/import", "ops", "text/csv", csv).Code)
+		require.Equal(t, http.StatusBadRequest, serve("/admin/language-packs/IT/import", "ops", "application/json", csv).Code)
+		require.Equal(t, http.StatusBadRequest, serve("/admin/language-packs/IT/import?dryRun=maybe", "ops", "text/csv", csv).Code)
+
+		responseRecorder := serve("/admin/language-packs/IT/import?format=csv", "ops", "", "city,name,date\nLodi,San Bassiano,01-32\n")
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), `line 2: invalid date "01-32"`)
+
+		responseRecorder = serve("/admin/language-packs/IT/import?dryRun=true", "ops", "text/csv", "city,name,date,region,province\nCrema,San Pantaleone,06-10,Lombardy,CR\n")
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+		require.Contains(t, responseRecorder.Body.String(), `(Crema): unknown region "Lombardy"`)
+	})
+
+	testCase.Run("dry run", func(t *testing.T) {
+		responseRecorder := serve("/admin/language-packs/IT/import?dryRun=true", "ops", "text/csv", csv)
+		require.Equal(t, http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+		var result packImportResult
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &result))
+		require.True(t, result.DryRun)
+		require.Empty(t, result.Version)
+		require.Equal(t, []helpers.Holiday{{City: "Crema", Name: "San Pantaleone", Date: "06-10", Region: "Lombardia", Province: "CR"}}, result.Added)
+		require.Len(t, result.Changed, 1)
+		require.Equal(t, "12-07", result.Changed[0].Before.Date)
+		require.Equal(t, "Lombardia", result.Changed[0].After.Region)
+		require.Empty(t, result.Removed)
+		require.Contains(t, strings.Join(result.Warnings, "\n"), `(Roma): unknown province "Roma"`, "the entries not fully usable are told")
+
+		current, err := ioutil.ReadFile(filepath.Join(dir, "IT.json"))
+		require.NoError(t, err)
+		require.Equal(t, pack, current, "a dry run leaves the pack as it is")
+	})
+
+	testCase.Run("import", func(t *testing.T) {
+		version := helpers.LanguagePackVersion("IT")
+		responseRecorder := serve("/admin/language-packs/IT/import", "data-team", "text/csv; charset=utf-8", csv)
+		require.Equal(t, http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+		var result packImportResult
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &result))
+		require.False(t, result.DryRun)
+		require.NotEqual(t, version, result.Version)
+		require.Equal(t, helpers.LanguagePackVersion("IT"), result.Version)
+
+		holidays := helpers.ListHolidays(2030, "IT", "Crema")
+		require.Equal(t, "San Pantaleone", holidays[len(holidays)-1].Name)
+		require.Equal(t, "2030-12-08", helpers.ListHolidays(2030, "IT", "Milano")[12].Date.String())
+	})
+}
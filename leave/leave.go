@@ -0,0 +1,407 @@
+// Package leave models how the leave of an employee is accrued, carried over into the
+// next year and spent, to tell the balance available on a given day.
+//
+// Amounts are kept in hours, since permits such as the Italian ROL and ex-festività
+// are counted in hours while holidays are counted in days: Policy.HoursPerDay converts
+// between the two.
+package leave
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"feriapp-backend-go/civil"
+)
+
+// Accrual tells when the hours of an entitlement become available.
+type Accrual string
+
+const (
+	// Monthly accrues a twelfth of the hours on the last day of every month.
+	Monthly Accrual = "monthly"
+	// Yearly accrues all the hours on the first day of the year.
+	Yearly Accrual = "yearly"
+)
+
+// Entitlement is a kind of leave granted every year.
+type Entitlement struct {
+	Name    string  `json:"name"`
+	Hours   float64 `json:"hours"`
+	Accrual Accrual `json:"accrual"`
+	// CarryOverHours is the maximum number of hours left at the end of a year that can be
+	// used in the following ones: the others are lost. A negative value carries them all.
+	CarryOverHours float64 `json:"carryOverHours"`
+	// CarryOverMonths is how long the carried hours last after the end of the year,
+	// they never expire when zero.
+	CarryOverMonths int `json:"carryOverMonths"`
+}
+
+// Policy is the set of entitlements of a contract.
+type Policy struct {
+	HoursPerDay  float64       `json:"hoursPerDay"`
+	Entitlements []Entitlement `json:"entitlements"`
+}
+
+// Italian returns a policy with the entitlements of the Italian national contracts: holidays
+// accrued monthly and usable within 18 months from the end of the year, and ROL and
+// ex-festività hours accrued monthly and usable within 24 months.
+// Contracts differ in the details, so the returned policy is meant to be adjusted.
+func Italian(holidayDays, rolHours, exFestivitaHours float64) Policy {
+	return Policy{
+		HoursPerDay: 8,
+		Entitlements: []Entitlement{
+			{Name: "ferie", Hours: holidayDays * 8, Accrual: Monthly, CarryOverHours: -1, CarryOverMonths: 18},
+			{Name: "rol", Hours: rolHours, Accrual: Monthly, CarryOverHours: -1, CarryOverMonths: 24},
+			{Name: "ex-festivita", Hours: exFestivitaHours, Accrual: Monthly, CarryOverHours: -1, CarryOverMonths: 24},
+		},
+	}
+}
+
+// Validate checks that the policy can be used to compute balances.
+func (p Policy) Validate() error {
+	if p.HoursPerDay <= 0 {
+		return errors.New("hoursPerDay must be positive")
+	}
+	for _, entitlement := range p.Entitlements {
+		if entitlement.Name == "" {
+			return errors.New("every entitlement must have a name")
+		}
+		if entitlement.Hours < 0 {
+			return fmt.Errorf("entitlement %s: hours must not be negative", entitlement.Name)
+		}
+		if entitlement.Accrual != Monthly && entitlement.Accrual != Yearly {
+			return fmt.Errorf("entitlement %s: accrual must be %s or %s", entitlement.Name, Monthly, Yearly)
+		}
+		if entitlement.CarryOverMonths < 0 {
+			return fmt.Errorf("entitlement %s: carryOverMonths must not be negative", entitlement.Name)
+		}
+	}
+	return nil
+}
+
+// Days converts hours into days of leave.
+func (p Policy) Days(hours float64) float64 {
+	return hours / p.HoursPerDay
+}
+
+// Hours converts days of leave into hours.
+func (p Policy) Hours(days float64) float64 {
+	return days * p.HoursPerDay
+}
+
+// Lot is an amount of hours of the same entitlement sharing the same expiry.
+type Lot struct {
+	Name  string  `json:"name"`
+	Hours float64 `json:"hours"`
+	// Expires is the first day the hours can no longer be used. It is zero for the hours
+	// accrued in the current year, subject to carry over, and for those never expiring.
+	Expires civil.Date `json:"expires"`
+}
+
+// Usage is leave spent on a day.
+type Usage struct {
+	Date  civil.Date `json:"date"`
+	Hours float64    `json:"hours"`
+}
+
+// Grant is leave given on a day in addition to the entitlements, as the day of leave of an
+// holiday falling on Sunday under some contracts. Granted hours never expire.
+type Grant struct {
+	Name  string     `json:"name"`
+	Date  civil.Date `json:"date"`
+	Hours float64    `json:"hours"`
+}
+
+// Balance is the state of an account on a day.
+type Balance struct {
+	Date civil.Date `json:"date"`
+	// Hours available, negative when more leave has been used than accrued.
+	Hours float64 `json:"hours"`
+	Days  float64 `json:"days"`
+	// Lots are the hours available, in the order they are spent.
+	Lots []Lot `json:"lots"`
+	// Expired are the hours lost because of expiry or of the carry over limits.
+	Expired float64 `json:"expired"`
+}
+
+// Account applies a policy from the Start day on.
+type Account struct {
+	Policy Policy `json:"policy"`
+	// Start is the first day of accrual: the leave of its month and year is accrued in full.
+	Start civil.Date `json:"start"`
+	// Opening are the hours available on Start, such as those carried over from previous
+	// years. Opening lots without an expiry never expire.
+	Opening []Lot `json:"opening,omitempty"`
+	// Grants add to the hours available from their day on, paying back the leave used beyond them first.
+	Grants []Grant `json:"grants,omitempty"`
+	Usages []Usage `json:"usages,omitempty"`
+}
+
+// BalanceAt returns the balance at the end of date, after the leave used on that day.
+func (a Account) BalanceAt(date civil.Date) (Balance, error) {
+	state, err := a.simulate(date, nil)
+	if err != nil {
+		return Balance{}, err
+	}
+	return state.balance(a.Policy, date), nil
+}
+
+// CanAfford tells whether days of leave can be taken on date without running out of leave,
+// neither on that day nor on the days of the usages already in the account.
+func (a Account) CanAfford(date civil.Date, days int) bool {
+	without, err := a.simulate(civil.Date{}, nil)
+	if err != nil {
+		return false
+	}
+	with, err := a.simulate(civil.Date{}, &Usage{Date: date, Hours: a.Policy.Hours(float64(days))})
+	if err != nil {
+		return false
+	}
+	return with.overdrawn == without.overdrawn
+}
+
+// Amounts are simulated in minutes, so that monthly accruals add up exactly to the yearly hours.
+type lot struct {
+	name string
+	// entitlement is the index of the entitlement in the policy, -1 for grants and opening lots of other kinds
+	entitlement int
+	minutes     int64
+	// year is the year the minutes were accrued in, zero once carried over
+	year    int
+	expires civil.Date
+}
+
+type state struct {
+	lots []*lot
+	// deficit are the minutes used beyond the available ones, paid back by the next accruals
+	deficit   int64
+	overdrawn int64
+	expired   int64
+}
+
+func minutes(hours float64) int64 {
+	return int64(math.Round(hours * 60))
+}
+
+// simulate runs the account day by day up to until, or up to the last usage when until is zero.
+func (a Account) simulate(until civil.Date, extra *Usage) (*state, error) {
+	if err := a.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	if a.Start.IsZero() {
+		return nil, errors.New("the account must have a start day")
+	}
+	start := a.Start
+
+	usages := map[civil.Date]int64{}
+	last := start
+	addUsage := func(usage Usage) error {
+		date := usage.Date
+		if date.Before(start) {
+			return fmt.Errorf("leave used on %s, before the start of the account", date)
+		}
+		usages[date] += minutes(usage.Hours)
+		if date.After(last) {
+			last = date
+		}
+		return nil
+	}
+	for _, usage := range a.Usages {
+		if err := addUsage(usage); err != nil {
+			return nil, err
+		}
+	}
+	if extra != nil {
+		if err := addUsage(*extra); err != nil {
+			return nil, err
+		}
+	}
+	if until.IsZero() {
+		until = last
+	}
+
+	grants := map[civil.Date][]Grant{}
+	for _, grant := range a.Grants {
+		if grant.Date.Before(start) {
+			return nil, fmt.Errorf("leave granted on %s, before the start of the account", grant.Date)
+		}
+		grants[grant.Date] = append(grants[grant.Date], grant)
+	}
+
+	s := &state{}
+	for _, opening := range a.Opening {
+		l := &lot{name: opening.Name, entitlement: a.entitlementIndex(opening.Name), minutes: minutes(opening.Hours)}
+		l.expires = opening.Expires
+		s.lots = append(s.lots, l)
+	}
+
+	for date := start; !date.After(until); date = date.AddDays(1) {
+		if isNewYear(date) && date.After(start) {
+			s.carryOver(a.Policy, date)
+		}
+		s.expire(date)
+		for index, entitlement := range a.Policy.Entitlements {
+			if accrued := accrual(entitlement, date, start); accrued > 0 {
+				s.accrue(index, entitlement.Name, date.Year, accrued)
+			}
+		}
+		for _, grant := range grants[date] {
+			s.accrue(-1, grant.Name, 0, minutes(grant.Hours))
+		}
+		if used, ok := usages[date]; ok {
+			s.use(used)
+		}
+	}
+	return s, nil
+}
+
+func (a Account) entitlementIndex(name string) int {
+	for index, entitlement := range a.Policy.Entitlements {
+		if entitlement.Name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+// isNewYear tells whether date is the first day of its year.
+func isNewYear(date civil.Date) bool {
+	return date.Month == time.January && date.Day == 1
+}
+
+// accrual returns the minutes of the entitlement accrued on date.
+func accrual(entitlement Entitlement, date civil.Date, start civil.Date) int64 {
+	yearly := minutes(entitlement.Hours)
+	switch entitlement.Accrual {
+	case Yearly:
+		if isNewYear(date) || date == start {
+			return yearly
+		}
+	case Monthly:
+		if date.AddDays(1).Day == 1 {
+			month := int64(date.Month)
+			return yearly*month/12 - yearly*(month-1)/12
+		}
+	}
+	return 0
+}
+
+func (s *state) accrue(entitlement int, name string, year int, accrued int64) {
+	paid := accrued
+	if paid > s.deficit {
+		paid = s.deficit
+	}
+	s.deficit -= paid
+	accrued -= paid
+	if accrued == 0 {
+		return
+	}
+	for _, l := range s.lots {
+		if l.entitlement == entitlement && l.year == year && l.expires.IsZero() {
+			l.minutes += accrued
+			return
+		}
+	}
+	s.lots = append(s.lots, &lot{name: name, entitlement: entitlement, minutes: accrued, year: year})
+}
+
+// carryOver moves the minutes accrued in the year before date into lots expiring
+// as set by the policy, dropping those over the carry over limits.
+func (s *state) carryOver(policy Policy, date civil.Date) {
+	lots := s.lots[:0]
+	for _, l := range s.lots {
+		if l.year != date.Year-1 {
+			lots = append(lots, l)
+			continue
+		}
+		entitlement := policy.Entitlements[l.entitlement]
+		carried := l.minutes
+		if limit := minutes(entitlement.CarryOverHours); entitlement.CarryOverHours >= 0 && carried > limit {
+			carried = limit
+		}
+		s.expired += l.minutes - carried
+		if carried == 0 {
+			continue
+		}
+		l.minutes = carried
+		l.year = 0
+		if entitlement.CarryOverMonths > 0 {
+			l.expires = date.AddDate(0, entitlement.CarryOverMonths, 0)
+		}
+		lots = append(lots, l)
+	}
+	s.lots = lots
+}
+
+func (s *state) expire(date civil.Date) {
+	lots := s.lots[:0]
+	for _, l := range s.lots {
+		if !l.expires.IsZero() && !date.Before(l.expires) {
+			s.expired += l.minutes
+			continue
+		}
+		lots = append(lots, l)
+	}
+	s.lots = lots
+}
+
+// use spends the minutes from the lots expiring first, then from the hours of the current
+// year and lastly from those never expiring.
+func (s *state) use(used int64) {
+	s.sortLots()
+	for _, l := range s.lots {
+		spent := used
+		if spent > l.minutes {
+			spent = l.minutes
+		}
+		l.minutes -= spent
+		used -= spent
+	}
+	lots := s.lots[:0]
+	for _, l := range s.lots {
+		if l.minutes > 0 {
+			lots = append(lots, l)
+		}
+	}
+	s.lots = lots
+	s.deficit += used
+	s.overdrawn += used
+}
+
+func (s *state) sortLots() {
+	rank := func(l *lot) int {
+		switch {
+		case !l.expires.IsZero():
+			return 0
+		case l.year > 0:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(s.lots, func(i, j int) bool {
+		if rank(s.lots[i]) != rank(s.lots[j]) {
+			return rank(s.lots[i]) < rank(s.lots[j])
+		}
+		if s.lots[i].expires != s.lots[j].expires {
+			return s.lots[i].expires.Before(s.lots[j].expires)
+		}
+		return s.lots[i].entitlement < s.lots[j].entitlement
+	})
+}
+
+func (s *state) balance(policy Policy, date civil.Date) Balance {
+	s.sortLots()
+	balance := Balance{Date: date, Lots: []Lot{}, Expired: float64(s.expired) / 60}
+	var total int64
+	for _, l := range s.lots {
+		balance.Lots = append(balance.Lots, Lot{Name: l.name, Hours: float64(l.minutes) / 60, Expires: l.expires})
+		total += l.minutes
+	}
+	balance.Hours = float64(total-s.deficit) / 60
+	balance.Days = policy.Days(balance.Hours)
+	return balance
+}
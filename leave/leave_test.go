@@ -0,0 +1,189 @@
+package leave
+
+import (
+	"testing"
+	"time"
+
+	"feriapp-backend-go/civil"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) civil.Date {
+	return civil.NewDate(year, month, day)
+}
+
+func TestPolicyValidate(testCase *testing.T) {
+	testCase.Run("italian policy", func(t *testing.T) {
+		require.NoError(t, Italian(26, 72, 32).Validate())
+	})
+
+	invalid := map[string]Policy{
+		"no hours per day":  {Entitlements: []Entitlement{{Name: "ferie", Accrual: Monthly}}},
+		"no name":           {HoursPerDay: 8, Entitlements: []Entitlement{{Accrual: Monthly}}},
+		"negative hours":    {HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Hours: -1, Accrual: Monthly}}},
+		"unknown accrual":   {HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Accrual: "weekly"}}},
+		"negative duration": {HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Accrual: Yearly, CarryOverMonths: -1}}},
+	}
+	for name, policy := range invalid {
+		testCase.Run(name, func(t *testing.T) {
+			require.Error(t, policy.Validate())
+		})
+	}
+}
+
+func TestBalanceAt(testCase *testing.T) {
+	testCase.Run("monthly accrual adds up to the yearly hours", func(t *testing.T) {
+		account := Account{Policy: Italian(26, 72, 32), Start: date(2021, 1, 1)}
+
+		balance, err := account.BalanceAt(date(2021, 1, 30))
+		require.NoError(t, err)
+		require.Equal(t, 0.0, balance.Hours)
+
+		balance, err = account.BalanceAt(date(2021, 1, 31))
+		require.NoError(t, err)
+		// a twelfth of 208 hours of ferie, 72 of rol and 32 of ex-festività
+		require.InDelta(t, 26.0, balance.Hours, 0.001)
+		require.InDelta(t, 3.25, balance.Days, 0.001)
+
+		balance, err = account.BalanceAt(date(2021, 12, 31))
+		require.NoError(t, err)
+		require.Equal(t, 312.0, balance.Hours)
+		require.Equal(t, []Lot{
+			{Name: "ferie", Hours: 208},
+			{Name: "rol", Hours: 72},
+			{Name: "ex-festivita", Hours: 32},
+		}, balance.Lots)
+	})
+
+	testCase.Run("yearly accrual", func(t *testing.T) {
+		account := Account{
+			Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Hours: 160, Accrual: Yearly}}},
+			Start:  date(2021, 6, 15),
+		}
+
+		balance, err := account.BalanceAt(date(2021, 6, 15))
+		require.NoError(t, err)
+		require.Equal(t, 20.0, balance.Days)
+	})
+
+	testCase.Run("carry over with limit and expiry", func(t *testing.T) {
+		account := Account{
+			Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{
+				{Name: "ferie", Hours: 160, Accrual: Yearly, CarryOverHours: 40, CarryOverMonths: 6},
+			}},
+			Start:  date(2021, 1, 1),
+			Usages: []Usage{{Date: date(2021, 8, 2), Hours: 80}},
+		}
+
+		balance, err := account.BalanceAt(date(2022, 1, 1))
+		require.NoError(t, err)
+		// 80 hours left in 2021: 40 carried over and 40 lost, plus the 160 of 2022
+		require.Equal(t, 200.0, balance.Hours)
+		require.Equal(t, 40.0, balance.Expired)
+		require.Equal(t, []Lot{
+			{Name: "ferie", Hours: 40, Expires: date(2022, 7, 1)},
+			{Name: "ferie", Hours: 160},
+		}, balance.Lots)
+
+		balance, err = account.BalanceAt(date(2022, 7, 1))
+		require.NoError(t, err)
+		require.Equal(t, 160.0, balance.Hours)
+		require.Equal(t, 80.0, balance.Expired)
+	})
+
+	testCase.Run("leave expiring first is used first", func(t *testing.T) {
+		account := Account{
+			Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{
+				{Name: "ferie", Hours: 160, Accrual: Yearly, CarryOverHours: -1, CarryOverMonths: 6},
+			}},
+			Start: date(2022, 1, 1),
+			Opening: []Lot{
+				{Name: "banca ore", Hours: 16},
+				{Name: "ferie", Hours: 24, Expires: date(2022, 7, 1)},
+			},
+			Usages: []Usage{{Date: date(2022, 3, 1), Hours: 32}},
+		}
+
+		balance, err := account.BalanceAt(date(2022, 3, 1))
+		require.NoError(t, err)
+		require.Equal(t, []Lot{
+			{Name: "ferie", Hours: 152},
+			{Name: "banca ore", Hours: 16},
+		}, balance.Lots)
+	})
+
+	testCase.Run("overdrawn leave is paid back by the next accruals", func(t *testing.T) {
+		account := Account{
+			Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Hours: 96, Accrual: Monthly}}},
+			Start:  date(2021, 1, 1),
+			Usages: []Usage{{Date: date(2021, 2, 1), Hours: 16}},
+		}
+
+		balance, err := account.BalanceAt(date(2021, 2, 1))
+		require.NoError(t, err)
+		require.Equal(t, -8.0, balance.Hours)
+
+		balance, err = account.BalanceAt(date(2021, 2, 28))
+		require.NoError(t, err)
+		require.Equal(t, 0.0, balance.Hours)
+		require.Empty(t, balance.Lots)
+	})
+
+	testCase.Run("granted leave pays back the overdrawn one and never expires", func(t *testing.T) {
+		account := Account{
+			Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{
+				{Name: "ferie", Hours: 96, Accrual: Monthly, CarryOverHours: -1, CarryOverMonths: 6},
+			}},
+			Start:  date(2021, 1, 1),
+			Grants: []Grant{{Name: "compensation", Date: date(2021, 2, 1), Hours: 8}, {Name: "compensation", Date: date(2021, 3, 1), Hours: 8}},
+			Usages: []Usage{{Date: date(2021, 2, 1), Hours: 16}},
+		}
+
+		balance, err := account.BalanceAt(date(2021, 2, 1))
+		require.NoError(t, err)
+		require.Equal(t, 0.0, balance.Hours)
+
+		balance, err = account.BalanceAt(date(2023, 1, 1))
+		require.NoError(t, err)
+		require.Equal(t, []Lot{
+			{Name: "ferie", Hours: 96, Expires: date(2023, 7, 1)},
+			{Name: "compensation", Hours: 8},
+		}, balance.Lots)
+
+		_, err = Account{Policy: Italian(26, 0, 0), Start: date(2021, 1, 1), Grants: []Grant{{Name: "compensation", Date: date(2020, 12, 27), Hours: 8}}}.BalanceAt(date(2021, 1, 1))
+		require.EqualError(t, err, "leave granted on 2020-12-27, before the start of the account")
+	})
+
+	testCase.Run("invalid accounts", func(t *testing.T) {
+		_, err := Account{Policy: Italian(26, 0, 0)}.BalanceAt(date(2021, 1, 1))
+		require.Error(t, err)
+
+		_, err = Account{Policy: Italian(26, 0, 0), Start: date(2021, 1, 1), Usages: []Usage{{Date: date(2020, 12, 31), Hours: 8}}}.BalanceAt(date(2021, 1, 1))
+		require.EqualError(t, err, "leave used on 2020-12-31, before the start of the account")
+	})
+}
+
+func TestCanAfford(testCase *testing.T) {
+	account := Account{
+		Policy: Policy{HoursPerDay: 8, Entitlements: []Entitlement{{Name: "ferie", Hours: 96, Accrual: Monthly}}},
+		Start:  date(2021, 1, 1),
+		Usages: []Usage{{Date: date(2021, 6, 1), Hours: 32}},
+	}
+
+	testCase.Run("leave accrued by the day", func(t *testing.T) {
+		// 8 hours accrued every month: 5 months by June, 4 days already used
+		require.True(t, account.CanAfford(date(2021, 6, 1), 1))
+		require.False(t, account.CanAfford(date(2021, 6, 1), 2))
+		require.True(t, account.CanAfford(date(2021, 12, 31), 8))
+	})
+
+	testCase.Run("leave taken earlier must not leave later usages uncovered", func(t *testing.T) {
+		require.True(t, account.CanAfford(date(2021, 3, 1), 1))
+		require.False(t, account.CanAfford(date(2021, 3, 1), 2))
+	})
+
+	testCase.Run("invalid account", func(t *testing.T) {
+		require.False(t, Account{}.CanAfford(date(2021, 3, 1), 1))
+	})
+}
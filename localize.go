@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// requestLocalizer returns the localizer for the languages preferred by the request:
+// lang, given in the query string or in the body, then those of the Accept-Language header.
+func requestLocalizer(messages *i18n.Bundle, req *http.Request, lang string) *i18n.Localizer {
+	preferences := i18n.ParseAcceptLanguage(req.Header.Get("Accept-Language"))
+	if lang != "" {
+		preferences = append([]string{lang}, preferences...)
+	}
+	return messages.Localizer(preferences...)
+}
+
+// setLanguageHeaders tells caches that the response depends on the Accept-Language header.
+func setLanguageHeaders(w http.ResponseWriter, localizer *i18n.Localizer) {
+	w.Header().Set("Content-Language", localizer.Language())
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// localizeFilter labels the bridges kept by filter in the language of the localizer, naming
+// the holidays within them, custom ones included, also in the breakdown of their days. Labels
+// are added after the computation, so that cached bridges are shared by every language.
+// The holidays of a year are listed once per filter from the language pack parsed once per
+// version. A language pack that cannot be read is logged, and the bridges are labelled
+// without the patron day.
+func localizeFilter(logger *logrus.Entry, localizer *i18n.Localizer, reqBody bridges.BridgesRequest, filter bridgesFilter) bridgesFilter {
+	daysOff := map[time.Weekday]bool{}
+	for _, dayOff := range reqBody.DaysOff {
+		daysOff[time.Weekday(dayOff)] = true
+	}
+	holidaysByYear := map[int][]helpers.HolidayDate{}
+	yearHolidays := func(year int) []helpers.HolidayDate {
+		if holidays, ok := holidaysByYear[year]; ok {
+			return holidays
+		}
+		holidays, err := helpers.LoadHolidays(year, reqBody.Country, reqBody.City)
+		if err != nil {
+			logger.WithError(err).Warn("language pack not readable: the patron day is not labelled")
+		}
+		for _, customHoliday := range reqBody.CustomHolidays {
+			dates, err := customHolidayDates(context.Background(), []bridges.CustomHolidays{customHoliday}, year, 0)
+			if err != nil {
+				continue
+			}
+			name := customHoliday.Name
+			if name == "" {
+				name = localizer.Message("holiday.custom")
+			}
+			for _, date := range dates {
+				if date.Year == year {
+					holidays = append(holidays, helpers.HolidayDate{Date: date, Name: name})
+				}
+			}
+		}
+		sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+		holidaysByYear[year] = holidays
+		return holidays
+	}
+
+	var label func(bridge bridges.Bridge) bridges.Bridge
+	label = func(bridge bridges.Bridge) bridges.Bridge {
+		// the bridge is named after the first holiday falling on a working day, which makes it
+		// a bridge, or after the first holiday when all of them fall on days off
+		var main *helpers.HolidayDate
+		bridge.Holidays = []bridges.NamedDay{}
+		for year := bridge.Start.Year; year <= bridge.End.Year; year++ {
+			holidays := yearHolidays(year)
+			for index, holiday := range holidays {
+				if holiday.Date.Before(bridge.Start) || holiday.Date.After(bridge.End) {
+					continue
+				}
+				bridge.Holidays = append(bridge.Holidays, bridges.NamedDay{Date: holiday.Date, Name: localizer.HolidayName(holiday)})
+				if main == nil || (daysOff[main.Date.Weekday()] && !daysOff[holiday.Date.Weekday()]) {
+					main = &holidays[index]
+				}
+			}
+		}
+		bridge.Label = localizer.BridgeLabel(main)
+		if len(bridge.Days) > 0 {
+			bridge.Days = namedDays(bridge.Days, bridge.Holidays)
+		}
+		if len(bridge.Alternatives) > 0 {
+			alternatives := make([]bridges.Bridge, 0, len(bridge.Alternatives))
+			for _, alternative := range bridge.Alternatives {
+				alternatives = append(alternatives, label(alternative))
+			}
+			bridge.Alternatives = alternatives
+		}
+		return bridge
+	}
+
+	return func(yearBridges bridges.YearBridges) bridges.YearBridges {
+		yearBridges = filter(yearBridges)
+		labelled := make([]bridges.Bridge, 0, len(yearBridges.Bridges))
+		for _, bridge := range yearBridges.Bridges {
+			labelled = append(labelled, label(bridge))
+		}
+		yearBridges.Bridges = labelled
+		return yearBridges
+	}
+}
+
+// namedDays returns a copy of days with the names of the holidays falling on them.
+func namedDays(days []bridges.Day, holidays []bridges.NamedDay) []bridges.Day {
+	named := make([]bridges.Day, 0, len(days))
+	for _, day := range days {
+		for _, holiday := range holidays {
+			if holiday.Date == day.Date {
+				day.Name = holiday.Name
+				break
+			}
+		}
+		named = append(named, day)
+	}
+	return named
+}
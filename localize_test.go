@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizeFilter(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	log, _ := test.NewNullLogger()
+	testLogger := logrus.NewEntry(log)
+	day := func(month time.Month, day int) civil.Date { return civil.NewDate(2030, month, day) }
+	reqBody := bridges.BridgesRequest{
+		City:           "Milano",
+		Country:        "IT",
+		DaysOff:        []int{0, 6},
+		CustomHolidays: []bridges.CustomHolidays{{Date: "08-16", Name: "San Rocco"}, {Date: "2030-03-04"}},
+	}
+	yearBridges := bridges.YearBridges{Bridges: []bridges.Bridge{
+		// All Saints' Day is a Friday
+		{Id: "all-saints", Start: day(11, 1), End: day(11, 3)},
+		// Christmas is a Wednesday, Santo Stefano a Thursday
+		{Id: "christmas", Start: day(12, 25), End: day(12, 29)},
+		// Ferragosto is a Thursday, followed by the custom holiday
+		{Id: "assumption", Start: day(8, 15), End: day(8, 18)},
+		{Id: "custom", Start: day(3, 2), End: day(3, 4)},
+		{Id: "weekend", Start: day(3, 9), End: day(3, 10)},
+	}}
+
+	testCase.Run("italian", func(t *testing.T) {
+		labelled := localizeFilter(testLogger, testMessages.Localizer("it"), reqBody, unfiltered)(yearBridges)
+		labels := []string{}
+		for _, bridge := range labelled.Bridges {
+			labels = append(labels, bridge.Label)
+		}
+		require.Equal(t, []string{"Ponte di Ognissanti", "Ponte di Natale", "Ponte di Ferragosto", "Ponte di Giorno libero", "Ponte"}, labels)
+		require.Equal(t, []bridges.NamedDay{{Date: day(8, 15), Name: "Ferragosto"}, {Date: day(8, 16), Name: "San Rocco"}}, labelled.Bridges[2].Holidays)
+		require.Empty(t, yearBridges.Bridges[0].Label, "the cached bridges must not be modified")
+	})
+
+	testCase.Run("english", func(t *testing.T) {
+		labelled := localizeFilter(testLogger, testMessages.Localizer("en-GB"), reqBody, unfiltered)(yearBridges)
+		require.Equal(t, "All Saints' bridge", labelled.Bridges[0].Label)
+		require.Equal(t, []bridges.NamedDay{{Date: day(12, 25), Name: "Christmas Day"}, {Date: day(12, 26), Name: "St. Stephen's Day"}}, labelled.Bridges[1].Holidays)
+		require.Equal(t, "Long weekend", labelled.Bridges[4].Label)
+	})
+
+	testCase.Run("holidays of other countries", func(t *testing.T) {
+		british := bridges.BridgesRequest{Country: "GB", DaysOff: []int{0, 6}, CustomHolidays: []bridges.CustomHolidays{}}
+		// Good Friday is on the 19th of April 2030, Easter Monday on the 22nd
+		easter := bridges.YearBridges{Bridges: []bridges.Bridge{{Id: "easter", Start: day(4, 19), End: day(4, 22)}}}
+		labelled := localizeFilter(testLogger, testMessages.Localizer("it"), british, unfiltered)(easter)
+		require.Equal(t, "Ponte di Venerdì Santo", labelled.Bridges[0].Label)
+		require.Equal(t, []bridges.NamedDay{{Date: day(4, 19), Name: "Venerdì Santo"}, {Date: day(4, 22), Name: "Lunedì dell'Angelo"}}, labelled.Bridges[0].Holidays)
+	})
+
+	testCase.Run("the language pack is read once", func(t *testing.T) {
+		localizeFilter(testLogger, testMessages.Localizer("it"), reqBody, unfiltered)(yearBridges)
+		loads := helpers.LanguagePackStats().Loads
+		for i := 0; i < 3; i++ {
+			localizeFilter(testLogger, testMessages.Localizer("it"), reqBody, unfiltered)(yearBridges)
+		}
+		require.Equal(t, loads, helpers.LanguagePackStats().Loads)
+	})
+}
+
+func TestLocalizedRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+
+	serve := func(method, target, acceptLanguage string, body interface{}) *httptest.ResponseRecorder {
+		var requestBody bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&requestBody).Encode(body)
+		}
+		request := httptest.NewRequest(method, target, &requestBody)
+		if acceptLanguage != "" {
+			request.Header.Set("Accept-Language", acceptLanguage)
+		}
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	labels := func(t *testing.T, body []byte) []string {
+		var years []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(body, &years))
+		labels := []string{}
+		for _, yearBridges := range years {
+			for _, bridge := range yearBridges.Bridges {
+				require.NotEmpty(t, bridge.Label, bridge.Id)
+				labels = append(labels, bridge.Label)
+			}
+		}
+		require.NotEmpty(t, labels)
+		return labels
+	}
+
+	testCase.Run("italian by default", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&dayOfHolidays=2", "", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "it", responseRecorder.Header().Get("Content-Language"))
+		require.Contains(t, responseRecorder.Header().Get("Vary"), "Accept-Language")
+		for _, label := range labels(t, responseRecorder.Body.Bytes()) {
+			require.True(t, strings.HasPrefix(label, "Ponte"), label)
+		}
+	})
+
+	testCase.Run("language from the header and the parameter", func(t *testing.T) {
+		italian := serve(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&dayOfHolidays=2", "", nil)
+		fromHeader := serve(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&dayOfHolidays=2", "fr;q=0.9, en-GB;q=0.8", nil)
+		require.Equal(t, http.StatusOK, fromHeader.Code)
+		require.Equal(t, "en", fromHeader.Header().Get("Content-Language"))
+		require.NotEqual(t, italian.Header().Get("ETag"), fromHeader.Header().Get("ETag"))
+		for _, label := range labels(t, fromHeader.Body.Bytes()) {
+			require.False(t, strings.HasPrefix(label, "Ponte"), label)
+		}
+
+		fromParameter := serve(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&dayOfHolidays=2&lang=en", "it", nil)
+		require.Equal(t, "en", fromParameter.Header().Get("Content-Language"))
+		require.Equal(t, fromHeader.Header().Get("ETag"), fromParameter.Header().Get("ETag"))
+	})
+
+	testCase.Run("language from the body", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/bridges", "", bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}, DayOfHolidays: 2, Lang: "en"})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "en", responseRecorder.Header().Get("Content-Language"))
+		for _, label := range labels(t, responseRecorder.Body.Bytes()) {
+			require.False(t, strings.HasPrefix(label, "Ponte"), label)
+		}
+	})
+
+	testCase.Run("batch items in their own language", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/bridges/batch", "en", []bridges.BatchBridgesRequest{
+			{Id: "it", BridgesRequest: bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}, DayOfHolidays: 2, Lang: "it"}},
+			{Id: "en", BridgesRequest: bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}, DayOfHolidays: 2}},
+		})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var results []bridges.BatchBridgesResult
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &results))
+		require.Len(t, results, 2)
+		require.True(t, strings.HasPrefix(results[0].Bridges[0].Bridges[0].Label, "Ponte"))
+		require.False(t, strings.HasPrefix(results[1].Bridges[0].Bridges[0].Label, "Ponte"))
+	})
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -24,7 +25,9 @@ import (
 	"path"
 	"syscall"
 
+	"feriapp-backend-go/cache"
 	"feriapp-backend-go/helpers"
+	"feriapp-backend-go/i18n"
 
 	"github.com/gorilla/mux"
 	"github.com/mia-platform/configlib"
@@ -49,16 +52,63 @@ func entrypoint(shutdown chan os.Signal) {
 		panic(err.Error())
 	}
 
+	shutdownTracing, err := setupTracing(env)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	auth, err := newAuthenticator(env)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	store, err := newStore(env)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	messages, err := i18n.LoadDir(env.MessageCatalogsPath, env.DefaultLanguage)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	// A missing language pack only leaves out the patron days, while a broken one is a mistake to fix.
+	for _, locale := range helpers.LanguagePackLocales() {
+		warnings, err := helpers.CheckLanguagePack(locale)
+		if os.IsNotExist(err) {
+			log.WithField("locale", locale).Warn("language pack not found: patron days are not included")
+		} else if err != nil {
+			panic(err.Error())
+		}
+		for _, warning := range warnings {
+			log.WithField("locale", locale).WithField("problem", warning).Warn("language pack entry not fully usable")
+		}
+	}
+
 	// Routing
+	bridgesCache := cache.New(env.BridgesCacheSize)
 	router := mux.NewRouter()
 	router.Use(glogger.RequestMiddlewareLogger(log, []string{"/-/"}))
+	router.Use(metricsMiddleware)
+	router.Use(tracingMiddleware)
+	// addresses are limited before the authentication, so that guessing credentials is
+	// limited too, and subjects after it, by the identity they proved
+	router.Use(rateLimitMiddleware(env))
+	router.Use(authMiddleware(auth))
+	router.Use(subjectRateLimitMiddleware(env))
 	StatusRoutes(router, "feriapp-backend-go", env.ServiceVersion)
+	MetricsRoute(router, newMetricsRegistry(bridgesCache))
 
 	serviceRouter := router
 	if env.ServicePrefix != "" && env.ServicePrefix != "/" {
 		serviceRouter = router.PathPrefix(fmt.Sprintf("%s/", path.Clean(env.ServicePrefix))).Subrouter()
 	}
-	setupBridgesRouter(serviceRouter)
+	setupBridgesRouter(serviceRouter, bridgesCache, store, messages, env)
+	setupProfilesRouter(serviceRouter, store)
+	setupOrganizationsRouter(serviceRouter, store)
+	setupBookingsRouter(serviceRouter, store)
+	setupLanguagePacksRouter(serviceRouter, env)
+	setupCitiesRouter(serviceRouter)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%s", env.HTTPPort),
@@ -77,4 +127,10 @@ func entrypoint(shutdown chan os.Signal) {
 	// We'll accept graceful shutdowns when quit via  and SIGTERM (Ctrl+/)
 	// SIGINT (Ctrl+C), SIGKILL or SIGQUIT will not be caught.
 	helpers.GracefulShutdown(srv, shutdown, log, env.DelayShutdownSeconds)
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.WithError(err).Error("Error flushing pending traces.")
+	}
+	if err := store.Close(); err != nil {
+		log.WithError(err).Error("Error closing the storage.")
+	}
 }
@@ -45,6 +45,10 @@ func TestEntryPoint(t *testing.T) {
 		resp, err := http.DefaultClient.Get("http://localhost:3000/-/healthz")
 		require.Equal(t, nil, err)
 		require.Equal(t, 200, resp.StatusCode)
+
+		resp, err = http.DefaultClient.Get("http://localhost:3000/-/metrics")
+		require.Equal(t, nil, err)
+		require.Equal(t, 200, resp.StatusCode)
 	})
 
 	t.Run("GracefulShutdown works properly", func(t *testing.T) {
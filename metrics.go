@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/helpers"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "feriapp"
+
+// Collectors are shared by every registry created with newMetricsRegistry, so that
+// handlers can record values without having the registry threaded through.
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	bridgesComputationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "bridges_computation_duration_seconds",
+		Help:      "Time spent computing the bridges of a single year, by distance from the current year.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"year_offset"})
+
+	bridgesReturned = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "bridges_returned",
+		Help:      "Number of bridges returned by a single response.",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+)
+
+// newMetricsRegistry creates a registry with the service collectors, the Go runtime and
+// process collectors, and the usage counters of the bridges cache and language packs.
+func newMetricsRegistry(bridgesCache *cache.LRU) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		bridgesComputationDuration,
+		bridgesReturned,
+		newCounterFunc("bridges_cache_hits_total", "Number of bridges cache hits.", func() float64 {
+			return float64(bridgesCache.Stats().Hits)
+		}),
+		newCounterFunc("bridges_cache_misses_total", "Number of bridges cache misses.", func() float64 {
+			return float64(bridgesCache.Stats().Misses)
+		}),
+		newCounterFunc("bridges_cache_evictions_total", "Number of bridges cache evictions.", func() float64 {
+			return float64(bridgesCache.Stats().Evictions)
+		}),
+		newCounterFunc("bridges_cache_shared_total", "Number of bridges computations shared between concurrent requests.", func() float64 {
+			return float64(bridgesCache.Stats().Shared)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "bridges_cache_entries",
+			Help:      "Number of entries currently held by the bridges cache.",
+		}, func() float64 {
+			return float64(bridgesCache.Stats().Entries)
+		}),
+		newCounterFunc("language_pack_loads_total", "Number of language pack reads.", func() float64 {
+			return float64(helpers.LanguagePackStats().Loads)
+		}),
+		newCounterFunc("language_pack_load_errors_total", "Number of failed language pack reads.", func() float64 {
+			return float64(helpers.LanguagePackStats().Errors)
+		}),
+	)
+	return registry
+}
+
+func newCounterFunc(name, help string, value func() float64) prometheus.CounterFunc {
+	return prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      name,
+		Help:      help,
+	}, value)
+}
+
+// MetricsRoute exposes the registry in the Prometheus text format.
+func MetricsRoute(r *mux.Router, registry *prometheus.Registry) {
+	r.Handle("/-/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// metricsMiddleware records count and latency of every request matched by the router,
+// labelled with the route template to keep the cardinality bounded.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, req)
+
+		route := routeTemplate(req)
+		status := strconv.Itoa(recorder.status)
+		httpRequestsTotal.WithLabelValues(route, req.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, req.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate returns the template of the route matched by mux, falling back to the path.
+func routeTemplate(req *http.Request) string {
+	if currentRoute := mux.CurrentRoute(req); currentRoute != nil {
+		if template, err := currentRoute.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return req.URL.Path
+}
+
+// maxYearOffset is the last distance from the current year with a label of its own, since
+// any yearsScope would otherwise create a series for every year.
+const maxYearOffset = 3
+
+func observeYearComputation(year int, elapsed time.Duration) {
+	bridgesComputationDuration.WithLabelValues(yearOffsetLabel(year, time.Now().Year())).Observe(elapsed.Seconds())
+}
+
+// yearOffsetLabel is the distance of year from the current one: "past" for the years gone,
+// then "0" up to maxYearOffset, which also labels the years after it.
+func yearOffsetLabel(year int, currentYear int) string {
+	switch offset := year - currentYear; {
+	case offset < 0:
+		return "past"
+	case offset >= maxYearOffset:
+		return strconv.Itoa(maxYearOffset) + "+"
+	default:
+		return strconv.Itoa(offset)
+	}
+}
+
+// statusRecorder keeps track of the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(body []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(body)
+}
+
+// Flush allows streaming handlers to keep flushing through the recorder.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
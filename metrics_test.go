@@ -0,0 +1,64 @@
+package main
+
+import (
+	"feriapp-backend-go/cache"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(testCase *testing.T) {
+	bridgesCache := cache.New(4)
+	testRouter := mux.NewRouter()
+	testRouter.Use(metricsMiddleware)
+	MetricsRoute(testRouter, newMetricsRegistry(bridgesCache))
+	testRouter.HandleFunc("/items/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	testCase.Run("requests are counted by route template and status", func(t *testing.T) {
+		before := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/items/{id}", http.MethodGet, "418"))
+
+		for _, path := range []string{"/items/1", "/items/2"} {
+			request, _ := http.NewRequest(http.MethodGet, path, nil)
+			testRouter.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		after := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/items/{id}", http.MethodGet, "418"))
+		require.Equal(t, before+2, after)
+	})
+
+	testCase.Run("/-/metrics exposes service and runtime metrics", func(t *testing.T) {
+		bridgesCache.Get("missing")
+
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodGet, "/-/metrics", nil)
+		testRouter.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+
+		body, _ := ioutil.ReadAll(responseRecorder.Result().Body)
+		for _, name := range []string{
+			"feriapp_http_requests_total",
+			"feriapp_http_request_duration_seconds",
+			"feriapp_bridges_cache_misses_total 1",
+			"feriapp_bridges_cache_entries",
+			"feriapp_language_pack_loads_total",
+			"go_goroutines",
+		} {
+			require.Contains(t, string(body), name)
+		}
+	})
+
+	testCase.Run("computations are labelled by a bounded year offset", func(t *testing.T) {
+		labels := []string{}
+		for _, year := range []int{2019, 2020, 2021, 2022, 2023, 2100} {
+			labels = append(labels, yearOffsetLabel(year, 2020))
+		}
+		require.Equal(t, []string{"past", "0", "1", "2", "3+", "3+"}, labels)
+	})
+}
@@ -0,0 +1,400 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/planner"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+var (
+	errOrganizationNotFound       = errors.New("organization not found")
+	errOrganizationNotOwned       = errors.New("only the owner can change the organization")
+	errOrganizationNameIsRequired = errors.New("organization name is required")
+)
+
+// closureRange is a closure happening in a given year.
+type closureRange struct {
+	Name  string
+	Start civil.Date
+	End   civil.Date
+}
+
+func setupOrganizationsRouter(router *mux.Router, store storage.Store) {
+	router.HandleFunc("/organizations", createOrganization(store)).Methods(http.MethodPost)
+	router.HandleFunc("/organizations", listOrganizations(store)).Methods(http.MethodGet)
+	router.HandleFunc("/organizations/{id}", getOrganization(store)).Methods(http.MethodGet)
+	router.HandleFunc("/organizations/{id}", updateOrganization(store)).Methods(http.MethodPut)
+	router.HandleFunc("/organizations/{id}", deleteOrganization(store)).Methods(http.MethodDelete)
+}
+
+// closureRanges returns the closures happening from fromYear to toYear, including the yearly
+// ones started the year before fromYear and ending in it. Dated closures are cut to the days
+// from fromYear to toYear, so that a closure lasting years costs no more than the years asked.
+func closureRanges(closures []storage.Closure, fromYear, toYear int) ([]closureRange, error) {
+	ranges := []closureRange{}
+	for _, closure := range closures {
+		invalid := fmt.Errorf("invalid closure %q: start and end must be both YYYY-MM-DD or both MM-DD, with the end not before the start", closure.Name)
+		start, startErr := civil.ParseDate(closure.Start)
+		end, endErr := civil.ParseDate(closure.End)
+		if startErr == nil && endErr == nil {
+			if end.Before(start) {
+				return nil, invalid
+			}
+			if start.Year > toYear || end.Year < fromYear {
+				continue
+			}
+			if firstDay := civil.NewDate(fromYear, time.January, 1); start.Before(firstDay) {
+				start = firstDay
+			}
+			if lastDay := civil.NewDate(toYear, time.December, 31); end.After(lastDay) {
+				end = lastDay
+			}
+			ranges = append(ranges, closureRange{Name: closure.Name, Start: start, End: end})
+			continue
+		}
+
+		yearlyStart, startErr := time.Parse("01-02", closure.Start)
+		yearlyEnd, endErr := time.Parse("01-02", closure.End)
+		if startErr != nil || endErr != nil {
+			return nil, invalid
+		}
+		for year := fromYear - 1; year <= toYear; year++ {
+			yearStart := civil.NewDate(year, yearlyStart.Month(), yearlyStart.Day())
+			yearEnd := civil.NewDate(year, yearlyEnd.Month(), yearlyEnd.Day())
+			if yearlyEnd.Before(yearlyStart) {
+				yearEnd = civil.NewDate(year+1, yearlyEnd.Month(), yearlyEnd.Day())
+			}
+			if yearStart.Year == year && yearEnd.Year >= fromYear {
+				ranges = append(ranges, closureRange{Name: closure.Name, Start: yearStart, End: yearEnd})
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// findOrganization returns the organization linked to the profile, or nil when it is
+// not linked to any, or the organization has been deleted in the meantime.
+func findOrganization(ctx context.Context, store storage.Store, profile storage.Profile) (*storage.Organization, error) {
+	if profile.OrganizationId == "" {
+		return nil, nil
+	}
+	organization, err := store.GetOrganization(ctx, profile.OrganizationId)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// applyOrganization adds the custom holidays of the organization and the days of its
+// closures to the request, so that bridges are planned around them.
+func applyOrganization(reqBody *bridges.BridgesRequest, organization *storage.Organization, fromYear int) error {
+	if organization == nil {
+		return nil
+	}
+	years := reqBody.YearsScope
+	if years == 0 {
+		years = defaultYearsScope
+	}
+	ranges, err := closureRanges(organization.Closures, fromYear, fromYear+years)
+	if err != nil {
+		return err
+	}
+	customHolidays := append([]bridges.CustomHolidays{}, reqBody.CustomHolidays...)
+	customHolidays = append(customHolidays, organization.CustomHolidays...)
+	for _, closure := range ranges {
+		for date := closure.Start; !date.After(closure.End); date = date.AddDays(1) {
+			customHolidays = append(customHolidays, bridges.CustomHolidays{Date: date.String(), Name: closure.Name})
+		}
+	}
+	reqBody.CustomHolidays = customHolidays
+	return nil
+}
+
+// profileBookings returns the bookings of the profile together with the leave forced by the
+// closures of its organization up to toYear, since both are taken from the leave of the profile,
+// and with the leave given back for the holidays falling on its compensated weekdays.
+// The organization is returned as well, nil when the profile is not linked to any.
+func profileBookings(ctx context.Context, store storage.Store, profile storage.Profile, toYear int) ([]storage.Booking, *storage.Organization, error) {
+	bookings, err := store.ListBookings(ctx, profile.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	organization, err := findOrganization(ctx, store, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	hasClosures := organization != nil && len(organization.Closures) > 0
+	if !hasClosures && len(profile.CompensatedDays) == 0 {
+		return bookings, organization, nil
+	}
+
+	now, err := requestNow(profile.BridgesRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromYear := now.Year()
+	if toYear < fromYear {
+		fromYear = toYear
+	}
+	if profile.Leave != nil && profile.Leave.Start.Year < fromYear {
+		fromYear = profile.Leave.Start.Year
+	}
+	if hasClosures {
+		forced, err := forcedBookings(ctx, profile, organization, fromYear, toYear)
+		if err != nil {
+			return nil, nil, err
+		}
+		bookings = append(bookings, forced...)
+	}
+	if len(profile.CompensatedDays) > 0 {
+		compensations, err := compensationBookings(ctx, profile, fromYear, toYear)
+		if err != nil {
+			return nil, nil, err
+		}
+		bookings = append(bookings, compensations...)
+	}
+	return bookings, organization, nil
+}
+
+// compensationBookings returns the holidays from fromYear to toYear falling on the compensated
+// weekdays of the profile as bookings giving back a day of leave.
+func compensationBookings(ctx context.Context, profile storage.Profile, fromYear, toYear int) ([]storage.Booking, error) {
+	dates, err := bridgesPlanner.Compensations(ctx, planner.Options{
+		Country: profile.Country,
+		City:    profile.City,
+		DaysOff: profile.DaysOff,
+	}, profile.CompensatedDays, civil.NewDate(fromYear, time.January, 1), civil.NewDate(toYear, time.December, 31))
+	if err != nil {
+		return nil, err
+	}
+	compensations := make([]storage.Booking, 0, len(dates))
+	for _, date := range dates {
+		bridgeID := date.String() + "-" + date.String()
+		compensations = append(compensations, storage.Booking{
+			Id:        "compensation-" + date.String(),
+			ProfileId: profile.Id,
+			BridgeId:  bridgeID,
+			Status:    storage.BookingCompensation,
+			Start:     date,
+			End:       date,
+			LeaveDays: 1,
+		})
+	}
+	return compensations, nil
+}
+
+// forcedBookings returns the closures of the organization from fromYear to toYear as bookings
+// costing the working days of the profile they take.
+func forcedBookings(ctx context.Context, profile storage.Profile, organization *storage.Organization, fromYear, toYear int) ([]storage.Booking, error) {
+	ranges, err := closureRanges(organization.Closures, fromYear, toYear)
+	if err != nil {
+		return nil, err
+	}
+	holidays := append(append([]bridges.CustomHolidays{}, profile.CustomHolidays...), organization.CustomHolidays...)
+	forced := make([]storage.Booking, 0, len(ranges))
+	for _, closure := range ranges {
+		customHolidays, err := customHolidayDates(ctx, holidays, closure.Start.Year, closure.End.Year-closure.Start.Year)
+		if err != nil {
+			return nil, err
+		}
+		leaveDays, err := bridgesPlanner.LeaveCost(ctx, planner.Options{
+			Country:        profile.Country,
+			City:           profile.City,
+			DaysOff:        profile.DaysOff,
+			CustomHolidays: customHolidays,
+		}, closure.Start, closure.End)
+		if err != nil {
+			return nil, err
+		}
+		bridgeID := closure.Start.String() + "-" + closure.End.String()
+		forced = append(forced, storage.Booking{
+			Id:        "closure-" + bridgeID,
+			ProfileId: profile.Id,
+			BridgeId:  bridgeID,
+			Status:    storage.BookingForced,
+			Start:     closure.Start,
+			End:       closure.End,
+			LeaveDays: leaveDays,
+		})
+	}
+	return forced, nil
+}
+
+// decodeOrganization reads the editable fields of an organization from the request body.
+func decodeOrganization(req *http.Request) (storage.Organization, error) {
+	var body struct {
+		Name           string                   `json:"name"`
+		Closures       []storage.Closure        `json:"closures"`
+		CustomHolidays []bridges.CustomHolidays `json:"customHolidays"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return storage.Organization{}, err
+	}
+	if body.Name == "" {
+		return storage.Organization{}, errOrganizationNameIsRequired
+	}
+	year := time.Now().UTC().Year()
+	if _, err := closureRanges(body.Closures, year, year); err != nil {
+		return storage.Organization{}, err
+	}
+	if _, err := customHolidayDates(context.Background(), body.CustomHolidays, year, 0); err != nil {
+		return storage.Organization{}, err
+	}
+	if body.Closures == nil {
+		body.Closures = []storage.Closure{}
+	}
+	if body.CustomHolidays == nil {
+		body.CustomHolidays = []bridges.CustomHolidays{}
+	}
+	return storage.Organization{Name: body.Name, Closures: body.Closures, CustomHolidays: body.CustomHolidays}, nil
+}
+
+func writeOrganizationError(w http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, errOrganizationNotFound), errors.Is(err, storage.ErrNotFound):
+		http.Error(w, errOrganizationNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, errOrganizationNotOwned):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errAuthenticationNeeded):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		glogger.Get(req.Context()).WithError(err).Error("failed organization storage")
+		http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ownedOrganization returns the organization only when it is owned by the caller of the request,
+// since organizations are readable by everyone linking a profile to them but changed by their owner.
+// Anonymous callers own no organization.
+func ownedOrganization(ctx context.Context, store storage.Store, id string) (storage.Organization, error) {
+	if profileOwner(ctx) == "" {
+		return storage.Organization{}, errAuthenticationNeeded
+	}
+	organization, err := store.GetOrganization(ctx, id)
+	if err != nil {
+		return storage.Organization{}, err
+	}
+	if organization.Owner != profileOwner(ctx) {
+		return storage.Organization{}, errOrganizationNotOwned
+	}
+	return organization, nil
+}
+
+func createOrganization(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner := profileOwner(req.Context())
+		if owner == "" {
+			http.Error(w, errAuthenticationNeeded.Error(), http.StatusUnauthorized)
+			return
+		}
+		organization, err := decodeOrganization(req)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		organization.Owner = owner
+
+		created, err := store.CreateOrganization(req.Context(), organization)
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		w.Header().Set("Location", path.Join(req.URL.Path, created.Id))
+		writeResponse(glogger.Get(req.Context()), w, http.StatusCreated, created)
+	}
+}
+
+// listOrganizations returns the organizations owned by the caller, which must be authenticated.
+func listOrganizations(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner := profileOwner(req.Context())
+		if owner == "" {
+			http.Error(w, errAuthenticationNeeded.Error(), http.StatusUnauthorized)
+			return
+		}
+		organizations, err := store.ListOrganizations(req.Context(), owner)
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, organizations)
+	}
+}
+
+func getOrganization(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		organization, err := store.GetOrganization(req.Context(), mux.Vars(req)["id"])
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, organization)
+	}
+}
+
+func updateOrganization(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		organization, err := decodeOrganization(req)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		existing, err := ownedOrganization(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		organization.Id = existing.Id
+
+		updated, err := store.UpdateOrganization(req.Context(), organization)
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, updated)
+	}
+}
+
+func deleteOrganization(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		organization, err := ownedOrganization(req.Context(), store, mux.Vars(req)["id"])
+		if err == nil {
+			err = store.DeleteOrganization(req.Context(), organization.Id)
+		}
+		if err != nil {
+			writeOrganizationError(w, req, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
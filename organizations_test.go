@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClosureRanges(testCase *testing.T) {
+	day := func(year int, month time.Month, day int) civil.Date {
+		return civil.NewDate(year, month, day)
+	}
+
+	testCase.Run("dated and yearly closures", func(t *testing.T) {
+		ranges, err := closureRanges([]storage.Closure{
+			{Name: "move", Start: "2030-03-04", End: "2030-03-05"},
+			{Name: "old move", Start: "2020-03-04", End: "2020-03-05"},
+			{Name: "summer", Start: "08-12", End: "08-18"},
+		}, 2030, 2031)
+		require.NoError(t, err)
+		require.Equal(t, []closureRange{
+			{Name: "move", Start: day(2030, 3, 4), End: day(2030, 3, 5)},
+			{Name: "summer", Start: day(2030, 8, 12), End: day(2030, 8, 18)},
+			{Name: "summer", Start: day(2031, 8, 12), End: day(2031, 8, 18)},
+		}, ranges)
+	})
+
+	testCase.Run("dated closures are cut to the years", func(t *testing.T) {
+		ranges, err := closureRanges([]storage.Closure{{Name: "forever", Start: "2000-01-01", End: "9999-12-31"}}, 2030, 2031)
+		require.NoError(t, err)
+		require.Equal(t, []closureRange{{Name: "forever", Start: day(2030, 1, 1), End: day(2031, 12, 31)}}, ranges)
+	})
+
+	testCase.Run("yearly closures across the end of the year", func(t *testing.T) {
+		ranges, err := closureRanges([]storage.Closure{{Name: "winter", Start: "12-24", End: "01-06"}}, 2030, 2030)
+		require.NoError(t, err)
+		require.Equal(t, []closureRange{
+			{Name: "winter", Start: day(2029, 12, 24), End: day(2030, 1, 6)},
+			{Name: "winter", Start: day(2030, 12, 24), End: day(2031, 1, 6)},
+		}, ranges)
+	})
+
+	testCase.Run("invalid", func(t *testing.T) {
+		for _, closure := range []storage.Closure{
+			{Start: "2030-03-05", End: "2030-03-04"},
+			{Start: "2030-03-05", End: "03-06"},
+			{Start: "08-12"},
+			{Start: "august", End: "september"},
+		} {
+			_, err := closureRanges([]storage.Closure{closure}, 2030, 2030)
+			require.Error(t, err, closure)
+		}
+	})
+}
+
+func TestForcedBookings(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	day := func(month time.Month, day int) civil.Date { return civil.NewDate(2030, month, day) }
+	profile := storage.Profile{Id: "profile", BridgesRequest: bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}}}
+	organization := &storage.Organization{
+		Closures:       []storage.Closure{{Name: "summer", Start: "08-12", End: "08-18"}},
+		CustomHolidays: []bridges.CustomHolidays{{Date: "08-16"}},
+	}
+
+	testCase.Run("closures cost the working days", func(t *testing.T) {
+		forced, err := forcedBookings(context.Background(), profile, organization, 2030, 2030)
+		require.NoError(t, err)
+		require.Len(t, forced, 1)
+		require.Equal(t, storage.BookingForced, forced[0].Status)
+		require.Equal(t, day(8, 12), forced[0].Start)
+		// from Monday to Friday, without Ferragosto and the holiday of the organization
+		require.Equal(t, 3, forced[0].LeaveDays)
+	})
+
+	testCase.Run("bridges within closures are removed", func(t *testing.T) {
+		forced, err := forcedBookings(context.Background(), profile, organization, 2030, 2030)
+		require.NoError(t, err)
+		filter := bookingsFilter(storage.Profile{}, forced)
+		filtered := filter(bridges.YearBridges{Bridges: []bridges.Bridge{
+			{Id: "within", Start: civil.NewDate(2030, 8, 15), End: civil.NewDate(2030, 8, 18)},
+			{Id: "extending", Start: civil.NewDate(2030, 8, 15), End: civil.NewDate(2030, 8, 20), WeekdaysCount: 1},
+		}})
+		require.Len(t, filtered.Bridges, 1)
+		require.Equal(t, "extending", filtered.Bridges[0].Id)
+	})
+}
+
+func TestOrganizationsRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	store := storage.NewMemory()
+	testRouter := mux.NewRouter()
+	// the test subject header stands in for the authentication middleware
+	testRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if subject := req.Header.Get("X-Test-Subject"); subject != "" {
+				req = req.WithContext(contextWithIdentity(req.Context(), Identity{Subject: subject, Method: authMethodAPIKey}))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	setupBridgesRouter(testRouter, cache.New(16), store, testMessages, testEnv)
+	setupProfilesRouter(testRouter, store)
+	setupOrganizationsRouter(testRouter, store)
+	setupBookingsRouter(testRouter, store)
+
+	serve := func(method, target, subject string, body interface{}) *httptest.ResponseRecorder {
+		var requestBody bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&requestBody).Encode(body)
+		}
+		request := httptest.NewRequest(method, target, &requestBody)
+		if subject != "" {
+			request.Header.Set("X-Test-Subject", subject)
+		}
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+
+	var organization storage.Organization
+	testCase.Run("create", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/organizations", "admin", map[string]interface{}{
+			"name":     "Mia",
+			"closures": []storage.Closure{{Name: "summer", Start: "08-10", End: "08-21"}},
+		})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &organization))
+		require.Equal(t, "admin", organization.Owner)
+		require.Equal(t, "/organizations/"+organization.Id, responseRecorder.Header().Get("Location"))
+	})
+
+	testCase.Run("create - invalid", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/organizations", "admin", map[string]string{}).Code)
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/organizations", "admin", map[string]interface{}{
+			"name":     "Mia",
+			"closures": []storage.Closure{{Name: "summer", Start: "08-10"}},
+		}).Code)
+	})
+
+	testCase.Run("only the owner changes the organization", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/organizations/"+organization.Id, "user-1", nil).Code)
+		require.Equal(t, http.StatusForbidden, serve(http.MethodPut, "/organizations/"+organization.Id, "user-1", map[string]string{"name": "stolen"}).Code)
+		require.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/organizations/"+organization.Id, "user-1", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/organizations/unknown", "user-1", nil).Code)
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/organizations/"+organization.Id, "", nil).Code)
+	})
+
+	testCase.Run("anonymous callers change no organization", func(t *testing.T) {
+		require.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/organizations", "", map[string]string{"name": "ownerless"}).Code)
+		ownerless, err := store.CreateOrganization(context.Background(), storage.Organization{Name: "ownerless"})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, serve(http.MethodPut, "/organizations/"+ownerless.Id, "", map[string]string{"name": "stolen"}).Code)
+		require.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/organizations/"+ownerless.Id, "", nil).Code)
+		require.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/organizations/"+ownerless.Id, "user-1", nil).Code)
+
+		responseRecorder := serve(http.MethodGet, "/organizations", "admin", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var organizations []storage.Organization
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &organizations))
+		require.Len(t, organizations, 1)
+	})
+
+	var profile storage.Profile
+	testCase.Run("closures take the leave of the linked profiles", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/profiles", "user-1", map[string]interface{}{
+			"name":           "work",
+			"organizationId": "unknown",
+		}).Code)
+
+		responseRecorder := serve(http.MethodPost, "/profiles", "user-1", map[string]interface{}{
+			"name":            "work",
+			"city":            "Milano",
+			"daysOff":         []int{0, 6},
+			"annualLeaveDays": 20,
+			"yearsScope":      1,
+			"organizationId":  organization.Id,
+		})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &profile))
+
+		responseRecorder = serve(http.MethodGet, "/profiles/"+profile.Id+"/leave", "user-1", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var balances []leaveBalance
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &balances))
+		// two weeks of August always take at least eight working days
+		require.GreaterOrEqual(t, balances[0].Forced, 8)
+		require.Equal(t, 20-balances[0].Forced, *balances[0].Remaining)
+
+		responseRecorder = serve(http.MethodGet, "/bridges?profile="+profile.Id, "user-1", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var years []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &years))
+		for _, bridge := range years[0].Bridges {
+			closureStart := civil.NewDate(bridge.Start.Year, 8, 10)
+			closureEnd := civil.NewDate(bridge.Start.Year, 8, 21)
+			require.False(t, !bridge.Start.Before(closureStart) && !bridge.End.After(closureEnd), bridge.Id)
+			require.LessOrEqual(t, bridge.WeekdaysCount, *balances[0].Remaining, bridge.Id)
+		}
+	})
+
+	testCase.Run("delete unlinks the profiles", func(t *testing.T) {
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/organizations/"+organization.Id, "admin", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/organizations/"+organization.Id, "admin", nil).Code)
+
+		responseRecorder := serve(http.MethodGet, "/profiles/"+profile.Id, "user-1", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var unlinked storage.Profile
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &unlinked))
+		require.Empty(t, unlinked.OrganizationId)
+	})
+}
@@ -0,0 +1,63 @@
+package planner_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/planner"
+)
+
+func ExamplePlan() {
+	// Holidays are read from the language packs in LANGUAGE_PACK_FILE_PATH.
+	result, err := planner.Plan(context.Background(), planner.Options{
+		City:      "Milano",
+		DaysOff:   []int{0, 6},
+		LeaveDays: 2,
+		Years:     1,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, yearBridges := range result.Years {
+		for _, bridge := range yearBridges.Bridges {
+			fmt.Println(bridge.Id, bridge.IsTop)
+		}
+	}
+}
+
+func ExampleNew() {
+	// A company calendar with only two holidays, evaluated as if today was the 1st of January 2021.
+	calendar := planner.CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		return []civil.Date{
+			civil.NewDate(year, time.June, 2),
+			civil.NewDate(year, time.December, 8),
+		}, nil
+	})
+	clock := planner.ClockFunc(func() time.Time {
+		return civil.NewDate(2021, time.January, 1).In(time.UTC)
+	})
+	longest := func(bridge bridges.Bridge) float32 {
+		return float32(bridge.DaysCount)
+	}
+
+	p := planner.New(planner.WithCalendar(calendar), planner.WithClock(clock), planner.WithScorer(longest))
+	result, err := p.Plan(context.Background(), planner.Options{DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, bridge := range result.Years[0].Bridges {
+		if bridge.IsTop {
+			fmt.Println(bridge.Id, bridge.DaysCount, bridge.WeekdaysCount)
+		}
+	}
+	// Output:
+	// 2021-05-29-2021-06-02 5 2
+	// 2021-06-02-2021-06-06 5 2
+	// 2021-12-04-2021-12-08 5 2
+	// 2021-12-08-2021-12-12 5 2
+}
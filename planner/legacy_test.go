@@ -0,0 +1,311 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// legacyBridgesByYear is the day by day implementation of bridgesByYear, which must return the
+// same bridges. It computes the bridges of year: every bridge starts with an holiday
+// and is extended using at most maxAvailability days of leave. Only the two best scoring groups
+// of bridges are returned, with the best one flagged as top. When explain is set, every bridge
+// holds the breakdown of its days, as told by the same function used to build it.
+func (p *Planner) legacyBridgesByYear(ctx context.Context, year int, maxAvailability int, country string, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, explain bool, today civil.Date) (bridges.YearBridges, error) {
+	ctx, span := tracer.Start(ctx, "bridgesByYear", trace.WithAttributes(attribute.Int("feriapp.year", year)))
+	defer span.End()
+
+	var daysOffMap = make(map[int]bool)
+	for i := 0; i < len(daysOff); i += 1 {
+		daysOffMap[daysOff[i]] = true
+	}
+	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	var currentDate = startDate
+	// any hour of today skips the same days
+	now := today.In(time.UTC).Add(time.Hour)
+
+	yearDayType, err := p.dayTypeFunc(ctx, currentDate.Year(), daysOffMap, country, city, customHolidays)
+	if err != nil {
+		return bridges.YearBridges{}, err
+	}
+	dayType := func(date time.Time) string {
+		return yearDayType(civil.DateOf(date))
+	}
+	isHolidays := func(date time.Time) bool {
+		return dayType(date) != bridges.DayWorking
+	}
+
+	var candidates []bridges.Bridge
+
+	for !currentDate.UTC().After(time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		if err := ctx.Err(); err != nil {
+			return bridges.YearBridges{}, err
+		}
+
+		isCurrentDateHolidays := isHolidays(currentDate)
+		availableDays := (map[bool]int{true: maxAvailability, false: maxAvailability - 1})[isCurrentDateHolidays]
+		// if no more days off are left and today is not holiday the bridge is closed
+		if maxAvailability == 0 && !isCurrentDateHolidays {
+			currentDate = currentDate.AddDate(0, 0, 1)
+			continue
+		}
+		// if skipPastBridges is true only bridges that happens after today - maxAvailability day will be returned
+		if currentDate.Before(now.AddDate(0, 0, -(maxAvailability+1))) && skipPastBridges {
+			currentDate = currentDate.AddDate(0, 0, 1)
+			continue
+		}
+		currentBridge := bridges.Bridge{
+			Start:         civil.DateOf(currentDate),
+			End:           civil.DateOf(currentDate),
+			HolidaysCount: (map[bool]int{true: 1, false: 0})[isCurrentDateHolidays],
+			WeekdaysCount: (map[bool]int{true: 0, false: 1})[isCurrentDateHolidays],
+			DaysCount:     1,
+		}
+		if explain {
+			currentBridge.Days = []bridges.Day{newDay(civil.DateOf(currentDate), dayType(currentDate))}
+		}
+
+		nextDate := currentDate
+		nextDate = nextDate.AddDate(0, 0, 1)
+		// a bridge should always start with an holiday
+		if currentBridge.DaysCount == 1 && !isCurrentDateHolidays {
+			currentDate = currentDate.AddDate(0, 0, 1)
+			continue
+		}
+
+		for availableDays > 0 || isHolidays(nextDate) {
+			isNextDateHolidays := isHolidays(nextDate)
+			if explain {
+				currentBridge.Days = append(currentBridge.Days, newDay(civil.DateOf(nextDate), dayType(nextDate)))
+			}
+
+			if isNextDateHolidays {
+				currentBridge.HolidaysCount++
+			} else {
+				currentBridge.WeekdaysCount++
+				availableDays -= 1
+			}
+
+			currentBridge.End = civil.DateOf(nextDate)
+			currentBridge.DaysCount++
+			nextDate = nextDate.AddDate(0, 0, 1)
+			if nextDate.Year() != currentDate.Year() {
+				yearDayType, err = p.dayTypeFunc(ctx, nextDate.Year(), daysOffMap, country, city, customHolidays)
+				if err != nil {
+					return bridges.YearBridges{}, err
+				}
+			}
+		}
+		for isHolidays(currentDate) {
+			currentDate = currentDate.AddDate(0, 0, 1)
+		}
+
+		// the bridge is inserted only if it is longer than daysOff (es: exlude weekend bridges)
+		// and if it is not in the past for more than maxAvailability days
+		currentBridge.Id = fmt.Sprintf("%s-%s", currentBridge.Start, currentBridge.End)
+
+		if currentBridge.DaysCount > len(daysOff) {
+			candidates = append(candidates, currentBridge)
+		}
+	}
+	return p.rank(year, candidates, span), nil
+}
+
+// legacyWindowsByYear is the day by day implementation of windowsByYear, which must return the
+// same bridges. It computes the bridges of year like bridgesByYear, but considering
+// the windows starting on any day of the year and spending from none to maxAvailability days
+// of leave, before as well as after the holidays. Only the windows that cannot be extended
+// without more leave are candidates: they follow a working day and precede another one.
+func (p *Planner) legacyWindowsByYear(ctx context.Context, year int, maxAvailability int, country string, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, explain bool, today civil.Date) (bridges.YearBridges, error) {
+	ctx, span := tracer.Start(ctx, "windowsByYear", trace.WithAttributes(attribute.Int("feriapp.year", year)))
+	defer span.End()
+
+	daysOffMap := make(map[int]bool)
+	for _, dayOff := range daysOff {
+		daysOffMap[dayOff] = true
+	}
+	// any hour of today skips the same days
+	now := today.In(time.UTC).Add(time.Hour)
+	var err error
+	dayTypes := map[int]func(date civil.Date) string{}
+	dayType := func(date time.Time) string {
+		yearDayType, ok := dayTypes[date.Year()]
+		if !ok {
+			if yearDayType, err = p.dayTypeFunc(ctx, date.Year(), daysOffMap, country, city, customHolidays); err != nil {
+				yearDayType = func(civil.Date) string { return bridges.DayWorking }
+			}
+			dayTypes[date.Year()] = yearDayType
+		}
+		return yearDayType(civil.DateOf(date))
+	}
+	isHolidays := func(date time.Time) bool {
+		return dayType(date) != bridges.DayWorking
+	}
+
+	var candidates []bridges.Bridge
+	lastDate := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+	for start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); !start.After(lastDate); start = start.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return bridges.YearBridges{}, err
+		}
+		// a window following a non working day would be longer starting the day before
+		if isHolidays(start.AddDate(0, 0, -1)) {
+			continue
+		}
+		if skipPastBridges && start.Before(now.AddDate(0, 0, -(maxAvailability+1))) {
+			continue
+		}
+
+		window := bridges.Bridge{Start: civil.DateOf(start)}
+		// windows are bounded to a year, in case no day is worked at all
+		for day := start; day.Before(start.AddDate(1, 0, 0)); day = day.AddDate(0, 0, 1) {
+			if isHolidays(day) {
+				window.HolidaysCount++
+			} else if window.WeekdaysCount < maxAvailability {
+				window.WeekdaysCount++
+			} else {
+				break
+			}
+			window.End = civil.DateOf(day)
+			window.DaysCount++
+			if explain {
+				window.Days = append(window.Days, newDay(civil.DateOf(day), dayType(day)))
+			}
+			if isHolidays(day.AddDate(0, 0, 1)) || window.HolidaysCount == 0 || window.DaysCount <= len(daysOff) {
+				continue
+			}
+			candidate := window
+			candidate.Id = fmt.Sprintf("%s-%s", candidate.Start, candidate.End)
+			candidate.Days = window.Days[:len(window.Days):len(window.Days)]
+			candidates = append(candidates, candidate)
+		}
+	}
+	if err != nil {
+		return bridges.YearBridges{}, err
+	}
+	return p.rank(year, candidates, span), nil
+}
+
+// searchFunc is the signature shared by the searches and their day by day implementations.
+type searchFunc func(ctx context.Context, year int, maxAvailability int, country string, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, explain bool, today civil.Date) (bridges.YearBridges, error)
+
+// randomCalendar returns a calendar with random holidays, always the same for a year.
+func randomCalendar(seed int64) Calendar {
+	return CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		random := rand.New(rand.NewSource(seed*10000 + int64(year)))
+		holidays := []civil.Date{}
+		for count := random.Intn(30); count > 0; count-- {
+			holidays = append(holidays, civil.NewDate(year, time.January, 1+random.Intn(365)))
+		}
+		// the end of the year is where the day by day search typed the days with the wrong year
+		if random.Intn(2) == 0 {
+			holidays = append(holidays, civil.NewDate(year, time.December, 31))
+		}
+		if random.Intn(2) == 0 {
+			holidays = append(holidays, civil.NewDate(year, time.January, 1))
+		}
+		return holidays, nil
+	})
+}
+
+// cachedLanguagePacks returns the calendar of the language packs, reading the holidays of
+// every year and city once.
+func cachedLanguagePacks() Calendar {
+	holidays := map[string][]civil.Date{}
+	return CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		key := fmt.Sprintf("%d-%s-%s", year, country, city)
+		if _, ok := holidays[key]; !ok {
+			yearHolidays, err := helpers.Holidays(ctx, year, country, city)
+			if err != nil {
+				return nil, err
+			}
+			holidays[key] = yearHolidays
+		}
+		return holidays[key], nil
+	})
+}
+
+func TestDifferential(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	daysOffSets := [][]int{{0, 6}, {0}, {}, {5, 6, 0}, {3}}
+
+	compare := func(t *testing.T, p *Planner, search, legacy searchFunc, year int, maxAvailability int, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, today civil.Date) {
+		expected, err := legacy(context.Background(), year, maxAvailability, "IT", city, daysOff, customHolidays, skipPastBridges, true, today)
+		require.NoError(t, err)
+		actual, err := search(context.Background(), year, maxAvailability, "IT", city, daysOff, customHolidays, skipPastBridges, true, today)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, "year %d, %d days of leave, days off %v, custom holidays %v, skip past %t from %s", year, maxAvailability, daysOff, customHolidays, skipPastBridges, today)
+	}
+
+	testCase.Run("language packs", func(t *testing.T) {
+		p := New(WithCalendar(cachedLanguagePacks()))
+		for _, city := range []string{"", "Milano", "Roma"} {
+			for year := 2018; year <= 2032; year++ {
+				for maxAvailability := 0; maxAvailability <= 3; maxAvailability++ {
+					for _, daysOff := range daysOffSets {
+						today := civil.NewDate(year, time.January, 1)
+						compare(t, p, p.bridgesByYear, p.legacyBridgesByYear, year, maxAvailability, city, daysOff, nil, false, today)
+						compare(t, p, p.windowsByYear, p.legacyWindowsByYear, year, maxAvailability, city, daysOff, nil, false, today)
+					}
+				}
+			}
+		}
+	})
+
+	testCase.Run("random calendars", func(t *testing.T) {
+		random := rand.New(rand.NewSource(1))
+		for seed := int64(0); seed < 300; seed++ {
+			p := New(WithCalendar(randomCalendar(seed)))
+			year := 2000 + random.Intn(50)
+			customHolidays := []civil.Date{}
+			for count := random.Intn(5); count > 0; count-- {
+				customHolidays = append(customHolidays, civil.NewDate(year, time.January, 1+random.Intn(730)))
+			}
+			today := civil.NewDate(year, time.January, 1+random.Intn(400))
+			daysOff := daysOffSets[random.Intn(len(daysOffSets))]
+			maxAvailability := random.Intn(6)
+			for _, skipPastBridges := range []bool{false, true} {
+				compare(t, p, p.bridgesByYear, p.legacyBridgesByYear, year, maxAvailability, "", daysOff, customHolidays, skipPastBridges, today)
+				compare(t, p, p.windowsByYear, p.legacyWindowsByYear, year, maxAvailability, "", daysOff, customHolidays, skipPastBridges, today)
+			}
+		}
+	})
+}
+
+func BenchmarkBridgesByYear(b *testing.B) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	// the holidays are read once, so that only the searches are measured
+	p := New(WithCalendar(cachedLanguagePacks()))
+	today := civil.NewDate(2021, time.January, 1)
+	searches := []struct {
+		name   string
+		search searchFunc
+	}{
+		{"calendar", p.bridgesByYear},
+		{"day by day", p.legacyBridgesByYear},
+		{"calendar windows", p.windowsByYear},
+		{"day by day windows", p.legacyWindowsByYear},
+	}
+	for _, search := range searches {
+		for _, maxAvailability := range []int{2, 10} {
+			b.Run(fmt.Sprintf("%s/%d days", search.name, maxAvailability), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := search.search(context.Background(), today.Year, maxAvailability, "IT", "Milano", []int{0, 6}, nil, false, false, today); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package planner
+
+import (
+	"sort"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+)
+
+// Modes of Options.Overlap, telling how the bridges sharing some days are returned.
+// When empty, bridges are returned as computed.
+const (
+	// OverlapList returns every bridge, with the cluster of the bridges it overlaps.
+	OverlapList = "list"
+	// OverlapCollapse returns the dominant bridge of every cluster only, with the others as its alternatives.
+	OverlapCollapse = "collapse"
+)
+
+func validOverlap(mode string) bool {
+	return mode == "" || mode == OverlapList || mode == OverlapCollapse
+}
+
+// resolveOverlaps groups into clusters the bridges sharing some days, also through other bridges,
+// identifying each cluster by the days it spans. Bridges keep their order, and with OverlapCollapse
+// only the dominant one of every cluster is kept, holding the others in chronological order.
+func resolveOverlaps(candidates []bridges.Bridge, mode string, scorer Scorer) []bridges.Bridge {
+	if mode == "" || len(candidates) == 0 {
+		return candidates
+	}
+
+	order := make([]int, len(candidates))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		first, second := candidates[order[i]], candidates[order[j]]
+		if first.Start != second.Start {
+			return first.Start.Before(second.Start)
+		}
+		return first.End.Before(second.End)
+	})
+
+	var clusters [][]int
+	for _, index := range order {
+		last := len(clusters) - 1
+		if last < 0 || candidates[index].Start.After(clusterEnd(candidates, clusters[last])) {
+			clusters = append(clusters, []int{index})
+			continue
+		}
+		clusters[last] = append(clusters[last], index)
+	}
+
+	clusterIDs := make([]string, len(candidates))
+	dominants := make(map[int][]int, len(clusters))
+	for _, cluster := range clusters {
+		id := candidates[cluster[0]].Start.String() + "-" + clusterEnd(candidates, cluster).String()
+		dominant := cluster[0]
+		for _, index := range cluster {
+			clusterIDs[index] = id
+			if dominates(candidates[index], candidates[dominant], scorer) {
+				dominant = index
+			}
+		}
+		dominants[dominant] = cluster
+	}
+
+	resolved := make([]bridges.Bridge, 0, len(candidates))
+	for index, bridge := range candidates {
+		bridge.Cluster = clusterIDs[index]
+		if mode == OverlapCollapse {
+			cluster, ok := dominants[index]
+			if !ok {
+				continue
+			}
+			bridge.Alternatives = []bridges.Bridge{}
+			for _, alternative := range cluster {
+				if alternative != index {
+					alternativeBridge := candidates[alternative]
+					alternativeBridge.Cluster = clusterIDs[alternative]
+					bridge.Alternatives = append(bridge.Alternatives, alternativeBridge)
+				}
+			}
+		}
+		resolved = append(resolved, bridge)
+	}
+	return resolved
+}
+
+func clusterEnd(candidates []bridges.Bridge, cluster []int) civil.Date {
+	end := candidates[cluster[0]].End
+	for _, index := range cluster[1:] {
+		if candidates[index].End.After(end) {
+			end = candidates[index].End
+		}
+	}
+	return end
+}
+
+// dominates tells whether bridge is better than other: it scores more, or it costs less leave
+// with the same score, or it comes first when they are equivalent.
+func dominates(bridge, other bridges.Bridge, scorer Scorer) bool {
+	if score, otherScore := scorer(bridge), scorer(other); score != otherScore {
+		return score > otherScore
+	}
+	if bridge.WeekdaysCount != other.WeekdaysCount {
+		return bridge.WeekdaysCount < other.WeekdaysCount
+	}
+	return bridge.Start.Before(other.Start)
+}
@@ -0,0 +1,638 @@
+// Package planner computes the best bridges, the periods where a few days of leave
+// join holidays and days off into a long vacation.
+//
+// The zero configuration uses the language packs of the helpers package as calendar,
+// the default scorer and the system clock:
+//
+//	result, err := planner.Plan(ctx, planner.Options{City: "Milano", LeaveDays: 2})
+//
+// Planners with custom calendars, scorers or clocks are created with New.
+package planner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/calendar"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/helpers"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// SearchWindow considers the windows starting on any day, leave days included,
+	// so that the leave can be taken before the holidays as well as after them.
+	SearchWindow = "window"
+)
+
+const (
+	// DefaultCountry is the country used when Options.Country is empty.
+	DefaultCountry = "IT"
+	// DefaultYears is the number of years planned when Options.Years is zero.
+	DefaultYears = 3
+)
+
+var tracer = otel.Tracer("feriapp-backend-go/planner")
+
+// Options describes what to plan.
+type Options struct {
+	// Country selects the national holidays, DefaultCountry when empty.
+	Country string
+	// City adds the patron day of the city to the holidays.
+	City string
+	// DaysOff are the weekdays that are not worked, from 0 (Sunday) to 6 (Saturday).
+	DaysOff []int
+	// LeaveDays is the maximum number of days of leave spent on a single bridge.
+	LeaveDays int
+	// CustomHolidays are days not worked in addition to the ones of the calendar.
+	CustomHolidays []civil.Date
+	// Years is the number of years to plan starting from the current one, DefaultYears when zero.
+	Years int
+	// IncludePast keeps the bridges that can no longer be taken because they already started.
+	IncludePast bool
+	// Location is the time zone telling which day is today, UTC when nil.
+	Location *time.Location
+	// Until drops the bridges starting after it, when set.
+	Until civil.Date
+	// Budget drops the bridges whose leave cannot be afforded, when set.
+	Budget Budget
+	// Explain adds to every bridge the breakdown of its days.
+	Explain bool
+	// Overlap tells how the bridges sharing some days are returned: OverlapList or
+	// OverlapCollapse, or as computed when empty.
+	Overlap string
+	// Search selects how the bridges are searched: SearchWindow, or starting from
+	// the holidays and extending forward only when empty.
+	Search string
+}
+
+// Result holds the planned bridges of every year, in chronological order.
+type Result struct {
+	Years []bridges.YearBridges
+}
+
+// Budget tells whether the leave needed by a bridge can be taken, such as a leave.Account.
+type Budget interface {
+	CanAfford(date civil.Date, days int) bool
+}
+
+// Scorer rates a bridge: higher scores are better.
+type Scorer func(bridge bridges.Bridge) float32
+
+// Calendar provides the holidays of a year.
+type Calendar interface {
+	Holidays(ctx context.Context, year int, country string, city string) ([]civil.Date, error)
+}
+
+// CalendarFunc adapts a function to the Calendar interface.
+type CalendarFunc func(ctx context.Context, year int, country string, city string) ([]civil.Date, error)
+
+// Holidays calls f.
+func (f CalendarFunc) Holidays(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+	return f(ctx, year, country, city)
+}
+
+// Clock tells the current time, used to skip the bridges already started.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// Observer is notified after the bridges of a year have been computed.
+type Observer func(year int, elapsed time.Duration)
+
+// Option configures a Planner.
+type Option func(*Planner)
+
+// WithScorer replaces DefaultScorer.
+func WithScorer(scorer Scorer) Option {
+	return func(p *Planner) {
+		p.scorer = scorer
+	}
+}
+
+// WithCalendar replaces the language pack calendar.
+func WithCalendar(calendar Calendar) Option {
+	return func(p *Planner) {
+		p.calendar = calendar
+	}
+}
+
+// WithClock replaces the system clock.
+func WithClock(clock Clock) Option {
+	return func(p *Planner) {
+		p.clock = clock
+	}
+}
+
+// WithObserver registers a function notified after each year is computed.
+func WithObserver(observer Observer) Option {
+	return func(p *Planner) {
+		p.observer = observer
+	}
+}
+
+// Planner computes bridges. It is safe for concurrent use.
+type Planner struct {
+	scorer   Scorer
+	calendar Calendar
+	clock    Clock
+	observer Observer
+}
+
+// New creates a Planner with the given options applied over the defaults.
+func New(options ...Option) *Planner {
+	p := &Planner{
+		scorer:   DefaultScorer,
+		calendar: LanguagePackCalendar{},
+		clock:    ClockFunc(time.Now),
+		observer: func(int, time.Duration) {},
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Plan computes the bridges with a Planner using the default configuration.
+func Plan(ctx context.Context, options Options) (Result, error) {
+	return New().Plan(ctx, options)
+}
+
+// Plan computes the bridges of every year in options, one year after the other.
+func (p *Planner) Plan(ctx context.Context, options Options) (Result, error) {
+	result := Result{Years: []bridges.YearBridges{}}
+	err := p.Stream(ctx, options, func(yearBridges bridges.YearBridges) error {
+		result.Years = append(result.Years, yearBridges)
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// Stream computes the bridges like Plan, passing each year to emit as soon as it is computed.
+// It stops at the first error returned by emit, or when ctx is done.
+// It fails when the calendar does not know the country of options.
+func (p *Planner) Stream(ctx context.Context, options Options, emit func(bridges.YearBridges) error) error {
+	options = withDefaults(options)
+	if err := p.checkCountry(options.Country); err != nil {
+		return err
+	}
+	if options.LeaveDays < 0 {
+		return fmt.Errorf("leave days must not be negative, got %d", options.LeaveDays)
+	}
+	for _, dayOff := range options.DaysOff {
+		if dayOff < 0 || dayOff > 6 {
+			return fmt.Errorf("invalid day off %d: must be a weekday between 0 and 6", dayOff)
+		}
+	}
+	if options.Search != "" && options.Search != SearchWindow {
+		return fmt.Errorf("invalid search %q: must be %s or empty", options.Search, SearchWindow)
+	}
+	if !validOverlap(options.Overlap) {
+		return fmt.Errorf("invalid overlap %q: must be %s or %s", options.Overlap, OverlapList, OverlapCollapse)
+	}
+
+	ctx, span := tracer.Start(ctx, "plan", trace.WithAttributes(
+		attribute.String("feriapp.city", options.City),
+		attribute.Int("feriapp.leave_days", options.LeaveDays),
+		attribute.Int("feriapp.years", options.Years),
+	))
+	defer span.End()
+
+	today := civil.Today(p.clock.Now(), options.Location)
+	for i := 0; i < options.Years; i++ {
+		if err := ctx.Err(); err != nil {
+			span.RecordError(err)
+			return err
+		}
+		year := today.Year + i
+		start := time.Now()
+		yearBridges, err := p.byYear(ctx, year, options, today)
+		p.observer(year, time.Since(start))
+		if err == nil {
+			err = emit(yearBridges)
+		}
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// LeaveCost returns the days of leave needed to be away from start to end included,
+// that are the days which are neither holidays nor days off for options.
+func (p *Planner) LeaveCost(ctx context.Context, options Options, start, end civil.Date) (int, error) {
+	days, err := p.Explain(ctx, options, start, end)
+	if err != nil {
+		return 0, err
+	}
+	cost := 0
+	for _, day := range days {
+		if day.Leave {
+			cost++
+		}
+	}
+	return cost, nil
+}
+
+// Explain returns the breakdown of the days from start to end included: whether each of them
+// is an holiday, a day off or a working day needing leave for options.
+func (p *Planner) Explain(ctx context.Context, options Options, start, end civil.Date) ([]bridges.Day, error) {
+	options = withDefaults(options)
+	if err := p.checkCountry(options.Country); err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("the end %s is before the start %s", end, start)
+	}
+
+	daysOffMap := make(map[int]bool)
+	for _, dayOff := range options.DaysOff {
+		daysOffMap[dayOff] = true
+	}
+	days := []bridges.Day{}
+	var dayType func(date civil.Date) string
+	for date := start; !date.After(end); date = date.AddDays(1) {
+		if dayType == nil || (date.Month == time.January && date.Day == 1) {
+			var err error
+			dayType, err = p.dayTypeFunc(ctx, date.Year, daysOffMap, options.Country, options.City, options.CustomHolidays)
+			if err != nil {
+				return nil, err
+			}
+		}
+		days = append(days, newDay(date, dayType(date)))
+	}
+	return days, nil
+}
+
+// Compensations returns the holidays of the calendar from start to end falling on the weekdays,
+// from 0 (Sunday) to 6 (Saturday), as the contracts giving a day of leave for every holiday
+// falling on Sunday. Easter, always on Sunday, and the custom holidays of options are never
+// compensated.
+func (p *Planner) Compensations(ctx context.Context, options Options, weekdays []int, start, end civil.Date) ([]civil.Date, error) {
+	compensated := make(map[int]bool, len(weekdays))
+	for _, weekday := range weekdays {
+		compensated[weekday] = true
+	}
+	options.CustomHolidays = nil
+	days, err := p.Explain(ctx, options, start, end)
+	if err != nil {
+		return nil, err
+	}
+	dates := []civil.Date{}
+	for _, day := range days {
+		if day.Type != bridges.DayHoliday || !compensated[int(day.Date.Weekday())] {
+			continue
+		}
+		if easter, err := helpers.CatholicByYear(day.Date.Year); err == nil && civil.DateOf(easter) == day.Date {
+			continue
+		}
+		dates = append(dates, day.Date)
+	}
+	return dates, nil
+}
+
+func newDay(date civil.Date, dayType string) bridges.Day {
+	return bridges.Day{Date: date, Type: dayType, Leave: dayType == bridges.DayWorking}
+}
+
+// checkCountry fails when the language pack calendar has no holidays for country, which
+// would plan every weekend as a bridge. Custom calendars are free to take any country.
+func (p *Planner) checkCountry(country string) error {
+	if _, ok := p.calendar.(LanguagePackCalendar); !ok {
+		return nil
+	}
+	return helpers.CheckCountry(country)
+}
+
+func withDefaults(options Options) Options {
+	if options.Country == "" {
+		options.Country = DefaultCountry
+	}
+	if options.Years == 0 {
+		options.Years = DefaultYears
+	}
+	return options
+}
+
+// byYear computes the bridges of year. Unless options.IncludePast is set,
+// bridges starting before the leave needed to reach them could be requested are skipped,
+// as well as those starting after options.Until or not affordable with options.Budget.
+// Overlapping bridges are then resolved as told by options.Overlap.
+func (p *Planner) byYear(ctx context.Context, year int, options Options, today civil.Date) (bridges.YearBridges, error) {
+	search := p.bridgesByYear
+	if options.Search == SearchWindow {
+		search = p.windowsByYear
+	}
+	yearBridges, err := search(ctx, year, options.LeaveDays, options.Country, options.City, options.DaysOff, options.CustomHolidays, !options.IncludePast, options.Explain, today)
+	if err != nil {
+		return yearBridges, err
+	}
+	if options.IncludePast && options.Until.IsZero() && options.Budget == nil {
+		yearBridges.Bridges = resolveOverlaps(yearBridges.Bridges, options.Overlap, p.scorer)
+		return yearBridges, nil
+	}
+	filteredBridges := []bridges.Bridge{}
+	for _, bridge := range yearBridges.Bridges {
+		if !options.IncludePast && !bridge.Start.After(today.AddDays(options.LeaveDays)) {
+			continue
+		}
+		if !options.Until.IsZero() && bridge.Start.After(options.Until) {
+			continue
+		}
+		if options.Budget != nil && !options.Budget.CanAfford(bridge.Start, bridge.WeekdaysCount) {
+			continue
+		}
+		filteredBridges = append(filteredBridges, bridge)
+	}
+	yearBridges.Bridges = resolveOverlaps(filteredBridges, options.Overlap, p.scorer)
+	return yearBridges, nil
+}
+
+// dayTypeFunc returns the function telling whether a day of year is an holiday, a day off
+// or a working day. Holidays falling on days off are reported as holidays.
+func (p *Planner) dayTypeFunc(ctx context.Context, year int, daysOffMap map[int]bool, country string, city string, customHolidays []civil.Date) (func(date civil.Date) string, error) {
+	holidays, err := p.calendar.Holidays(ctx, year, country, city)
+	if err != nil {
+		return nil, err
+	}
+	holidaysMap := make(map[civil.Date]bool, len(holidays)+len(customHolidays))
+	for _, holiday := range holidays {
+		holidaysMap[holiday] = true
+	}
+	for _, holiday := range customHolidays {
+		holidaysMap[holiday] = true
+	}
+	return func(date civil.Date) string {
+		switch {
+		case holidaysMap[date]:
+			return bridges.DayHoliday
+		case daysOffMap[int(date.Weekday())]:
+			return bridges.DayOff
+		default:
+			return bridges.DayWorking
+		}
+	}, nil
+}
+
+// bridgesByYear computes the bridges of the year of date: every bridge starts with an holiday
+// and is extended using at most maxAvailability days of leave. Only the two best scoring groups
+// of bridges are returned, with the best one flagged as top. When explain is set, every bridge
+// holds the breakdown of its days.
+//
+// The days are typed once in a calendar.Range, where every bridge is found in constant time.
+// Bridges are the same found walking the year day by day, also where that typed the days of
+// the year with the holidays of the next one, after a bridge had reached it.
+func (p *Planner) bridgesByYear(ctx context.Context, year int, maxAvailability int, country string, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, explain bool, today civil.Date) (bridges.YearBridges, error) {
+	ctx, span := tracer.Start(ctx, "bridgesByYear", trace.WithAttributes(attribute.Int("feriapp.year", year)))
+	defer span.End()
+
+	daysOffMap := make(map[int]bool)
+	for _, dayOff := range daysOff {
+		daysOffMap[dayOff] = true
+	}
+	yearDayType, err := p.dayTypeFunc(ctx, year, daysOffMap, country, city, customHolidays)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return bridges.YearBridges{}, err
+	}
+	nextYearDayType, err := p.dayTypeFunc(ctx, year+1, daysOffMap, country, city, customHolidays)
+	if err != nil {
+		return bridges.YearBridges{}, err
+	}
+
+	startDate := civil.NewDate(year, time.January, 1)
+	lastDate := civil.NewDate(year, time.December, 31)
+	length := civil.NewDate(year+2, time.January, 1).DaysSince(startDate)
+	// typedUntil types the days up to last with the holidays of the year, and the others with the
+	// holidays of the next one
+	typedUntil := func(last civil.Date) *calendar.Range {
+		return calendar.New(startDate, length, calendarType(func(date civil.Date) string {
+			if date.After(last) {
+				return nextYearDayType(date)
+			}
+			return yearDayType(date)
+		}))
+	}
+	days := typedUntil(lastDate)
+	lastIndex := days.Index(lastDate)
+	firstIndex := 0
+	if skipPastBridges {
+		firstIndex = days.Index(pastCutoff(today, maxAvailability))
+	}
+
+	var candidates []bridges.Bridge
+	crossed := false
+	for index := days.NextOff(firstIndex); index <= lastIndex; index = days.NextOff(index) {
+		if err := ctx.Err(); err != nil {
+			return bridges.YearBridges{}, err
+		}
+
+		bridgeDays := days
+		if !crossed && index == lastIndex {
+			// a bridge starting on the last day of the year types the first day of the next one
+			// with the holidays of the year
+			bridgeDays = typedUntil(lastDate.AddDays(1))
+		}
+		end := bridgeDays.WindowEnd(index, maxAvailability)
+		bridge := rangeBridge(bridgeDays, index, end, explain)
+		if !crossed && end >= lastIndex {
+			// once a bridge reaches the next year, the days left are typed with its holidays
+			crossed = true
+			days = calendar.New(startDate, length, calendarType(nextYearDayType))
+		}
+		// the bridge is inserted only if it is longer than daysOff (es: exlude weekend bridges)
+		if bridge.DaysCount > len(daysOff) {
+			candidates = append(candidates, bridge)
+		}
+		index = days.NextWorking(index)
+	}
+	return p.rank(year, candidates, span), nil
+}
+
+// rank returns the two best scoring groups of the candidate bridges of year,
+// with the best one flagged as top.
+func (p *Planner) rank(year int, candidates []bridges.Bridge, span trace.Span) bridges.YearBridges {
+	var scoreMap = map[int][]bridges.Bridge{}
+	var topBridges, goodBridges int
+	var calculatedBridges []bridges.Bridge
+
+	for _, candidate := range candidates {
+		score := p.scorer(candidate)
+		scoreMap[int(score)] = append(scoreMap[int(score)], candidate)
+	}
+	topBridges = 0
+	goodBridges = 0
+	for k := range scoreMap {
+		if k > topBridges {
+			goodBridges = topBridges
+			topBridges = k
+		} else {
+			if k > goodBridges {
+				goodBridges = k
+			}
+		}
+	}
+
+	for index := range scoreMap[topBridges] {
+		scoreMap[topBridges][index].IsTop = true
+	}
+
+	calculatedBridges = append(scoreMap[topBridges], scoreMap[goodBridges]...)
+	span.SetAttributes(attribute.Int("feriapp.bridges_count", len(calculatedBridges)))
+
+	return bridges.YearBridges{
+		Years:         []string{strconv.Itoa(year)},
+		Bridges:       calculatedBridges,
+		HolidaysCount: 6,
+		WeekdaysCount: 4,
+		DaysCount:     10,
+	}
+}
+
+// windowsByYear computes the bridges of year like bridgesByYear, but considering
+// the windows starting on any day of the year and spending from none to maxAvailability days
+// of leave, before as well as after the holidays. Only the windows that cannot be extended
+// without more leave are candidates: they follow a working day and precede another one.
+func (p *Planner) windowsByYear(ctx context.Context, year int, maxAvailability int, country string, city string, daysOff []int, customHolidays []civil.Date, skipPastBridges bool, explain bool, today civil.Date) (bridges.YearBridges, error) {
+	ctx, span := tracer.Start(ctx, "windowsByYear", trace.WithAttributes(attribute.Int("feriapp.year", year)))
+	defer span.End()
+
+	daysOffMap := make(map[int]bool)
+	for _, dayOff := range daysOff {
+		daysOffMap[dayOff] = true
+	}
+	dayTypes := map[int]func(date civil.Date) string{}
+	for typedYear := year - 1; typedYear <= year+1; typedYear++ {
+		if err := ctx.Err(); err != nil {
+			return bridges.YearBridges{}, err
+		}
+		dayType, err := p.dayTypeFunc(ctx, typedYear, daysOffMap, country, city, customHolidays)
+		if err != nil {
+			return bridges.YearBridges{}, err
+		}
+		dayTypes[typedYear] = dayType
+	}
+
+	// the day before the year tells whether a window starting on its first day is maximal
+	startDate := civil.NewDate(year-1, time.December, 31)
+	days := calendar.New(startDate, civil.NewDate(year+2, time.January, 1).DaysSince(startDate), calendarType(func(date civil.Date) string {
+		return dayTypes[date.Year](date)
+	}))
+	firstIndex := days.Index(civil.NewDate(year, time.January, 1))
+	lastIndex := days.Index(civil.NewDate(year, time.December, 31))
+	if cutoff := days.Index(pastCutoff(today, maxAvailability)); skipPastBridges && cutoff > firstIndex {
+		firstIndex = cutoff
+	}
+
+	var candidates []bridges.Bridge
+	for start := firstIndex; start <= lastIndex; start++ {
+		if err := ctx.Err(); err != nil {
+			return bridges.YearBridges{}, err
+		}
+		// a window following a non working day would be longer starting the day before
+		if days.Off(start - 1) {
+			continue
+		}
+		for leave := 0; leave <= maxAvailability; leave++ {
+			end := days.WindowEnd(start, leave)
+			// the last day of the range ends the windows running out of working days
+			if end == days.Len()-1 {
+				break
+			}
+			if end < start {
+				continue
+			}
+			window := rangeBridge(days, start, end, explain)
+			if window.HolidaysCount > 0 && window.DaysCount > len(daysOff) {
+				candidates = append(candidates, window)
+			}
+		}
+	}
+	return p.rank(year, candidates, span), nil
+}
+
+var dayTypeNames = map[calendar.Type]string{
+	calendar.Holiday: bridges.DayHoliday,
+	calendar.DayOff:  bridges.DayOff,
+	calendar.Working: bridges.DayWorking,
+}
+
+// calendarType adapts a function returned by dayTypeFunc to calendar.New.
+func calendarType(dayType func(date civil.Date) string) func(date civil.Date) calendar.Type {
+	return func(date civil.Date) calendar.Type {
+		switch dayType(date) {
+		case bridges.DayHoliday:
+			return calendar.Holiday
+		case bridges.DayOff:
+			return calendar.DayOff
+		default:
+			return calendar.Working
+		}
+	}
+}
+
+// rangeBridge returns the bridge of the days from start to end included.
+func rangeBridge(days *calendar.Range, start, end int, explain bool) bridges.Bridge {
+	working := days.WorkingCount(start, end)
+	bridge := bridges.Bridge{
+		Start:         days.Date(start),
+		End:           days.Date(end),
+		HolidaysCount: end - start + 1 - working,
+		WeekdaysCount: working,
+		DaysCount:     end - start + 1,
+	}
+	bridge.Id = bridge.Start.String() + "-" + bridge.End.String()
+	if explain {
+		bridge.Days = make([]bridges.Day, 0, bridge.DaysCount)
+		for index := start; index <= end; index++ {
+			bridge.Days = append(bridge.Days, newDay(days.Date(index), dayTypeNames[days.Type(index)]))
+		}
+	}
+	return bridge
+}
+
+// pastCutoff returns the first day whose bridges can still be taken today, having enough time
+// to request maxAvailability days of leave.
+func pastCutoff(today civil.Date, maxAvailability int) civil.Date {
+	return today.AddDays(-maxAvailability)
+}
+
+// DefaultScorer rates a bridge by its length and by the ratio between its length
+// and the leave it costs.
+func DefaultScorer(bridge bridges.Bridge) float32 {
+	if bridge.WeekdaysCount == 0 {
+		return float32(bridge.DaysCount)
+	}
+	return (float32(bridge.DaysCount) / (float32(bridge.WeekdaysCount)) * (float32(bridge.DaysCount) / 30.0) * 100)
+}
+
+// LanguagePackCalendar reads the holidays from the language packs of the helpers package.
+type LanguagePackCalendar struct{}
+
+// Holidays returns the national holidays of country and the patron day of city.
+func (LanguagePackCalendar) Holidays(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return helpers.Holidays(ctx, year, country, city)
+}
@@ -0,0 +1,645 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/leave"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBridgesByYear(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+
+	testCase.Run("bridgesByYear", func(t *testing.T) {
+		bridgesArray := []bridges.Bridge{
+			{Id: "2019-04-20-2019-04-25", IsTop: true, Start: civil.NewDate(2019, 4, 20), End: civil.NewDate(2019, 4, 25), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
+			{Id: "2019-12-21-2019-12-26", IsTop: true, Start: civil.NewDate(2019, 12, 21), End: civil.NewDate(2019, 12, 26), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
+			{Id: "2019-12-25-2019-12-30", IsTop: true, Start: civil.NewDate(2019, 12, 25), End: civil.NewDate(2019, 12, 30), HolidaysCount: 4, WeekdaysCount: 2, DaysCount: 6},
+			{Id: "2019-04-25-2019-04-29", IsTop: false, Start: civil.NewDate(2019, 4, 25), End: civil.NewDate(2019, 4, 29), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+			{Id: "2019-04-27-2019-05-01", IsTop: false, Start: civil.NewDate(2019, 4, 27), End: civil.NewDate(2019, 5, 1), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+			{Id: "2019-05-01-2019-05-05", IsTop: false, Start: civil.NewDate(2019, 5, 1), End: civil.NewDate(2019, 5, 5), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+			{Id: "2019-08-15-2019-08-19", IsTop: false, Start: civil.NewDate(2019, 8, 15), End: civil.NewDate(2019, 8, 19), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+			{Id: "2019-11-01-2019-11-05", IsTop: false, Start: civil.NewDate(2019, 11, 1), End: civil.NewDate(2019, 11, 5), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+			{Id: "2019-12-28-2020-01-01", IsTop: false, Start: civil.NewDate(2019, 12, 28), End: civil.NewDate(2020, 1, 1), HolidaysCount: 3, WeekdaysCount: 2, DaysCount: 5},
+		}
+		YearBridges := bridges.YearBridges{
+			Years:         []string{"2019"},
+			Bridges:       bridgesArray,
+			HolidaysCount: 6,
+			WeekdaysCount: 4,
+			DaysCount:     10,
+		}
+
+		expectedResponse, _ := json.Marshal(YearBridges)
+
+		result, err := New().bridgesByYear(
+			context.Background(),
+			2019,
+			2,
+			"IT",
+			"Milano",
+			[]int{0, 6},
+			nil,
+			false,
+			false,
+			civil.Today(time.Now(), nil),
+		)
+
+		require.Equal(t, nil, err)
+
+		actualResponse, _ := json.Marshal(result)
+		// require.Equal(t, len(bridgesArray), len(result.Bridges), "The 2019 bridges should be %v", len(bridgesArray))
+		require.Equal(t, string(expectedResponse), string(actualResponse), "The 2019 bridges should be correctly calculated")
+	})
+
+	testCase.Run("bridgesByYear - max availability = 0", func(t *testing.T) {
+		bridgesArray := []bridges.Bridge{
+			{Id: "2019-04-20-2019-04-22", IsTop: true, Start: civil.NewDate(2019, 4, 20), End: civil.NewDate(2019, 4, 22), HolidaysCount: 3, WeekdaysCount: 0, DaysCount: 3},
+			{Id: "2019-11-01-2019-11-03", IsTop: true, Start: civil.NewDate(2019, 11, 1), End: civil.NewDate(2019, 11, 3), HolidaysCount: 3, WeekdaysCount: 0, DaysCount: 3},
+		}
+		YearBridges := bridges.YearBridges{
+			Years:         []string{"2019"},
+			Bridges:       bridgesArray,
+			HolidaysCount: 6,
+			WeekdaysCount: 4,
+			DaysCount:     10,
+		}
+
+		expectedResponse, _ := json.Marshal(YearBridges)
+
+		result, err := New().bridgesByYear(
+			context.Background(),
+			2019,
+			0,
+			"IT",
+			"Milano",
+			[]int{0, 6},
+			nil,
+			false,
+			false,
+			civil.Today(time.Now(), nil),
+		)
+		require.Equal(t, nil, err)
+
+		actualResponse, _ := json.Marshal(result)
+		require.Equal(t, string(expectedResponse), string(actualResponse), "The 2019 bridges should be 2")
+	})
+
+	testCase.Run("bridgesByYear - local city holiday - milano", func(t *testing.T) {
+		expectedBridge := bridges.Bridge{
+			Start:         civil.NewDate(2020, 12, 5),
+			End:           civil.NewDate(2020, 12, 8),
+			HolidaysCount: 3,
+			WeekdaysCount: 0,
+			DaysCount:     3,
+		}
+
+		result, err := New().bridgesByYear(
+			context.Background(),
+			2020,
+			0,
+			"IT",
+			"Milano",
+			[]int{0, 6},
+			nil,
+			false,
+			false,
+			civil.Today(time.Now(), nil),
+		)
+		require.Equal(t, nil, err)
+		var foundBridge = false
+
+		for _, bridge := range result.Bridges {
+			if bridge.Start == expectedBridge.Start && bridge.End == expectedBridge.End {
+				foundBridge = true
+			}
+		}
+
+		require.Equal(t, true, foundBridge, "In 2020 bridges there should be san Ambrogio bridge")
+	})
+}
+
+func fixedClock(year int, month time.Month, day int) Clock {
+	return ClockFunc(func() time.Time {
+		return time.Date(year, month, day, 10, 0, 0, 0, time.UTC)
+	})
+}
+
+func TestPlan(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+
+	testCase.Run("defaults and observer", func(t *testing.T) {
+		observedYears := []int{}
+		p := New(
+			WithClock(fixedClock(2019, 1, 1)),
+			WithObserver(func(year int, elapsed time.Duration) {
+				observedYears = append(observedYears, year)
+			}),
+		)
+
+		result, err := p.Plan(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2})
+		require.NoError(t, err)
+		require.Len(t, result.Years, DefaultYears)
+		require.Equal(t, []int{2019, 2020, 2021}, observedYears)
+		require.Equal(t, []string{"2019"}, result.Years[0].Years)
+	})
+
+	testCase.Run("past bridges are skipped unless requested", func(t *testing.T) {
+		p := New(WithClock(fixedClock(2019, 7, 1)))
+
+		upcoming, err := p.Plan(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1})
+		require.NoError(t, err)
+		for _, bridge := range upcoming.Years[0].Bridges {
+			require.True(t, bridge.Start.After(civil.NewDate(2019, 7, 3)), bridge.Id)
+		}
+
+		all, err := p.Plan(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1, IncludePast: true})
+		require.NoError(t, err)
+		require.Equal(t, "2019-04-20-2019-04-25", all.Years[0].Bridges[0].Id)
+	})
+
+	testCase.Run("today in the time zone of the user", func(t *testing.T) {
+		// New Year's Eve in UTC is already the New Year in Rome
+		p := New(WithClock(ClockFunc(func() time.Time {
+			return time.Date(2019, 12, 31, 23, 30, 0, 0, time.UTC)
+		})))
+		rome, err := time.LoadLocation("Europe/Rome")
+		require.NoError(t, err)
+
+		utc, err := p.Plan(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1})
+		require.NoError(t, err)
+		require.Equal(t, []string{"2019"}, utc.Years[0].Years)
+
+		local, err := p.Plan(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1, Location: rome})
+		require.NoError(t, err)
+		require.Equal(t, []string{"2020"}, local.Years[0].Years)
+	})
+
+	testCase.Run("unknown country", func(t *testing.T) {
+		p := New(WithClock(fixedClock(2021, 1, 1)))
+		_, err := p.Plan(context.Background(), Options{Country: "XX", DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1})
+		require.EqualError(t, err, `invalid country "XX": must be one of GB, IT, US`)
+		_, err = p.LeaveCost(context.Background(), Options{Country: "it"}, civil.NewDate(2021, 3, 1), civil.NewDate(2021, 3, 5))
+		require.EqualError(t, err, `invalid country "it": must be one of GB, IT, US`)
+	})
+
+	testCase.Run("custom calendar and scorer", func(t *testing.T) {
+		calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+			require.Equal(t, "XX", country)
+			return []civil.Date{civil.NewDate(year, 3, 4)}, nil
+		})
+		p := New(
+			WithClock(fixedClock(2021, 1, 1)),
+			WithCalendar(calendar),
+			WithScorer(func(bridge bridges.Bridge) float32 { return float32(bridge.DaysCount) }),
+		)
+
+		result, err := p.Plan(context.Background(), Options{Country: "XX", DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1, IncludePast: true})
+		require.NoError(t, err)
+		// 2021-03-04 is a Thursday: taking the Friday gives a four days bridge
+		require.Equal(t, "2021-03-04-2021-03-07", result.Years[0].Bridges[0].Id)
+		require.True(t, result.Years[0].Bridges[0].IsTop)
+	})
+
+	testCase.Run("custom holidays", func(t *testing.T) {
+		calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+			return []civil.Date{civil.NewDate(year, 3, 4)}, nil
+		})
+		p := New(WithClock(fixedClock(2021, 1, 1)), WithCalendar(calendar))
+
+		result, err := p.Plan(context.Background(), Options{
+			DaysOff:        []int{0, 6},
+			Years:          1,
+			CustomHolidays: []civil.Date{civil.NewDate(2021, 3, 5)},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "2021-03-04-2021-03-07", result.Years[0].Bridges[0].Id)
+		require.Equal(t, 0, result.Years[0].Bridges[0].WeekdaysCount)
+	})
+
+	testCase.Run("until and budget", func(t *testing.T) {
+		calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+			return []civil.Date{civil.NewDate(year, 3, 4), civil.NewDate(year, 9, 2)}, nil
+		})
+		p := New(WithClock(fixedClock(2021, 1, 1)), WithCalendar(calendar))
+		ids := func(result Result) []string {
+			ids := []string{}
+			for _, bridge := range result.Years[0].Bridges {
+				ids = append(ids, bridge.Id)
+			}
+			return ids
+		}
+
+		all, err := p.Plan(context.Background(), Options{DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1})
+		require.NoError(t, err)
+		require.Contains(t, ids(all), "2021-09-02-2021-09-05")
+
+		untilSummer, err := p.Plan(context.Background(), Options{DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1, Until: civil.NewDate(2021, 6, 30)})
+		require.NoError(t, err)
+		require.NotEmpty(t, ids(untilSummer))
+		require.NotContains(t, ids(untilSummer), "2021-09-02-2021-09-05")
+
+		affordable, err := p.Plan(context.Background(), Options{DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1, Budget: budgetFunc(func(date civil.Date, days int) bool {
+			return days == 0 || date.Month > time.June
+		})})
+		require.NoError(t, err)
+		for _, bridge := range affordable.Years[0].Bridges {
+			require.True(t, bridge.WeekdaysCount == 0 || bridge.Start.Month > time.June, bridge.Id)
+		}
+		require.Contains(t, ids(affordable), "2021-09-02-2021-09-05")
+	})
+
+	testCase.Run("calendar errors are returned", func(t *testing.T) {
+		calendarError := errors.New("calendar unavailable")
+		p := New(WithCalendar(CalendarFunc(func(context.Context, int, string, string) ([]civil.Date, error) {
+			return nil, calendarError
+		})))
+
+		_, err := p.Plan(context.Background(), Options{})
+		require.Equal(t, calendarError, err)
+	})
+
+	testCase.Run("invalid options", func(t *testing.T) {
+		_, err := Plan(context.Background(), Options{LeaveDays: -1})
+		require.Error(t, err)
+
+		_, err = Plan(context.Background(), Options{DaysOff: []int{7}})
+		require.Error(t, err)
+	})
+}
+
+// leave accounts tell the bridges that can be afforded on their start day
+var _ Budget = leave.Account{}
+
+type budgetFunc func(date civil.Date, days int) bool
+
+func (f budgetFunc) CanAfford(date civil.Date, days int) bool {
+	return f(date, days)
+}
+
+func TestLeaveCost(testCase *testing.T) {
+	calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		return []civil.Date{civil.NewDate(year, 1, 1), civil.NewDate(year, 12, 25)}, nil
+	})
+	p := New(WithCalendar(calendar))
+
+	testCase.Run("skips holidays and days off across the year end", func(t *testing.T) {
+		// from Thursday 2020-12-24 to Monday 2021-01-04: 24, 28, 29, 30, 31 and 4 are worked
+		cost, err := p.LeaveCost(context.Background(), Options{DaysOff: []int{0, 6}},
+			civil.NewDate(2020, 12, 24), civil.NewDate(2021, 1, 4))
+		require.NoError(t, err)
+		require.Equal(t, 6, cost)
+	})
+
+	testCase.Run("custom holidays", func(t *testing.T) {
+		cost, err := p.LeaveCost(context.Background(), Options{
+			DaysOff:        []int{0, 6},
+			CustomHolidays: []civil.Date{civil.NewDate(2020, 12, 24)},
+		}, civil.NewDate(2020, 12, 24), civil.NewDate(2020, 12, 27))
+		require.NoError(t, err)
+		require.Equal(t, 0, cost)
+	})
+
+	testCase.Run("end before start", func(t *testing.T) {
+		_, err := p.LeaveCost(context.Background(), Options{},
+			civil.NewDate(2021, 1, 4), civil.NewDate(2021, 1, 3))
+		require.Error(t, err)
+	})
+}
+
+func TestCompensations(testCase *testing.T) {
+	calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		return []civil.Date{civil.NewDate(year, 1, 1), civil.NewDate(year, 12, 25)}, nil
+	})
+	p := New(WithCalendar(calendar))
+
+	testCase.Run("holidays on the compensated weekdays", func(t *testing.T) {
+		// 2022-01-01 is a Saturday and 2022-12-25 a Sunday
+		dates, err := p.Compensations(context.Background(), Options{DaysOff: []int{0, 6}}, []int{0},
+			civil.NewDate(2022, 1, 1), civil.NewDate(2022, 12, 31))
+		require.NoError(t, err)
+		require.Equal(t, []civil.Date{civil.NewDate(2022, 12, 25)}, dates)
+
+		dates, err = p.Compensations(context.Background(), Options{DaysOff: []int{0, 6}}, []int{0, 6},
+			civil.NewDate(2022, 1, 1), civil.NewDate(2022, 12, 31))
+		require.NoError(t, err)
+		require.Equal(t, []civil.Date{civil.NewDate(2022, 1, 1), civil.NewDate(2022, 12, 25)}, dates)
+	})
+
+	testCase.Run("easter is not compensated", func(t *testing.T) {
+		os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+		// Easter falls on the 21st of April
+		dates, err := New().Compensations(context.Background(), Options{DaysOff: []int{0, 6}}, []int{0},
+			civil.NewDate(2030, 1, 1), civil.NewDate(2030, 12, 31))
+		require.NoError(t, err)
+		require.Equal(t, []civil.Date{civil.NewDate(2030, 1, 6), civil.NewDate(2030, 6, 2), civil.NewDate(2030, 12, 8)}, dates)
+	})
+
+	testCase.Run("custom holidays are not compensated", func(t *testing.T) {
+		dates, err := p.Compensations(context.Background(), Options{
+			DaysOff:        []int{0, 6},
+			CustomHolidays: []civil.Date{civil.NewDate(2022, 8, 14)},
+		}, []int{0}, civil.NewDate(2022, 8, 1), civil.NewDate(2022, 8, 31))
+		require.NoError(t, err)
+		require.Empty(t, dates)
+	})
+}
+
+func TestExplain(testCase *testing.T) {
+	calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		return []civil.Date{civil.NewDate(year, 1, 1), civil.NewDate(year, 12, 25)}, nil
+	})
+	p := New(WithCalendar(calendar), WithClock(fixedClock(2020, 1, 1)))
+
+	testCase.Run("days of a range", func(t *testing.T) {
+		// from Thursday 2020-12-24 to Sunday 2020-12-27
+		days, err := p.Explain(context.Background(), Options{DaysOff: []int{0, 6}},
+			civil.NewDate(2020, 12, 24), civil.NewDate(2020, 12, 27))
+		require.NoError(t, err)
+		require.Equal(t, []bridges.Day{
+			{Date: civil.NewDate(2020, 12, 24), Type: bridges.DayWorking, Leave: true},
+			{Date: civil.NewDate(2020, 12, 25), Type: bridges.DayHoliday},
+			{Date: civil.NewDate(2020, 12, 26), Type: bridges.DayOff},
+			{Date: civil.NewDate(2020, 12, 27), Type: bridges.DayOff},
+		}, days)
+	})
+
+	testCase.Run("explained bridges match their counts", func(t *testing.T) {
+		result, err := p.Plan(context.Background(), Options{DaysOff: []int{0, 6}, LeaveDays: 2, Years: 2, Explain: true})
+		require.NoError(t, err)
+		unexplained, err := p.Plan(context.Background(), Options{DaysOff: []int{0, 6}, LeaveDays: 2, Years: 2})
+		require.NoError(t, err)
+
+		for yearIndex, yearBridges := range result.Years {
+			require.Len(t, yearBridges.Bridges, len(unexplained.Years[yearIndex].Bridges))
+			for index, bridge := range yearBridges.Bridges {
+				require.Len(t, bridge.Days, bridge.DaysCount, bridge.Id)
+				leave := 0
+				for _, day := range bridge.Days {
+					if day.Leave {
+						leave++
+					}
+				}
+				require.Equal(t, bridge.WeekdaysCount, leave, bridge.Id)
+				require.Equal(t, bridge.Start, bridge.Days[0].Date, bridge.Id)
+				require.Equal(t, bridge.End, bridge.Days[len(bridge.Days)-1].Date, bridge.Id)
+
+				bridge.Days = nil
+				require.Equal(t, unexplained.Years[yearIndex].Bridges[index], bridge)
+			}
+		}
+	})
+}
+
+func TestResolveOverlaps(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	p := New(WithClock(fixedClock(2019, 1, 1)))
+	options := Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1}
+
+	testCase.Run("computed bridges are left untouched", func(t *testing.T) {
+		result, err := p.Plan(context.Background(), options)
+		require.NoError(t, err)
+		require.Len(t, result.Years[0].Bridges, 9)
+		for _, bridge := range result.Years[0].Bridges {
+			require.Empty(t, bridge.Cluster)
+		}
+	})
+
+	testCase.Run("list", func(t *testing.T) {
+		options := options
+		options.Overlap = OverlapList
+		result, err := p.Plan(context.Background(), options)
+		require.NoError(t, err)
+		clusters := map[string]string{}
+		for _, bridge := range result.Years[0].Bridges {
+			clusters[bridge.Id] = bridge.Cluster
+		}
+		require.Equal(t, map[string]string{
+			"2019-04-20-2019-04-25": "2019-04-20-2019-05-05",
+			"2019-04-25-2019-04-29": "2019-04-20-2019-05-05",
+			"2019-04-27-2019-05-01": "2019-04-20-2019-05-05",
+			"2019-05-01-2019-05-05": "2019-04-20-2019-05-05",
+			"2019-08-15-2019-08-19": "2019-08-15-2019-08-19",
+			"2019-11-01-2019-11-05": "2019-11-01-2019-11-05",
+			"2019-12-21-2019-12-26": "2019-12-21-2020-01-01",
+			"2019-12-25-2019-12-30": "2019-12-21-2020-01-01",
+			"2019-12-28-2020-01-01": "2019-12-21-2020-01-01",
+		}, clusters)
+	})
+
+	testCase.Run("collapse", func(t *testing.T) {
+		options := options
+		options.Overlap = OverlapCollapse
+		result, err := p.Plan(context.Background(), options)
+		require.NoError(t, err)
+		alternatives := map[string][]string{}
+		for _, bridge := range result.Years[0].Bridges {
+			alternatives[bridge.Id] = []string{}
+			for _, alternative := range bridge.Alternatives {
+				alternatives[bridge.Id] = append(alternatives[bridge.Id], alternative.Id)
+			}
+		}
+		require.Equal(t, map[string][]string{
+			"2019-04-20-2019-04-25": {"2019-04-25-2019-04-29", "2019-04-27-2019-05-01", "2019-05-01-2019-05-05"},
+			"2019-08-15-2019-08-19": {},
+			"2019-11-01-2019-11-05": {},
+			"2019-12-21-2019-12-26": {"2019-12-25-2019-12-30", "2019-12-28-2020-01-01"},
+		}, alternatives)
+	})
+
+	testCase.Run("invalid mode", func(t *testing.T) {
+		options := options
+		options.Overlap = "merge"
+		_, err := p.Plan(context.Background(), options)
+		require.Error(t, err)
+	})
+}
+
+func TestWindowSearch(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	bestScore := func(result Result) float32 {
+		var best float32
+		for _, bridge := range result.Years[0].Bridges {
+			if score := DefaultScorer(bridge); score > best {
+				best = score
+			}
+		}
+		return best
+	}
+	plan := func(t *testing.T, year int, options Options) Result {
+		result, err := New(WithClock(fixedClock(year, 1, 1))).Plan(context.Background(), options)
+		require.NoError(t, err)
+		return result
+	}
+
+	testCase.Run("leave taken before the holidays", func(t *testing.T) {
+		// Friday 2020-12-04 before the weekend, Sant'Ambrogio and the Immaculate Conception
+		result := plan(t, 2020, Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 1, Years: 1, Search: SearchWindow})
+		ids := []string{}
+		for _, bridge := range result.Years[0].Bridges {
+			ids = append(ids, bridge.Id)
+		}
+		require.Contains(t, ids, "2020-12-04-2020-12-08")
+	})
+
+	testCase.Run("never worse than the holidays search", func(t *testing.T) {
+		for leaveDays := 0; leaveDays <= 3; leaveDays++ {
+			for year := 2019; year <= 2030; year++ {
+				options := Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: leaveDays, Years: 1, IncludePast: true}
+				legacy := plan(t, year, options)
+				options.Search = SearchWindow
+				window := plan(t, year, options)
+				require.GreaterOrEqual(t, bestScore(window), bestScore(legacy), "%d days of leave in %d", leaveDays, year)
+			}
+		}
+	})
+
+	testCase.Run("strictly better plans on known years", func(t *testing.T) {
+		// with two days of leave, a single one around Christmas or the Immaculate Conception
+		// is better than the two spent after Easter or the New Year
+		for year := 2019; year <= 2025; year++ {
+			options := Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1, IncludePast: true}
+			legacy := plan(t, year, options)
+			options.Search = SearchWindow
+			window := plan(t, year, options)
+			require.Greater(t, bestScore(window), bestScore(legacy), year)
+		}
+	})
+
+	testCase.Run("windows are maximal", func(t *testing.T) {
+		result := plan(t, 2019, Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 1, Search: SearchWindow, IncludePast: true, Explain: true})
+		require.NotEmpty(t, result.Years[0].Bridges)
+		for _, bridge := range result.Years[0].Bridges {
+			require.LessOrEqual(t, bridge.WeekdaysCount, 2, bridge.Id)
+			require.Len(t, bridge.Days, bridge.DaysCount, bridge.Id)
+			before, err := New().Explain(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}}, bridge.Start.AddDate(0, 0, -1), bridge.Start.AddDate(0, 0, -1))
+			require.NoError(t, err)
+			after, err := New().Explain(context.Background(), Options{City: "Milano", DaysOff: []int{0, 6}}, bridge.End.AddDate(0, 0, 1), bridge.End.AddDate(0, 0, 1))
+			require.NoError(t, err)
+			require.True(t, before[0].Leave, bridge.Id)
+			require.True(t, after[0].Leave, bridge.Id)
+		}
+	})
+
+	testCase.Run("invalid search", func(t *testing.T) {
+		_, err := New().Plan(context.Background(), Options{Search: "backward"})
+		require.Error(t, err)
+	})
+}
+
+func TestStream(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	options := Options{City: "Milano", DaysOff: []int{0, 6}, LeaveDays: 2, Years: 3}
+
+	testCase.Run("emits every year in order", func(t *testing.T) {
+		years := []string{}
+		err := New(WithClock(fixedClock(2019, 1, 1))).Stream(context.Background(), options, func(yearBridges bridges.YearBridges) error {
+			years = append(years, yearBridges.Years...)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"2019", "2020", "2021"}, years)
+	})
+
+	testCase.Run("stops on emit errors", func(t *testing.T) {
+		emitError := errors.New("client gone")
+		calls := 0
+		err := New().Stream(context.Background(), options, func(bridges.YearBridges) error {
+			calls++
+			return emitError
+		})
+		require.Equal(t, emitError, err)
+		require.Equal(t, 1, calls)
+	})
+
+	testCase.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := New().Stream(ctx, options, func(bridges.YearBridges) error {
+			calls++
+			cancel()
+			return nil
+		})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestPlanDeadline(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	calls := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	// the context is cancelled while the first year is being computed
+	calendar := CalendarFunc(func(ctx context.Context, year int, country string, city string) ([]civil.Date, error) {
+		calls++
+		cancel()
+		return []civil.Date{}, nil
+	})
+
+	_, err := New(WithCalendar(calendar)).Plan(ctx, Options{Years: 50})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, calls, "The computation should stop as soon as the context is done")
+}
+
+func TestLanguagePackCalendar(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "../helpers/")
+	testCase.Run("wrong locale", func(t *testing.T) {
+		actualHolidays, err := LanguagePackCalendar{}.Holidays(context.Background(), 2019, "wrong_locale", "Milano")
+		require.NoError(t, err)
+
+		expectedHolidays := []civil.Date{}
+		require.Equal(t, expectedHolidays, actualHolidays, "Should return empty list")
+	})
+
+	testCase.Run("wrong city", func(t *testing.T) {
+		year := 2019
+		actualHolidays, err := LanguagePackCalendar{}.Holidays(context.Background(), year, "IT", "wrong_city")
+		require.NoError(t, err)
+
+		expectedHolidays := []civil.Date{
+			civil.NewDate(year, 4, 21),
+			civil.NewDate(year, 4, 22),
+			civil.NewDate(year, 1, 1),
+			civil.NewDate(year, 1, 6),
+			civil.NewDate(year, 4, 25),
+			civil.NewDate(year, 5, 1),
+			civil.NewDate(year, 6, 2),
+			civil.NewDate(year, 8, 15),
+			civil.NewDate(year, 11, 1),
+			civil.NewDate(year, 12, 8),
+			civil.NewDate(year, 12, 25),
+			civil.NewDate(year, 12, 26),
+		}
+		require.Equal(t, expectedHolidays, actualHolidays, "Should return correct list except local city holiday")
+	})
+
+	testCase.Run("correct city - Milano", func(t *testing.T) {
+		year := 2019
+		actualHolidays, err := LanguagePackCalendar{}.Holidays(context.Background(), year, "IT", "Milano")
+		require.NoError(t, err)
+
+		expectedHolidays := []civil.Date{
+			civil.NewDate(year, 4, 21),
+			civil.NewDate(year, 4, 22),
+			civil.NewDate(year, 1, 1),
+			civil.NewDate(year, 1, 6),
+			civil.NewDate(year, 4, 25),
+			civil.NewDate(year, 5, 1),
+			civil.NewDate(year, 6, 2),
+			civil.NewDate(year, 8, 15),
+			civil.NewDate(year, 11, 1),
+			civil.NewDate(year, 12, 8),
+			civil.NewDate(year, 12, 25),
+			civil.NewDate(year, 12, 26),
+			civil.NewDate(year, 12, 7),
+		}
+		require.Equal(t, expectedHolidays, actualHolidays, "Should return correct list except local city holiday")
+	})
+}
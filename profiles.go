@@ -0,0 +1,323 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/leave"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/gorilla/mux"
+	"github.com/mia-platform/glogger"
+)
+
+const (
+	storageBackendMemory = "memory"
+	storageBackendFile   = "file"
+)
+
+var (
+	errProfileNotFound       = errors.New("profile not found")
+	errAuthenticationNeeded  = errors.New("authentication required")
+	errProfileNameIsRequired = errors.New("profile name is required")
+)
+
+// newStore creates the storage backend selected by the environment.
+func newStore(env EnvironmentVariables) (storage.Store, error) {
+	switch env.StorageBackend {
+	case "", storageBackendMemory:
+		return storage.NewMemory(), nil
+	case storageBackendFile:
+		return storage.NewFile(env.StorageFilePath)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", env.StorageBackend)
+	}
+}
+
+func setupProfilesRouter(router *mux.Router, store storage.Store) {
+	router.HandleFunc("/profiles", createProfile(store)).Methods(http.MethodPost)
+	router.HandleFunc("/profiles", listProfiles(store)).Methods(http.MethodGet)
+	router.HandleFunc("/profiles/{id}", getProfile(store)).Methods(http.MethodGet)
+	router.HandleFunc("/profiles/{id}", updateProfile(store)).Methods(http.MethodPut)
+	router.HandleFunc("/profiles/{id}", deleteProfile(store)).Methods(http.MethodDelete)
+}
+
+// profileOwner returns the subject owning the profiles created by the request.
+// Anonymous profiles have no owner and are reachable by anyone knowing their id.
+func profileOwner(ctx context.Context) string {
+	identity, _ := identityFromContext(ctx)
+	return identity.Subject
+}
+
+// findProfile returns the profile only when it is owned by the caller of the request.
+func findProfile(ctx context.Context, store storage.Store, id string) (storage.Profile, error) {
+	profile, err := store.GetProfile(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && profile.Owner != profileOwner(ctx)) {
+		return storage.Profile{}, errProfileNotFound
+	}
+	return profile, err
+}
+
+func writeProfileError(w http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, errProfileNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		glogger.Get(req.Context()).WithError(err).Error("failed profile storage")
+		http.Error(w, errGeneric.Error(), http.StatusInternalServerError)
+	}
+}
+
+// decodeProfile reads the editable fields of a profile from the request body.
+func decodeProfile(req *http.Request) (storage.Profile, error) {
+	var body struct {
+		Name            string         `json:"name"`
+		AnnualLeaveDays int            `json:"annualLeaveDays"`
+		OrganizationId  string         `json:"organizationId"`
+		Leave           *leave.Account `json:"leave"`
+		CompensatedDays []int          `json:"compensatedDays"`
+		bridges.BridgesRequest
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return storage.Profile{}, err
+	}
+	if body.Name == "" {
+		return storage.Profile{}, errProfileNameIsRequired
+	}
+	if body.AnnualLeaveDays < 0 {
+		return storage.Profile{}, fmt.Errorf("invalid annualLeaveDays value %d: must be a non negative integer", body.AnnualLeaveDays)
+	}
+	if body.Leave != nil {
+		if err := validateLeaveAccount(*body.Leave); err != nil {
+			return storage.Profile{}, err
+		}
+		// the leave used is the one of the bookings
+		body.Leave.Usages = nil
+	}
+	for _, weekday := range body.CompensatedDays {
+		if weekday < 0 || weekday > 6 {
+			return storage.Profile{}, fmt.Errorf("invalid compensatedDays value %d: must be a weekday between 0 and 6", weekday)
+		}
+	}
+	if err := validateBridgesRequest(body.BridgesRequest); err != nil {
+		return storage.Profile{}, err
+	}
+	normalizeBridgesRequest(&body.BridgesRequest)
+	if err := resolveCity(&body.BridgesRequest); err != nil {
+		return storage.Profile{}, err
+	}
+	return storage.Profile{
+		Name:            body.Name,
+		AnnualLeaveDays: body.AnnualLeaveDays,
+		OrganizationId:  body.OrganizationId,
+		Leave:           body.Leave,
+		CompensatedDays: body.CompensatedDays,
+		BridgesRequest:  body.BridgesRequest,
+	}, nil
+}
+
+func validateLeaveAccount(account leave.Account) error {
+	if err := account.Policy.Validate(); err != nil {
+		return fmt.Errorf("invalid leave policy: %w", err)
+	}
+	if account.Start.IsZero() {
+		return errors.New("invalid leave account: start is required")
+	}
+	for _, lot := range account.Opening {
+		if lot.Hours < 0 {
+			return fmt.Errorf("invalid leave account: opening hours of %s must not be negative", lot.Name)
+		}
+	}
+	for _, grant := range account.Grants {
+		if grant.Hours < 0 {
+			return fmt.Errorf("invalid leave account: granted hours of %s must not be negative", grant.Name)
+		}
+	}
+	return nil
+}
+
+// validateBridgesRequest checks the settings the planner would otherwise reject
+// only when computing the bridges.
+func validateBridgesRequest(reqBody bridges.BridgesRequest) error {
+	if reqBody.DayOfHolidays < 0 {
+		return fmt.Errorf("invalid dayOfHolidays value %d: must be a non negative integer", reqBody.DayOfHolidays)
+	}
+	if reqBody.YearsScope < 0 {
+		return fmt.Errorf("invalid yearsScope value %d: must be a non negative integer", reqBody.YearsScope)
+	}
+	if err := checkYearsScope(reqBody.YearsScope); err != nil {
+		return err
+	}
+	for _, dayOff := range reqBody.DaysOff {
+		if dayOff < 0 || dayOff > 6 {
+			return fmt.Errorf("invalid daysOff value %d: must be a weekday between 0 and 6", dayOff)
+		}
+	}
+	now, err := requestNow(reqBody)
+	if err != nil {
+		return err
+	}
+	_, err = customHolidayDates(context.Background(), reqBody.CustomHolidays, now.Year(), 0)
+	return err
+}
+
+// checkOrganization fails with a 400 when the profile is linked to an unknown organization.
+func checkOrganization(w http.ResponseWriter, req *http.Request, store storage.Store, profile storage.Profile) bool {
+	if profile.OrganizationId == "" {
+		return true
+	}
+	_, err := store.GetOrganization(req.Context(), profile.OrganizationId)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("organization %s not found", profile.OrganizationId), http.StatusBadRequest)
+		return false
+	}
+	if err != nil {
+		writeProfileError(w, req, err)
+		return false
+	}
+	return true
+}
+
+func createProfile(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := decodeProfile(req)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if !checkOrganization(w, req, store, profile) {
+			return
+		}
+		profile.Owner = profileOwner(req.Context())
+
+		created, err := store.CreateProfile(req.Context(), profile)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		w.Header().Set("Location", path.Join(path.Dir(req.URL.Path), "profiles", created.Id))
+		writeResponse(glogger.Get(req.Context()), w, http.StatusCreated, created)
+	}
+}
+
+// listProfiles returns the profiles of the caller, which must be authenticated
+// since anonymous profiles are shared by every anonymous caller.
+func listProfiles(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner := profileOwner(req.Context())
+		if owner == "" {
+			http.Error(w, errAuthenticationNeeded.Error(), http.StatusUnauthorized)
+			return
+		}
+		profiles, err := store.ListProfiles(req.Context(), owner)
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, profiles)
+	}
+}
+
+func getProfile(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, profile)
+	}
+}
+
+func updateProfile(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := decodeProfile(req)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		existing, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		if !checkOrganization(w, req, store, profile) {
+			return
+		}
+		profile.Id = existing.Id
+
+		updated, err := store.UpdateProfile(req.Context(), profile)
+		if errors.Is(err, storage.ErrNotFound) {
+			err = errProfileNotFound
+		}
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		writeResponse(glogger.Get(req.Context()), w, http.StatusOK, updated)
+	}
+}
+
+func deleteProfile(store storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile, err := findProfile(req.Context(), store, mux.Vars(req)["id"])
+		if err == nil {
+			err = store.DeleteProfile(req.Context(), profile.Id)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			err = errProfileNotFound
+		}
+		if err != nil {
+			writeProfileError(w, req, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// applyProfile fills the bridges request with the settings of the profile,
+// except for those given explicitly in the query string.
+func applyProfile(reqBody *bridges.BridgesRequest, profile storage.Profile, query url.Values) {
+	if _, ok := query["city"]; !ok {
+		reqBody.City = profile.City
+	}
+	if _, ok := query["dayOfHolidays"]; !ok {
+		reqBody.DayOfHolidays = profile.DayOfHolidays
+	}
+	if _, ok := query["yearsScope"]; !ok {
+		reqBody.YearsScope = profile.YearsScope
+	}
+	if _, ok := query["daysOff"]; !ok {
+		reqBody.DaysOff = append([]int{}, profile.DaysOff...)
+	}
+	if _, ok := query["customHolidays"]; !ok {
+		reqBody.CustomHolidays = append([]bridges.CustomHolidays{}, profile.CustomHolidays...)
+	}
+	if _, ok := query["timeZone"]; !ok {
+		reqBody.TimeZone = profile.TimeZone
+	}
+	if _, ok := query["country"]; !ok {
+		reqBody.Country = profile.Country
+	}
+}
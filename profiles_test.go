@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStore(testCase *testing.T) {
+	testCase.Run("memory by default", func(t *testing.T) {
+		store, err := newStore(EnvironmentVariables{})
+		require.NoError(t, err)
+		require.IsType(t, &storage.Memory{}, store)
+	})
+
+	testCase.Run("unknown backend", func(t *testing.T) {
+		_, err := newStore(EnvironmentVariables{StorageBackend: "mongo"})
+		require.Error(t, err)
+	})
+}
+
+func TestProfilesRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	store := storage.NewMemory()
+	testRouter := mux.NewRouter()
+	// the test subject header stands in for the authentication middleware
+	testRouter.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if subject := req.Header.Get("X-Test-Subject"); subject != "" {
+				req = req.WithContext(contextWithIdentity(req.Context(), Identity{Subject: subject, Method: authMethodAPIKey}))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	setupBridgesRouter(testRouter, cache.New(16), store, testMessages, testEnv)
+	setupProfilesRouter(testRouter, store)
+
+	serve := func(method, target, subject string, body interface{}) *httptest.ResponseRecorder {
+		var requestBody bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&requestBody).Encode(body)
+		}
+		request := httptest.NewRequest(method, target, &requestBody)
+		if subject != "" {
+			request.Header.Set("X-Test-Subject", subject)
+		}
+		responseRecorder := httptest.NewRecorder()
+		testRouter.ServeHTTP(responseRecorder, request)
+		return responseRecorder
+	}
+	decode := func(t *testing.T, responseRecorder *httptest.ResponseRecorder) storage.Profile {
+		var profile storage.Profile
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &profile))
+		return profile
+	}
+
+	var created storage.Profile
+	testCase.Run("create", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPost, "/profiles", "user-1", map[string]interface{}{
+			"name":           "work",
+			"city":           " Milano ",
+			"daysOff":        []int{6, 0},
+			"dayOfHolidays":  2,
+			"customHolidays": []bridges.CustomHolidays{{Date: "08-16", Name: "San Rocco"}},
+		})
+		require.Equal(t, http.StatusCreated, responseRecorder.Code, responseRecorder.Body.String())
+
+		created = decode(t, responseRecorder)
+		require.Equal(t, "/profiles/"+created.Id, responseRecorder.Header().Get("Location"))
+		require.Equal(t, "user-1", created.Owner)
+		require.Equal(t, "Milano", created.City)
+		require.Equal(t, []int{0, 6}, created.DaysOff)
+		require.Equal(t, defaultYearsScope, created.YearsScope)
+	})
+
+	testCase.Run("create - invalid", func(t *testing.T) {
+		invalidBodies := []map[string]interface{}{
+			{"city": "Milano"},
+			{"name": "work", "daysOff": []int{7}},
+			{"name": "work", "dayOfHolidays": -1},
+			{"name": "work", "yearsScope": 51},
+			{"name": "work", "customHolidays": []bridges.CustomHolidays{{Date: "16/08"}}},
+			{"name": "work", "city": "Milna"},
+		}
+		for _, body := range invalidBodies {
+			responseRecorder := serve(http.MethodPost, "/profiles", "user-1", body)
+			require.Equal(t, http.StatusBadRequest, responseRecorder.Code, body)
+		}
+	})
+
+	testCase.Run("get", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/profiles/"+created.Id, "user-1", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, "work", decode(t, responseRecorder).Name)
+	})
+
+	testCase.Run("profiles of others are not found", func(t *testing.T) {
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/profiles/"+created.Id, "user-2", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/profiles/"+created.Id, "", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodPut, "/profiles/"+created.Id, "user-2", map[string]string{"name": "stolen"}).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/profiles/"+created.Id, "user-2", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/bridges?profile="+created.Id, "user-2", nil).Code)
+	})
+
+	testCase.Run("list", func(t *testing.T) {
+		responseRecorder := serve(http.MethodGet, "/profiles", "user-1", nil)
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		var profiles []storage.Profile
+		require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &profiles))
+		require.Len(t, profiles, 1)
+
+		require.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/profiles", "", nil).Code)
+	})
+
+	testCase.Run("bridges from the profile", func(t *testing.T) {
+		fromProfile := serve(http.MethodGet, "/bridges?profile="+created.Id, "user-1", nil)
+		require.Equal(t, http.StatusOK, fromProfile.Code, fromProfile.Body.String())
+		require.True(t, strings.HasPrefix(fromProfile.Header().Get("Cache-Control"), "private"))
+
+		// the custom holiday is named in the labels, so it is sent in the body
+		explicit := serve(http.MethodPost, "/bridges", "", bridges.BridgesRequest{
+			City:           "Milano",
+			DaysOff:        []int{0, 6},
+			DayOfHolidays:  2,
+			CustomHolidays: []bridges.CustomHolidays{{Date: "08-16", Name: "San Rocco"}},
+		})
+		require.Equal(t, http.StatusOK, explicit.Code)
+		require.JSONEq(t, explicit.Body.String(), fromProfile.Body.String())
+
+		overridden := serve(http.MethodGet, "/bridges?profile="+created.Id+"&yearsScope=1", "user-1", nil)
+		require.Equal(t, http.StatusOK, overridden.Code)
+		var years []bridges.YearBridges
+		require.NoError(t, json.Unmarshal(overridden.Body.Bytes(), &years))
+		require.Len(t, years, 1)
+	})
+
+	testCase.Run("update", func(t *testing.T) {
+		responseRecorder := serve(http.MethodPut, "/profiles/"+created.Id, "user-1", map[string]interface{}{
+			"name": "home",
+			"city": "Torino",
+		})
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		updated := decode(t, responseRecorder)
+		require.Equal(t, "Torino", updated.City)
+		require.Equal(t, "user-1", updated.Owner)
+		require.Equal(t, created.CreatedAt, updated.CreatedAt)
+	})
+
+	testCase.Run("delete", func(t *testing.T) {
+		require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/profiles/"+created.Id, "user-1", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/profiles/"+created.Id, "user-1", nil).Code)
+		require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/bridges?profile="+created.Id, "user-1", nil).Code)
+	})
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	// limiters idle for longer than this are dropped, since their bucket is full again anyway
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+// clientLimiters keeps a token bucket for every client seen recently.
+type clientLimiters struct {
+	mutex     sync.Mutex
+	limit     rate.Limit
+	burst     int
+	limiters  map[string]*clientLimiter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newClientLimiters(requestsPerSecond float64, burst int) *clientLimiters {
+	return &clientLimiters{
+		limit:    rate.Limit(requestsPerSecond),
+		burst:    burst,
+		limiters: map[string]*clientLimiter{},
+		now:      time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the client. When the bucket is empty it
+// returns false together with the time to wait before the next request is allowed.
+func (l *clientLimiters) Allow(client string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	entry, ok := l.limiters[client]
+	if !ok {
+		entry = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[client] = entry
+	}
+	entry.lastSeen = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, limiterIdleTimeout
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *clientLimiters) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterIdleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for client, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) > limiterIdleTimeout {
+			delete(l.limiters, client)
+		}
+	}
+}
+
+// rateLimitMiddleware limits the requests of every client, identified by its IP address,
+// and bounds the size of the request bodies. It must run before authMiddleware, so that
+// requests with wrong credentials are limited as well.
+// The status and metrics routes are not limited.
+// A rate or a body size lower or equal to zero disables the related check.
+func rateLimitMiddleware(env EnvironmentVariables) mux.MiddlewareFunc {
+	var limiters *clientLimiters
+	if env.RateLimitRequestsPerSecond > 0 {
+		limiters = newClientLimiters(env.RateLimitRequestsPerSecond, env.RateLimitBurst)
+	}
+	maxBodyBytes := int64(env.MaxRequestBodyBytes)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.URL.Path, "/-/") {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			if limiters != nil && !allowRequest(w, limiters, addressKey(req, env.RateLimitTrustForwardedFor)) {
+				return
+			}
+
+			if maxBodyBytes > 0 && req.Body != nil {
+				if req.ContentLength > maxBodyBytes {
+					http.Error(w, errRequestBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				req.Body = &limitedBody{
+					ReadCloser: http.MaxBytesReader(w, req.Body, maxBodyBytes),
+					limit:      maxBodyBytes,
+				}
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// subjectRateLimitMiddleware limits the requests of every authenticated subject, whatever
+// the addresses it calls from. It must run after authMiddleware, while anonymous requests
+// are limited by rateLimitMiddleware only.
+// A rate lower or equal to zero disables the limit.
+func subjectRateLimitMiddleware(env EnvironmentVariables) mux.MiddlewareFunc {
+	if env.RateLimitRequestsPerSecond <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiters := newClientLimiters(env.RateLimitRequestsPerSecond, env.RateLimitBurst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			identity, ok := identityFromContext(req.Context())
+			if ok && !allowRequest(w, limiters, "subject:"+identity.Subject) {
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// allowRequest takes a token from the bucket of the client, answering 429 with the time to
+// wait when the bucket is empty.
+func allowRequest(w http.ResponseWriter, limiters *clientLimiters, client string) bool {
+	allowed, retryAfter := limiters.Allow(client)
+	if !allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	}
+	return allowed
+}
+
+// addressKey identifies the client by its IP address, whatever credentials it sends.
+// The first address of X-Forwarded-For is used only when the proxy in front is trusted.
+func addressKey(req *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwardedFor := req.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limitedBody reports errRequestBodyTooLarge once the body exceeds the limit,
+// so that handlers can tell it apart from malformed bodies.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		return n, errRequestBodyTooLarge
+	}
+	return n, err
+}
+
+// writeDecodeError answers a request whose body could not be decoded.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errRequestBodyTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
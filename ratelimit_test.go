@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientLimiters(testCase *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	newLimiters := func() *clientLimiters {
+		limiters := newClientLimiters(1, 2)
+		limiters.now = func() time.Time { return now }
+		return limiters
+	}
+
+	testCase.Run("allows the burst and then asks to retry", func(t *testing.T) {
+		limiters := newLimiters()
+
+		allowed, _ := limiters.Allow("ip:1.2.3.4")
+		require.True(t, allowed)
+		allowed, _ = limiters.Allow("ip:1.2.3.4")
+		require.True(t, allowed)
+		allowed, retryAfter := limiters.Allow("ip:1.2.3.4")
+		require.False(t, allowed)
+		require.Equal(t, time.Second, retryAfter)
+	})
+
+	testCase.Run("keeps a bucket per client", func(t *testing.T) {
+		limiters := newLimiters()
+
+		limiters.Allow("ip:1.2.3.4")
+		limiters.Allow("ip:1.2.3.4")
+		allowed, _ := limiters.Allow("ip:5.6.7.8")
+		require.True(t, allowed)
+	})
+
+	testCase.Run("drops idle clients", func(t *testing.T) {
+		limiters := newLimiters()
+
+		limiters.Allow("ip:1.2.3.4")
+		now = now.Add(2 * limiterIdleTimeout)
+		limiters.Allow("ip:5.6.7.8")
+		require.Len(t, limiters.limiters, 1)
+	})
+}
+
+func TestAddressKey(testCase *testing.T) {
+	testCase.Run("api key", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/bridges", nil)
+		request.RemoteAddr = "1.2.3.4:5678"
+		request.Header.Set(apiKeyHeader, "random")
+		require.Equal(t, "ip:1.2.3.4", addressKey(request, false))
+	})
+
+	testCase.Run("remote address", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/bridges", nil)
+		request.RemoteAddr = "1.2.3.4:5678"
+		request.Header.Set("X-Forwarded-For", "9.9.9.9")
+		require.Equal(t, "ip:1.2.3.4", addressKey(request, false))
+	})
+
+	testCase.Run("trusted forwarded for", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/bridges", nil)
+		request.RemoteAddr = "1.2.3.4:5678"
+		request.Header.Set("X-Forwarded-For", "9.9.9.9, 1.2.3.4")
+		require.Equal(t, "ip:9.9.9.9", addressKey(request, true))
+	})
+}
+
+func TestRateLimitMiddleware(testCase *testing.T) {
+	newRouter := func(env EnvironmentVariables) *mux.Router {
+		router := mux.NewRouter()
+		router.Use(rateLimitMiddleware(env))
+		StatusRoutes(router, "feriapp-backend-go", "")
+		setupBridgesRouter(router, nil, storage.NewMemory(), testMessages, testEnv)
+		return router
+	}
+
+	testCase.Run("429 with Retry-After when the bucket is empty", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{RateLimitRequestsPerSecond: 0.5, RateLimitBurst: 1})
+
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges?daysOff=9", nil))
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+
+		responseRecorder = httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/bridges?daysOff=9", nil))
+		require.Equal(t, http.StatusTooManyRequests, responseRecorder.Code)
+		require.Equal(t, "2", responseRecorder.Header().Get("Retry-After"))
+	})
+
+	testCase.Run("random API keys share the bucket of the address", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{RateLimitRequestsPerSecond: 0.5, RateLimitBurst: 1})
+
+		for index, expected := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+			request := httptest.NewRequest(http.MethodGet, "/bridges?daysOff=9", nil)
+			request.Header.Set(apiKeyHeader, fmt.Sprintf("random-%d", index))
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, request)
+			require.Equal(t, expected, responseRecorder.Code)
+		}
+	})
+
+	testCase.Run("wrong credentials are limited", func(t *testing.T) {
+		env := EnvironmentVariables{
+			RateLimitRequestsPerSecond: 0.5,
+			RateLimitBurst:             2,
+			AuthAPIKeysFilePath:        writeTestFile(t, "keys.json", []apiKey{{Key: "partner-key", Subject: "partner"}}),
+		}
+		auth, err := newAuthenticator(env)
+		require.NoError(t, err)
+		router := mux.NewRouter()
+		router.Use(rateLimitMiddleware(env))
+		router.Use(authMiddleware(auth))
+		router.Use(subjectRateLimitMiddleware(env))
+		setupBridgesRouter(router, nil, storage.NewMemory(), testMessages, testEnv)
+
+		for index, expected := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+			request := httptest.NewRequest(http.MethodGet, "/bridges?daysOff=9", nil)
+			request.Header.Set(apiKeyHeader, fmt.Sprintf("guess-%d", index))
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, request)
+			require.Equal(t, expected, responseRecorder.Code)
+		}
+	})
+
+	testCase.Run("authenticated subjects are limited across addresses", func(t *testing.T) {
+		env := EnvironmentVariables{RateLimitRequestsPerSecond: 0.5, RateLimitBurst: 1}
+		router := mux.NewRouter()
+		router.Use(rateLimitMiddleware(env))
+		// the test identity stands in for the authentication middleware
+		router.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				next.ServeHTTP(w, req.WithContext(contextWithIdentity(req.Context(), Identity{Subject: "partner", Method: authMethodAPIKey})))
+			})
+		})
+		router.Use(subjectRateLimitMiddleware(env))
+		setupBridgesRouter(router, nil, storage.NewMemory(), testMessages, testEnv)
+
+		for index, expected := range []int{http.StatusBadRequest, http.StatusTooManyRequests} {
+			request := httptest.NewRequest(http.MethodGet, "/bridges?daysOff=9", nil)
+			request.RemoteAddr = fmt.Sprintf("1.2.3.%d:5678", index)
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, request)
+			require.Equal(t, expected, responseRecorder.Code)
+		}
+	})
+
+	testCase.Run("status routes are not limited", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{RateLimitRequestsPerSecond: 0.5, RateLimitBurst: 1})
+
+		for i := 0; i < 3; i++ {
+			responseRecorder := httptest.NewRecorder()
+			router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/-/healthz", nil))
+			require.Equal(t, http.StatusOK, responseRecorder.Code)
+		}
+	})
+
+	testCase.Run("413 when the declared body is too large", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{MaxRequestBodyBytes: 16})
+
+		responseRecorder := httptest.NewRecorder()
+		body := `{"city":"` + strings.Repeat("a", 32) + `"}`
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/bridges", strings.NewReader(body)))
+		require.Equal(t, http.StatusRequestEntityTooLarge, responseRecorder.Code)
+	})
+
+	testCase.Run("413 when a streamed body is too large", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{MaxRequestBodyBytes: 16})
+
+		responseRecorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBufferString(`[{"id":"`+strings.Repeat("a", 32)+`"}]`))
+		request.ContentLength = -1
+		router.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusRequestEntityTooLarge, responseRecorder.Code)
+	})
+
+	testCase.Run("malformed bodies are still a 400", func(t *testing.T) {
+		router := newRouter(EnvironmentVariables{MaxRequestBodyBytes: 1024})
+
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodPost, "/bridges", strings.NewReader("{")))
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+	})
+}
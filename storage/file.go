@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// File is a Store keeping the data in memory and writing a JSON snapshot of it
+// to a single file after every change.
+type File struct {
+	*Memory
+	path string
+}
+
+// snapshot is the content of the file written by File.
+type snapshot struct {
+	Profiles      []Profile      `json:"profiles"`
+	Organizations []Organization `json:"organizations"`
+	Bookings      []Booking      `json:"bookings"`
+}
+
+// NewFile creates a File store, loading the snapshot at path when it exists.
+func NewFile(path string) (*File, error) {
+	f := &File{Memory: NewMemory(), path: path}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading storage file: %w", err)
+	}
+	if err == nil {
+		var data snapshot
+		if err := json.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("parsing storage file %s: %w", path, err)
+		}
+		for _, profile := range data.Profiles {
+			f.profiles[profile.Id] = profile
+		}
+		for _, organization := range data.Organizations {
+			f.organizations[organization.Id] = organization
+		}
+		for _, booking := range data.Bookings {
+			f.bookings[booking.Id] = booking
+		}
+	}
+
+	f.persist = f.write
+	return f, nil
+}
+
+// write replaces the file with the current data. The snapshot is written to a temporary
+// file renamed over the previous one, so that a crash never leaves a partial file.
+func (f *File) write() error {
+	data := snapshot{Profiles: []Profile{}, Organizations: []Organization{}, Bookings: []Booking{}}
+	for _, profile := range f.profiles {
+		data.Profiles = append(data.Profiles, profile)
+	}
+	sort.Slice(data.Profiles, func(i, j int) bool { return data.Profiles[i].Id < data.Profiles[j].Id })
+	for _, organization := range f.organizations {
+		data.Organizations = append(data.Organizations, organization)
+	}
+	sort.Slice(data.Organizations, func(i, j int) bool { return data.Organizations[i].Id < data.Organizations[j].Id })
+	for _, booking := range f.bookings {
+		data.Bookings = append(data.Bookings, booking)
+	}
+	sort.Slice(data.Bookings, func(i, j int) bool { return data.Bookings[i].Id < data.Bookings[j].Id })
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("writing storage file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing storage file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing storage file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("writing storage file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store keeping the data in the process memory.
+type Memory struct {
+	mutex         sync.RWMutex
+	profiles      map[string]Profile
+	organizations map[string]Organization
+	bookings      map[string]Booking
+	now           func() time.Time
+	// persist is called with the lock held after every change: when it fails the change is reverted
+	persist func() error
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		profiles:      map[string]Profile{},
+		organizations: map[string]Organization{},
+		bookings:      map[string]Booking{},
+		now:           time.Now,
+		persist:       func() error { return nil },
+	}
+}
+
+// CreateProfile implements Store.
+func (m *Memory) CreateProfile(ctx context.Context, profile Profile) (Profile, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if profile.Id == "" {
+		profile.Id = NewID()
+	}
+	if _, ok := m.profiles[profile.Id]; ok {
+		return Profile{}, ErrConflict
+	}
+	profile.CreatedAt = m.now().UTC()
+	profile.UpdatedAt = profile.CreatedAt
+	profile = cloneProfile(profile)
+
+	m.profiles[profile.Id] = profile
+	if err := m.persist(); err != nil {
+		delete(m.profiles, profile.Id)
+		return Profile{}, err
+	}
+	return cloneProfile(profile), nil
+}
+
+// GetProfile implements Store.
+func (m *Memory) GetProfile(ctx context.Context, id string) (Profile, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	profile, ok := m.profiles[id]
+	if !ok {
+		return Profile{}, ErrNotFound
+	}
+	return cloneProfile(profile), nil
+}
+
+// ListProfiles implements Store.
+func (m *Memory) ListProfiles(ctx context.Context, owner string) ([]Profile, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	profiles := []Profile{}
+	for _, profile := range m.profiles {
+		if profile.Owner == owner {
+			profiles = append(profiles, cloneProfile(profile))
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Id < profiles[j].Id })
+	return profiles, nil
+}
+
+// UpdateProfile implements Store.
+func (m *Memory) UpdateProfile(ctx context.Context, profile Profile) (Profile, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.profiles[profile.Id]
+	if !ok {
+		return Profile{}, ErrNotFound
+	}
+	profile.Owner = previous.Owner
+	profile.CreatedAt = previous.CreatedAt
+	profile.UpdatedAt = m.now().UTC()
+	profile = cloneProfile(profile)
+
+	m.profiles[profile.Id] = profile
+	if err := m.persist(); err != nil {
+		m.profiles[profile.Id] = previous
+		return Profile{}, err
+	}
+	return cloneProfile(profile), nil
+}
+
+// DeleteProfile implements Store.
+func (m *Memory) DeleteProfile(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.profiles[id]
+	if !ok {
+		return ErrNotFound
+	}
+	bookings := map[string]Booking{}
+	for bookingID, booking := range m.bookings {
+		if booking.ProfileId == id {
+			bookings[bookingID] = booking
+			delete(m.bookings, bookingID)
+		}
+	}
+	delete(m.profiles, id)
+	if err := m.persist(); err != nil {
+		m.profiles[id] = previous
+		for bookingID, booking := range bookings {
+			m.bookings[bookingID] = booking
+		}
+		return err
+	}
+	return nil
+}
+
+// CreateOrganization implements Store.
+func (m *Memory) CreateOrganization(ctx context.Context, organization Organization) (Organization, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if organization.Id == "" {
+		organization.Id = NewID()
+	}
+	if _, ok := m.organizations[organization.Id]; ok {
+		return Organization{}, ErrConflict
+	}
+	organization.CreatedAt = m.now().UTC()
+	organization.UpdatedAt = organization.CreatedAt
+	organization = cloneOrganization(organization)
+
+	m.organizations[organization.Id] = organization
+	if err := m.persist(); err != nil {
+		delete(m.organizations, organization.Id)
+		return Organization{}, err
+	}
+	return cloneOrganization(organization), nil
+}
+
+// GetOrganization implements Store.
+func (m *Memory) GetOrganization(ctx context.Context, id string) (Organization, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	organization, ok := m.organizations[id]
+	if !ok {
+		return Organization{}, ErrNotFound
+	}
+	return cloneOrganization(organization), nil
+}
+
+// ListOrganizations implements Store.
+func (m *Memory) ListOrganizations(ctx context.Context, owner string) ([]Organization, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	organizations := []Organization{}
+	for _, organization := range m.organizations {
+		if organization.Owner == owner {
+			organizations = append(organizations, cloneOrganization(organization))
+		}
+	}
+	sort.Slice(organizations, func(i, j int) bool { return organizations[i].Id < organizations[j].Id })
+	return organizations, nil
+}
+
+// UpdateOrganization implements Store.
+func (m *Memory) UpdateOrganization(ctx context.Context, organization Organization) (Organization, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.organizations[organization.Id]
+	if !ok {
+		return Organization{}, ErrNotFound
+	}
+	organization.Owner = previous.Owner
+	organization.CreatedAt = previous.CreatedAt
+	organization.UpdatedAt = m.now().UTC()
+	organization = cloneOrganization(organization)
+
+	m.organizations[organization.Id] = organization
+	if err := m.persist(); err != nil {
+		m.organizations[organization.Id] = previous
+		return Organization{}, err
+	}
+	return cloneOrganization(organization), nil
+}
+
+// DeleteOrganization implements Store.
+func (m *Memory) DeleteOrganization(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.organizations[id]
+	if !ok {
+		return ErrNotFound
+	}
+	linked := map[string]Profile{}
+	for profileID, profile := range m.profiles {
+		if profile.OrganizationId == id {
+			linked[profileID] = profile
+			profile.OrganizationId = ""
+			m.profiles[profileID] = profile
+		}
+	}
+	delete(m.organizations, id)
+	if err := m.persist(); err != nil {
+		m.organizations[id] = previous
+		for profileID, profile := range linked {
+			m.profiles[profileID] = profile
+		}
+		return err
+	}
+	return nil
+}
+
+// CreateBooking implements Store.
+func (m *Memory) CreateBooking(ctx context.Context, booking Booking) (Booking, error) {
+	return m.CreateBookingIf(ctx, booking, nil)
+}
+
+// CreateBookingIf implements Store.
+func (m *Memory) CreateBookingIf(ctx context.Context, booking Booking, check BookingCheck) (Booking, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if booking.Id == "" {
+		booking.Id = NewID()
+	}
+	if _, ok := m.bookings[booking.Id]; ok {
+		return Booking{}, ErrConflict
+	}
+	if _, ok := m.profiles[booking.ProfileId]; !ok {
+		return Booking{}, ErrNotFound
+	}
+	for _, existing := range m.bookings {
+		if existing.ProfileId == booking.ProfileId && existing.Overlaps(booking.Start, booking.End) {
+			return Booking{}, ErrOverlap
+		}
+	}
+	if check != nil {
+		if err := check(m.profileBookings(booking.ProfileId)); err != nil {
+			return Booking{}, err
+		}
+	}
+	booking.CreatedAt = m.now().UTC()
+	booking.UpdatedAt = booking.CreatedAt
+
+	m.bookings[booking.Id] = booking
+	if err := m.persist(); err != nil {
+		delete(m.bookings, booking.Id)
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+// GetBooking implements Store.
+func (m *Memory) GetBooking(ctx context.Context, id string) (Booking, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	booking, ok := m.bookings[id]
+	if !ok {
+		return Booking{}, ErrNotFound
+	}
+	return booking, nil
+}
+
+// ListBookings implements Store.
+func (m *Memory) ListBookings(ctx context.Context, profileID string) ([]Booking, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.profileBookings(profileID), nil
+}
+
+// profileBookings returns the bookings of the profile sorted by start. The mutex must be held.
+func (m *Memory) profileBookings(profileID string) []Booking {
+	bookings := []Booking{}
+	for _, booking := range m.bookings {
+		if booking.ProfileId == profileID {
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].Start.Before(bookings[j].Start) })
+	return bookings
+}
+
+// UpdateBookingStatus implements Store.
+func (m *Memory) UpdateBookingStatus(ctx context.Context, id string, status string) (Booking, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.bookings[id]
+	if !ok {
+		return Booking{}, ErrNotFound
+	}
+	booking := previous
+	booking.Status = status
+	booking.UpdatedAt = m.now().UTC()
+
+	m.bookings[id] = booking
+	if err := m.persist(); err != nil {
+		m.bookings[id] = previous
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+// DeleteBooking implements Store.
+func (m *Memory) DeleteBooking(ctx context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.bookings[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.bookings, id)
+	if err := m.persist(); err != nil {
+		m.bookings[id] = previous
+		return err
+	}
+	return nil
+}
+
+// Close implements Store.
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package storage persists the data saved by the users, such as their profiles.
+//
+// Memory keeps everything in the process, while File also writes a JSON snapshot
+// to disk after every change, so that the data survives restarts.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/leave"
+)
+
+var (
+	// ErrNotFound is returned when the requested entity does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when creating an entity whose id already exists.
+	ErrConflict = errors.New("already exists")
+	// ErrOverlap is returned when a booking overlaps another booking of the same profile.
+	ErrOverlap = errors.New("overlaps an existing booking")
+)
+
+const (
+	// BookingPlanned marks a bridge the user intends to take.
+	BookingPlanned = "planned"
+	// BookingBooked marks a bridge whose leave has been requested.
+	BookingBooked = "booked"
+	// BookingForced marks the leave taken by a closure of the organization of a profile.
+	// Such bookings are computed from the closures and never stored.
+	BookingForced = "forced"
+	// BookingCompensation marks the day of leave given back for an holiday falling on a
+	// compensated weekday. Such bookings are computed from the holidays and never stored.
+	BookingCompensation = "compensation"
+)
+
+// Profile holds the settings of a user, used to compute bridges without resending them.
+type Profile struct {
+	Id    string `json:"id" bson:"_id"`
+	Owner string `json:"owner,omitempty" bson:"owner"`
+	Name  string `json:"name" bson:"name"`
+	// AnnualLeaveDays is the leave available every year, not tracked when zero.
+	AnnualLeaveDays int `json:"annualLeaveDays" bson:"annualLeaveDays"`
+	// OrganizationId links the profile to the organization whose closures and holidays it follows.
+	OrganizationId string `json:"organizationId,omitempty" bson:"organizationId,omitempty"`
+	// Leave tracks the leave accrued under a contract instead of AnnualLeaveDays, when set.
+	// Its usages are the bookings of the profile.
+	Leave *leave.Account `json:"leave,omitempty" bson:"leave,omitempty"`
+	// CompensatedDays are the weekdays, from 0 (Sunday) to 6 (Saturday), whose holidays are
+	// given back as a day of leave, as Sunday under some Italian contracts.
+	CompensatedDays        []int     `json:"compensatedDays,omitempty" bson:"compensatedDays,omitempty"`
+	CreatedAt              time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt              time.Time `json:"updatedAt" bson:"updatedAt"`
+	bridges.BridgesRequest `bson:",inline"`
+}
+
+// Organization is a company whose closures and holidays are shared by the profiles linked to it.
+type Organization struct {
+	Id    string `json:"id" bson:"_id"`
+	Owner string `json:"owner,omitempty" bson:"owner"`
+	Name  string `json:"name" bson:"name"`
+	// Closures are the periods the organization is closed, taking leave from its people.
+	Closures []Closure `json:"closures" bson:"closures"`
+	// CustomHolidays are the days the organization is closed without taking leave, as the patron saint day.
+	CustomHolidays []bridges.CustomHolidays `json:"customHolidays" bson:"customHolidays"`
+	CreatedAt      time.Time                `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time                `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Closure is a period from Start to End, both included. Like custom holidays, its days are
+// either dates, as 2021-08-09, or yearly ones, as 12-24, ending the year after when End
+// comes before Start.
+type Closure struct {
+	Name  string `json:"name" bson:"name"`
+	Start string `json:"start" bson:"start"`
+	End   string `json:"end" bson:"end"`
+}
+
+// Booking is a bridge saved by a profile, costing LeaveDays of the budget of the year it starts in.
+type Booking struct {
+	Id        string     `json:"id" bson:"_id"`
+	ProfileId string     `json:"profileId" bson:"profileId"`
+	BridgeId  string     `json:"bridgeId" bson:"bridgeId"`
+	Status    string     `json:"status" bson:"status"`
+	Start     civil.Date `json:"start" bson:"start"`
+	End       civil.Date `json:"end" bson:"end"`
+	LeaveDays int        `json:"leaveDays" bson:"leaveDays"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// BookingCheck tells whether a booking can be saved given the bookings of its profile, sorted
+// by start. It must not use the store, which is locked while it runs.
+type BookingCheck func(bookings []Booking) error
+
+// Overlaps tells whether the booking shares at least a day with the period from start to end.
+func (b Booking) Overlaps(start, end civil.Date) bool {
+	return !b.Start.After(end) && !start.After(b.End)
+}
+
+// Store is implemented by every storage backend. It is safe for concurrent use.
+type Store interface {
+	// CreateProfile saves a new profile, assigning it an id when empty.
+	CreateProfile(ctx context.Context, profile Profile) (Profile, error)
+	GetProfile(ctx context.Context, id string) (Profile, error)
+	// ListProfiles returns the profiles of owner sorted by id.
+	ListProfiles(ctx context.Context, owner string) ([]Profile, error)
+	// UpdateProfile replaces an existing profile, keeping its owner and creation time.
+	UpdateProfile(ctx context.Context, profile Profile) (Profile, error)
+	// DeleteProfile removes the profile together with its bookings.
+	DeleteProfile(ctx context.Context, id string) error
+
+	// CreateOrganization saves a new organization, assigning it an id when empty.
+	CreateOrganization(ctx context.Context, organization Organization) (Organization, error)
+	GetOrganization(ctx context.Context, id string) (Organization, error)
+	// ListOrganizations returns the organizations of owner sorted by id.
+	ListOrganizations(ctx context.Context, owner string) ([]Organization, error)
+	// UpdateOrganization replaces an existing organization, keeping its owner and creation time.
+	UpdateOrganization(ctx context.Context, organization Organization) (Organization, error)
+	// DeleteOrganization removes the organization, unlinking the profiles linked to it.
+	DeleteOrganization(ctx context.Context, id string) error
+
+	// CreateBooking saves a new booking, failing with ErrOverlap when the profile
+	// has already booked any of its days.
+	CreateBooking(ctx context.Context, booking Booking) (Booking, error)
+	// CreateBookingIf saves a new booking as CreateBooking does, only when check accepts the
+	// bookings the profile already has, failing with the error of check otherwise. No booking
+	// of the profile is saved in between, so that the check holds once the booking is saved.
+	CreateBookingIf(ctx context.Context, booking Booking, check BookingCheck) (Booking, error)
+	GetBooking(ctx context.Context, id string) (Booking, error)
+	// ListBookings returns the bookings of the profile sorted by start.
+	ListBookings(ctx context.Context, profileID string) ([]Booking, error)
+	// UpdateBookingStatus changes the status of an existing booking.
+	UpdateBookingStatus(ctx context.Context, id string, status string) (Booking, error)
+	DeleteBooking(ctx context.Context, id string) error
+
+	Close() error
+}
+
+// NewID returns a random identifier, hard enough to guess to be used in URLs.
+func NewID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+func cloneProfile(profile Profile) Profile {
+	if profile.DaysOff != nil {
+		profile.DaysOff = append([]int{}, profile.DaysOff...)
+	}
+	if profile.CustomHolidays != nil {
+		profile.CustomHolidays = append([]bridges.CustomHolidays{}, profile.CustomHolidays...)
+	}
+	if profile.CompensatedDays != nil {
+		profile.CompensatedDays = append([]int{}, profile.CompensatedDays...)
+	}
+	if profile.Leave != nil {
+		account := *profile.Leave
+		account.Policy.Entitlements = append([]leave.Entitlement{}, account.Policy.Entitlements...)
+		account.Opening = append([]leave.Lot{}, account.Opening...)
+		account.Grants = append([]leave.Grant{}, account.Grants...)
+		account.Usages = append([]leave.Usage{}, account.Usages...)
+		profile.Leave = &account
+	}
+	return profile
+}
+
+func cloneOrganization(organization Organization) Organization {
+	if organization.Closures != nil {
+		organization.Closures = append([]Closure{}, organization.Closures...)
+	}
+	if organization.CustomHolidays != nil {
+		organization.CustomHolidays = append([]bridges.CustomHolidays{}, organization.CustomHolidays...)
+	}
+	return organization
+}
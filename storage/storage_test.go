@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/civil"
+	"feriapp-backend-go/leave"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tempFilePath(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "feriapp-storage")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "data.json")
+}
+
+// stores creates an empty store of every backend.
+var stores = map[string]func(t *testing.T) Store{
+	"memory": func(t *testing.T) Store { return NewMemory() },
+	"file": func(t *testing.T) Store {
+		store, err := NewFile(tempFilePath(t))
+		require.NoError(t, err)
+		return store
+	},
+}
+
+func TestStores(testCase *testing.T) {
+	for name, newStore := range stores {
+		testCase.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			defer store.Close()
+
+			created, err := store.CreateProfile(ctx, Profile{
+				Owner:           "user-1",
+				Name:            "work",
+				CompensatedDays: []int{0},
+				BridgesRequest:  bridges.BridgesRequest{City: "Milano", DaysOff: []int{0, 6}},
+			})
+			require.NoError(t, err)
+			require.Len(t, created.Id, 32)
+			require.False(t, created.CreatedAt.IsZero())
+
+			_, err = store.CreateProfile(ctx, Profile{Id: created.Id})
+			require.Equal(t, ErrConflict, err)
+
+			// the stored profile is not shared with the caller
+			created.DaysOff[0] = 3
+			created.CompensatedDays[0] = 3
+			found, err := store.GetProfile(ctx, created.Id)
+			require.NoError(t, err)
+			require.Equal(t, []int{0, 6}, found.DaysOff)
+			require.Equal(t, []int{0}, found.CompensatedDays)
+
+			accrued, err := store.CreateProfile(ctx, Profile{Owner: "user-3", Leave: &leave.Account{
+				Grants: []leave.Grant{{Name: "compensation", Date: civil.NewDate(2021, time.June, 2), Hours: 8}},
+			}})
+			require.NoError(t, err)
+			accrued.Leave.Grants[0].Hours = 80
+			accrued, err = store.GetProfile(ctx, accrued.Id)
+			require.NoError(t, err)
+			require.Equal(t, 8.0, accrued.Leave.Grants[0].Hours)
+
+			found.City = "Roma"
+			found.Owner = "someone-else"
+			updated, err := store.UpdateProfile(ctx, found)
+			require.NoError(t, err)
+			require.Equal(t, "Roma", updated.City)
+			require.Equal(t, "user-1", updated.Owner)
+			require.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+			_, err = store.CreateProfile(ctx, Profile{Owner: "user-2"})
+			require.NoError(t, err)
+			profiles, err := store.ListProfiles(ctx, "user-1")
+			require.NoError(t, err)
+			require.Len(t, profiles, 1)
+			require.Equal(t, "Roma", profiles[0].City)
+
+			require.NoError(t, store.DeleteProfile(ctx, created.Id))
+			_, err = store.GetProfile(ctx, created.Id)
+			require.Equal(t, ErrNotFound, err)
+			require.Equal(t, ErrNotFound, store.DeleteProfile(ctx, created.Id))
+			_, err = store.UpdateProfile(ctx, Profile{Id: created.Id})
+			require.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+func TestCreateBookingIf(testCase *testing.T) {
+	for name, newStore := range stores {
+		testCase.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			defer store.Close()
+			profile, err := store.CreateProfile(ctx, Profile{Owner: "user-1"})
+			require.NoError(t, err)
+
+			// every booking costs a day out of a budget of three
+			errBudget := errors.New("over budget")
+			withinBudget := func(bookings []Booking) error {
+				if len(bookings) >= 3 {
+					return errBudget
+				}
+				return nil
+			}
+			var wait sync.WaitGroup
+			results := make(chan error, 10)
+			for day := 1; day <= 10; day++ {
+				wait.Add(1)
+				go func(date civil.Date) {
+					defer wait.Done()
+					_, err := store.CreateBookingIf(ctx, Booking{ProfileId: profile.Id, Start: date, End: date, LeaveDays: 1}, withinBudget)
+					results <- err
+				}(civil.NewDate(2021, time.March, day))
+			}
+			wait.Wait()
+			close(results)
+
+			rejected := 0
+			for err := range results {
+				if err != nil {
+					require.Equal(t, errBudget, err)
+					rejected++
+				}
+			}
+			require.Equal(t, 7, rejected)
+			bookings, err := store.ListBookings(ctx, profile.Id)
+			require.NoError(t, err)
+			require.Len(t, bookings, 3)
+		})
+	}
+}
+
+func TestOrganizations(testCase *testing.T) {
+	for name, newStore := range stores {
+		testCase.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			defer store.Close()
+
+			created, err := store.CreateOrganization(ctx, Organization{
+				Owner:    "admin",
+				Name:     "Mia",
+				Closures: []Closure{{Name: "summer", Start: "08-09", End: "08-20"}},
+			})
+			require.NoError(t, err)
+			require.Len(t, created.Id, 32)
+			_, err = store.CreateOrganization(ctx, Organization{Id: created.Id})
+			require.Equal(t, ErrConflict, err)
+
+			created.Closures[0].Name = "changed"
+			found, err := store.GetOrganization(ctx, created.Id)
+			require.NoError(t, err)
+			require.Equal(t, "summer", found.Closures[0].Name)
+
+			found.Owner = "someone-else"
+			found.CustomHolidays = []bridges.CustomHolidays{{Date: "12-07"}}
+			updated, err := store.UpdateOrganization(ctx, found)
+			require.NoError(t, err)
+			require.Equal(t, "admin", updated.Owner)
+			require.Len(t, updated.CustomHolidays, 1)
+
+			organizations, err := store.ListOrganizations(ctx, "admin")
+			require.NoError(t, err)
+			require.Len(t, organizations, 1)
+
+			profile, err := store.CreateProfile(ctx, Profile{Name: "work", OrganizationId: created.Id})
+			require.NoError(t, err)
+			require.NoError(t, store.DeleteOrganization(ctx, created.Id))
+			_, err = store.GetOrganization(ctx, created.Id)
+			require.Equal(t, ErrNotFound, err)
+			profile, err = store.GetProfile(ctx, profile.Id)
+			require.NoError(t, err)
+			require.Empty(t, profile.OrganizationId, "deleted organizations are unlinked from their profiles")
+			require.Equal(t, ErrNotFound, store.DeleteOrganization(ctx, created.Id))
+		})
+	}
+}
+
+func TestFile(testCase *testing.T) {
+	testCase.Run("data survives a restart", func(t *testing.T) {
+		ctx := context.Background()
+		filePath := tempFilePath(t)
+
+		store, err := NewFile(filePath)
+		require.NoError(t, err)
+		created, err := store.CreateProfile(ctx, Profile{Name: "home", BridgesRequest: bridges.BridgesRequest{City: "Torino"}})
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFile(filePath)
+		require.NoError(t, err)
+		found, err := reopened.GetProfile(ctx, created.Id)
+		require.NoError(t, err)
+		require.Equal(t, "Torino", found.City)
+		require.True(t, created.CreatedAt.Equal(found.CreatedAt))
+
+		organization, err := reopened.CreateOrganization(ctx, Organization{Name: "Mia"})
+		require.NoError(t, err)
+		reopened, err = NewFile(filePath)
+		require.NoError(t, err)
+		_, err = reopened.GetOrganization(ctx, organization.Id)
+		require.NoError(t, err)
+	})
+
+	testCase.Run("days saved as timestamps", func(t *testing.T) {
+		filePath := tempFilePath(t)
+		require.NoError(t, ioutil.WriteFile(filePath, []byte(`{
+			"profiles": [{"id": "work", "leave": {"start": "2021-01-01T00:00:00Z", "opening": [{"name": "ferie", "hours": 8, "expires": "0001-01-01T00:00:00Z"}]}}],
+			"bookings": [{"id": "easter", "profileId": "work", "start": "2021-04-02T00:00:00Z", "end": "2021-04-05T00:00:00Z"}]
+		}`), 0600))
+
+		store, err := NewFile(filePath)
+		require.NoError(t, err)
+		booking, err := store.GetBooking(context.Background(), "easter")
+		require.NoError(t, err)
+		require.Equal(t, civil.NewDate(2021, time.April, 2), booking.Start)
+		require.Equal(t, civil.NewDate(2021, time.April, 5), booking.End)
+		profile, err := store.GetProfile(context.Background(), "work")
+		require.NoError(t, err)
+		require.Equal(t, civil.NewDate(2021, time.January, 1), profile.Leave.Start)
+		require.True(t, profile.Leave.Opening[0].Expires.IsZero())
+	})
+
+	testCase.Run("invalid file", func(t *testing.T) {
+		filePath := tempFilePath(t)
+		require.NoError(t, ioutil.WriteFile(filePath, []byte("{"), 0600))
+
+		_, err := NewFile(filePath)
+		require.Error(t, err)
+	})
+
+	testCase.Run("failed writes are reverted", func(t *testing.T) {
+		ctx := context.Background()
+		store, err := NewFile(tempFilePath(t))
+		require.NoError(t, err)
+		created, err := store.CreateProfile(ctx, Profile{Name: "home"})
+		require.NoError(t, err)
+
+		writeError := errors.New("disk full")
+		store.persist = func() error { return writeError }
+
+		_, err = store.CreateProfile(ctx, Profile{Name: "work"})
+		require.Equal(t, writeError, err)
+		created.Name = "renamed"
+		_, err = store.UpdateProfile(ctx, created)
+		require.Equal(t, writeError, err)
+		require.Equal(t, writeError, store.DeleteProfile(ctx, created.Id))
+
+		profiles, err := store.ListProfiles(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, profiles, 1)
+		require.Equal(t, "home", profiles[0].Name)
+	})
+}
+
+func TestMemoryClock(t *testing.T) {
+	store := NewMemory()
+	store.now = func() time.Time { return time.Date(2021, 1, 1, 10, 0, 0, 0, time.Local) }
+
+	created, err := store.CreateProfile(context.Background(), Profile{})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 1, 1, 10, 0, 0, 0, time.Local).UTC(), created.CreatedAt)
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2019 Mia srl
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// acceptsNDJSON tells whether the client asked for a newline delimited JSON stream.
+func acceptsNDJSON(req *http.Request) bool {
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonETag distinguishes the streamed representation from the JSON one.
+func ndjsonETag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-ndjson"`
+}
+
+// ndjsonStream writes one JSON document per line, flushing each of them to the client.
+type ndjsonStream struct {
+	w       http.ResponseWriter
+	encoder *json.Encoder
+	started bool
+}
+
+func newNDJSONStream(w http.ResponseWriter) *ndjsonStream {
+	return &ndjsonStream{w: w, encoder: json.NewEncoder(w)}
+}
+
+func (s *ndjsonStream) Write(value interface{}) error {
+	if !s.started {
+		s.w.Header().Set("Content-Type", ndjsonContentType)
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if err := s.encoder.Encode(value); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// WriteError reports err with an error status when nothing has been streamed yet, otherwise
+// as a final error line since the status code has already been sent.
+func (s *ndjsonStream) WriteError(err error) {
+	if !s.started {
+		writeComputationError(s.w, err)
+		return
+	}
+	_, err = computationErrorStatus(err)
+	s.Write(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+}
+
+// streamBridges writes every year of the response as soon as it is computed. Complete
+// responses are stored in the cache before being filtered, so that they can be replayed
+// to later requests.
+func streamBridges(ctx context.Context, w http.ResponseWriter, bridgesCache *cache.LRU, key string, reqBody bridges.BridgesRequest, now time.Time, filter bridgesFilter) {
+	stream := newNDJSONStream(w)
+
+	if cached, found := bridgesCache.Get(key); found {
+		for _, yearBridges := range cached.([]bridges.YearBridges) {
+			if err := stream.Write(filter(yearBridges)); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	options, err := plannerOptions(ctx, reqBody)
+	if err != nil {
+		stream.WriteError(err)
+		return
+	}
+	responseBody := []bridges.YearBridges{}
+	err = bridgesPlanner.Stream(ctx, options, func(yearBridges bridges.YearBridges) error {
+		responseBody = append(responseBody, yearBridges)
+		return stream.Write(filter(yearBridges))
+	})
+	if err != nil {
+		stream.WriteError(err)
+		return
+	}
+	observeBridgesReturned(responseBody)
+	bridgesCache.Set(key, responseBody, nextDayBoundary(now))
+}
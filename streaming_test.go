@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func readNDJSONLines(t *testing.T, body string) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "/bridges", nil)
+	require.False(t, acceptsNDJSON(request))
+
+	request.Header.Set("Accept", "application/json")
+	require.False(t, acceptsNDJSON(request))
+
+	request.Header.Set("Accept", "application/json, application/x-ndjson; q=0.9")
+	require.True(t, acceptsNDJSON(request))
+}
+
+func TestStreamingRoutes(testCase *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+
+	testRouter := mux.NewRouter()
+	setupBridgesRouter(testRouter, cache.New(16), storage.NewMemory(), testMessages, testEnv)
+
+	testCase.Run("POST /bridges - streams one year per line", func(t *testing.T) {
+		requestBody, _ := json.Marshal(bridges.BridgesRequest{City: "Milano", DayOfHolidays: 2, DaysOff: []int{0, 6}, YearsScope: 2})
+
+		for i := 0; i < 2; i++ {
+			responseRecorder := httptest.NewRecorder()
+			request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBuffer(requestBody))
+			request.Header.Set("Accept", ndjsonContentType)
+			testRouter.ServeHTTP(responseRecorder, request)
+
+			require.Equal(t, http.StatusOK, responseRecorder.Code)
+			require.Equal(t, ndjsonContentType, responseRecorder.Header().Get("Content-Type"))
+			require.True(t, responseRecorder.Flushed, "Every line should be flushed")
+
+			lines := readNDJSONLines(t, responseRecorder.Body.String())
+			require.Len(t, lines, 2, "The response should be the same when served from the cache")
+			var yearBridges bridges.YearBridges
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &yearBridges))
+			require.Len(t, yearBridges.Years, 1)
+		}
+	})
+
+	testCase.Run("GET /bridges - streamed representation has its own ETag", func(t *testing.T) {
+		jsonRecorder := httptest.NewRecorder()
+		jsonRequest, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&yearsScope=1", nil)
+		testRouter.ServeHTTP(jsonRecorder, jsonRequest)
+
+		streamRecorder := httptest.NewRecorder()
+		streamRequest, _ := http.NewRequest(http.MethodGet, "/bridges?city=Milano&daysOff=0,6&yearsScope=1", nil)
+		streamRequest.Header.Set("Accept", ndjsonContentType)
+		testRouter.ServeHTTP(streamRecorder, streamRequest)
+
+		require.Equal(t, http.StatusOK, streamRecorder.Code)
+		require.Len(t, readNDJSONLines(t, streamRecorder.Body.String()), 1)
+		require.Contains(t, streamRecorder.Header().Get("Vary"), "Accept")
+		require.NotEqual(t, jsonRecorder.Header().Get("ETag"), streamRecorder.Header().Get("ETag"))
+	})
+
+	testCase.Run("POST /bridges - errors before streaming are plain 400", func(t *testing.T) {
+		requestBody, _ := json.Marshal(bridges.BridgesRequest{City: "Milano", DayOfHolidays: 2, DaysOff: []int{8}})
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBuffer(requestBody))
+		request.Header.Set("Accept", ndjsonContentType)
+		testRouter.ServeHTTP(responseRecorder, request)
+
+		require.Equal(t, http.StatusBadRequest, responseRecorder.Code)
+	})
+
+	testCase.Run("POST /bridges/batch - streams one item per line", func(t *testing.T) {
+		items := []bridges.BatchBridgesRequest{}
+		for i := 0; i < 5; i++ {
+			items = append(items, bridges.BatchBridgesRequest{Id: fmt.Sprint(i), BridgesRequest: bridges.BridgesRequest{City: "Milano", DayOfHolidays: i, DaysOff: []int{0, 6}, YearsScope: 1}})
+		}
+		requestBody, _ := json.Marshal(items)
+
+		responseRecorder := httptest.NewRecorder()
+		request, _ := http.NewRequest(http.MethodPost, "/bridges/batch", bytes.NewBuffer(requestBody))
+		request.Header.Set("Accept", ndjsonContentType)
+		testRouter.ServeHTTP(responseRecorder, request)
+
+		require.Equal(t, http.StatusOK, responseRecorder.Code)
+		require.Equal(t, ndjsonContentType, responseRecorder.Header().Get("Content-Type"))
+		ids := map[string]bool{}
+		for _, line := range readNDJSONLines(t, responseRecorder.Body.String()) {
+			var result bridges.BatchBridgesResult
+			require.NoError(t, json.Unmarshal([]byte(line), &result))
+			require.Empty(t, result.Error)
+			ids[result.Id] = true
+		}
+		require.Len(t, ids, 5)
+	})
+}
+
+func TestStreamBatchBridges(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	items := []bridges.BatchBridgesRequest{}
+	for i := 0; i < 20; i++ {
+		items = append(items, bridges.BatchBridgesRequest{Id: fmt.Sprint(i), BridgesRequest: bridges.BridgesRequest{City: "Milano", DayOfHolidays: i}})
+	}
+
+	emitted := 0
+	streamBatchBridges(context.Background(), cache.New(0), newBatchItems(items), 1, func(index int, result bridges.BatchBridgesResult) error {
+		emitted++
+		return errors.New("client disconnected")
+	})
+	require.Equal(t, 1, emitted, "No more results should be emitted once the client is gone")
+}
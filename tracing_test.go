@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"feriapp-backend-go/bridges"
+	"feriapp-backend-go/cache"
+	"feriapp-backend-go/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupTracing(testCase *testing.T) {
+	testCase.Run("disabled by default", func(t *testing.T) {
+		shutdown, err := setupTracing(EnvironmentVariables{TracingExporter: "none"})
+		require.NoError(t, err)
+		require.NoError(t, shutdown(context.Background()))
+	})
+
+	testCase.Run("unknown exporter", func(t *testing.T) {
+		_, err := setupTracing(EnvironmentVariables{TracingExporter: "unknown"})
+		require.Error(t, err)
+	})
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	os.Setenv("LANGUAGE_PACK_FILE_PATH", "./helpers/")
+	spanRecorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	testRouter := mux.NewRouter()
+	testRouter.Use(tracingMiddleware)
+	setupBridgesRouter(testRouter, cache.New(0), storage.NewMemory(), testMessages, testEnv)
+
+	requestBody, _ := json.Marshal(bridges.BridgesRequest{City: "Milano", DayOfHolidays: 2, DaysOff: []int{0, 6}, YearsScope: 1})
+	request, _ := http.NewRequest(http.MethodPost, "/bridges", bytes.NewBuffer(requestBody))
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	responseRecorder := httptest.NewRecorder()
+	testRouter.ServeHTTP(responseRecorder, request)
+	require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+
+	spanNames := map[string]bool{}
+	for _, span := range spanRecorder.Ended() {
+		spanNames[span.Name()] = true
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), "All spans should continue the propagated trace")
+	}
+	for _, name := range []string{"POST /bridges", "decodeBridgesRequest", "plan", "bridgesByYear", "loadHolidays"} {
+		require.True(t, spanNames[name], "span %s should have been recorded", name)
+	}
+}